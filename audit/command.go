@@ -0,0 +1,321 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter/registry"
+	"github.com/gideaworx/terraform-exporter/runner"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	kindAdvisory   = "advisory"
+	kindYanked     = "yanked"
+	kindDeprecated = "deprecated"
+	kindInvalid    = "invalid-advisory"
+
+	failNever = "none"
+)
+
+var ErrVulnerable = errors.New("installed plugins have problems")
+
+type Command struct {
+	Plugins []string `arg:"" optional:"" help:"The plugins to audit. Defaults to every installed plugin"`
+	FailOn  string   `default:"low" enum:"none,low,medium,high,critical" help:"Exit with an error when an advisory at least this severe, or a yanked version, is installed, or when advisories can't be checked or a plugin can't be audited, like one installed from a local file. One of none, low, medium, high or critical"`
+	Output  string   `short:"o" default:"table" enum:"table,json" help:"How to print the findings. One of table or json"`
+	r       *registry.PluginRegistries
+}
+
+// Finding is a problem with an installed plugin version
+type Finding struct {
+	Plugin   string `json:"plugin"`
+	Version  string `json:"version"`
+	Active   bool   `json:"active"`
+	Registry string `json:"registry"`
+	// Kind is advisory, yanked, deprecated or invalid-advisory
+	Kind     string `json:"kind"`
+	ID       string `json:"id,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Summary  string `json:"summary,omitempty"`
+	Fixed    string `json:"fixed,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+// Unchecked is an installed plugin version that couldn't be audited
+type Unchecked struct {
+	Plugin  string `json:"plugin"`
+	Version string `json:"version"`
+	Reason  string `json:"reason"`
+}
+
+type Report struct {
+	Audited   int         `json:"audited"`
+	Findings  []Finding   `json:"findings"`
+	Unchecked []Unchecked `json:"unchecked"`
+}
+
+func (c *Command) BeforeApply() error {
+	var err error
+	c.r, err = registry.LoadFromDisk()
+	return err
+}
+
+func (c *Command) Run(ctx *kong.Context) error {
+	boms, err := c.installed()
+	if err != nil {
+		return err
+	}
+
+	report := c.audit(boms)
+
+	if c.Output == "json" {
+		encoder := json.NewEncoder(ctx.Stdout)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		writeReport(ctx, report)
+	}
+
+	if failing := c.failures(report); failing > 0 {
+		return fmt.Errorf("%w: %d findings and plugins that weren't audited fail the audit with --fail-on %s", ErrVulnerable, failing, c.FailOn)
+	}
+
+	return nil
+}
+
+// installed loads the bill of materials of every installed version of the
+// plugins to audit. Inactive versions are audited too, they're one "use" away
+// from running
+func (c *Command) installed() ([]runner.BillOfMaterials, error) {
+	names := c.Plugins
+	if len(names) == 0 {
+		active, err := runner.LoadInstalledBOMs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, bom := range active {
+			names = append(names, bom.Name)
+		}
+	}
+	sort.Strings(names)
+
+	boms := []runner.BillOfMaterials{}
+	for _, name := range names {
+		versions, err := runner.InstalledVersions(name)
+		if err != nil {
+			return nil, err
+		}
+
+		// plugins installed before versions were kept side by side only have
+		// their active version
+		if len(versions) == 0 {
+			bom, err := runner.LoadPluginBOM(name)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+
+			boms = append(boms, bom)
+			continue
+		}
+
+		for _, v := range versions {
+			bom, err := runner.LoadPluginBOM(runner.PluginRef(name, v))
+			if err != nil {
+				return nil, err
+			}
+
+			boms = append(boms, bom)
+		}
+	}
+
+	return boms, nil
+}
+
+func (c *Command) audit(boms []runner.BillOfMaterials) Report {
+	report := Report{Findings: []Finding{}, Unchecked: []Unchecked{}}
+
+	// indexes are revalidated so the advisories are current
+	loaded := map[string]*registry.PluginRegistry{}
+	failures := map[string]error{}
+	for _, bom := range boms {
+		version := bom.Version.String()
+		if bom.Source.Type != "registry" {
+			report.Unchecked = append(report.Unchecked, Unchecked{bom.Name, version, fmt.Sprintf("installed from a %s, not a registry", bom.Source.Type)})
+			continue
+		}
+
+		name := bom.Source.Name
+		reg, ok := loaded[name]
+		if !ok {
+			if reg = c.r.Get(name); reg == nil {
+				failures[name] = fmt.Errorf("registry %q is no longer configured", name)
+			} else if _, err := reg.Refresh(); err != nil {
+				failures[name] = fmt.Errorf("registry %q could not be loaded: %v", name, err)
+				reg = nil
+			}
+			loaded[name] = reg
+		}
+
+		if reg == nil {
+			report.Unchecked = append(report.Unchecked, Unchecked{bom.Name, version, failures[name].Error()})
+			continue
+		}
+
+		findings, err := check(reg, bom)
+		if err != nil {
+			report.Unchecked = append(report.Unchecked, Unchecked{bom.Name, version, err.Error()})
+			continue
+		}
+
+		report.Audited++
+		report.Findings = append(report.Findings, findings...)
+	}
+
+	return report
+}
+
+// check finds the advisories affecting an installed plugin version, and
+// whether the version was yanked or deprecated
+func check(reg *registry.PluginRegistry, bom runner.BillOfMaterials) ([]Finding, error) {
+	version := bom.Version.String()
+	active, err := runner.ActiveVersion(bom.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	finding := Finding{
+		Plugin:   bom.Name,
+		Version:  version,
+		Active:   active == "" || active == runner.NormalizeVersion(version),
+		Registry: bom.Source.Name,
+	}
+
+	findings := []Finding{}
+	if p, ok := reg.FindPlugin(bom.Name); ok {
+		if v, ok := registry.FindVersion(p, version); ok {
+			f := finding
+			f.Summary = v.Notice
+			switch {
+			case v.Yanked:
+				f.Kind = kindYanked
+				findings = append(findings, f)
+			case v.Deprecated:
+				f.Kind = kindDeprecated
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	// an advisory that can't be checked might be the one that matters, so it
+	// fails the audit too
+	advisories, err := reg.AdvisoriesFor(bom.Name, version)
+	if err != nil {
+		f := finding
+		f.Kind = kindInvalid
+		f.Summary = err.Error()
+		findings = append(findings, f)
+	}
+
+	for _, a := range advisories {
+		f := finding
+		f.Kind = kindAdvisory
+		f.ID = a.ID
+		f.Severity = a.Severity
+		f.Summary = a.Summary
+		f.Fixed = a.Fixed
+		f.URL = a.URL
+		findings = append(findings, f)
+	}
+
+	return findings, nil
+}
+
+// failures counts the findings and the plugin versions that weren't audited
+// that fail the audit. A version that wasn't audited could have any problem,
+// so it fails unless nothing does
+func (c *Command) failures(report Report) int {
+	if c.FailOn == failNever {
+		return 0
+	}
+
+	failing := len(report.Unchecked)
+	for _, f := range report.Findings {
+		if c.fails(f) {
+			failing++
+		}
+	}
+
+	return failing
+}
+
+// fails reports whether a finding fails the audit. Yanked versions and
+// advisories that can't be checked fail unless nothing does, and deprecated
+// versions never do
+func (c *Command) fails(f Finding) bool {
+	if c.FailOn == failNever {
+		return false
+	}
+
+	switch f.Kind {
+	case kindYanked, kindInvalid:
+		return true
+	case kindAdvisory:
+		return registry.SeverityRank(f.Severity) >= registry.SeverityRank(c.FailOn)
+	}
+
+	return false
+}
+
+func writeReport(ctx *kong.Context, report Report) {
+	for _, u := range report.Unchecked {
+		fmt.Fprintf(ctx.Stderr, "warning: %s was not audited: %s\n", runner.PluginRef(u.Plugin, u.Version), u.Reason)
+	}
+
+	if len(report.Findings) == 0 {
+		fmt.Fprintf(ctx.Stdout, "Audited %d installed plugin versions, no problems found\n", report.Audited)
+		return
+	}
+
+	table := tablewriter.NewWriter(ctx.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Plugin", "Version", "Registry", "Problem", "Severity", "Fixed In", "Summary"})
+	table.SetHeaderLine(true)
+	table.SetBorder(true)
+
+	for _, f := range report.Findings {
+		version := f.Version
+		if f.Active {
+			version += " (active)"
+		}
+
+		problem := f.Kind
+		if f.ID != "" {
+			problem = f.ID
+		}
+
+		summary := f.Summary
+		if f.URL != "" {
+			summary += "\n" + f.URL
+		}
+
+		severity := tablewriter.Colors{}
+		switch registry.SeverityRank(f.Severity) {
+		case registry.SeverityRank(registry.AdvisoryCritical), registry.SeverityRank(registry.AdvisoryHigh):
+			severity = tablewriter.Colors{tablewriter.Bold, tablewriter.FgRedColor}
+		case registry.SeverityRank(registry.AdvisoryMedium):
+			severity = tablewriter.Colors{tablewriter.FgYellowColor}
+		}
+
+		table.Rich([]string{f.Plugin, version, f.Registry, problem, f.Severity, f.Fixed, summary}, []tablewriter.Colors{{}, {}, {}, {}, severity, {}, {}})
+	}
+
+	table.Render()
+	fmt.Fprintf(ctx.Stdout, "Audited %d installed plugin versions, found %d problems\n", report.Audited, len(report.Findings))
+}
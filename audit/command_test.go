@@ -0,0 +1,46 @@
+package audit
+
+import "testing"
+
+func TestFailures(t *testing.T) {
+	report := Report{
+		Findings: []Finding{
+			{Plugin: "aws", Kind: kindAdvisory, Severity: "low"},
+			{Plugin: "aws", Kind: kindAdvisory, Severity: "high"},
+			{Plugin: "gcp", Kind: kindYanked},
+			{Plugin: "gcp", Kind: kindDeprecated},
+			{Plugin: "azure", Kind: kindInvalid},
+		},
+	}
+
+	unchecked := report
+	unchecked.Unchecked = []Unchecked{
+		{Plugin: "custom", Reason: "installed from a file, not a registry"},
+		{Plugin: "internal", Reason: `registry "corp" could not be loaded`},
+	}
+
+	tests := []struct {
+		failOn string
+		report Report
+		// failing is how many findings and unchecked plugins fail the audit
+		failing int
+	}{
+		{failOn: "low", report: report, failing: 4},
+		{failOn: "medium", report: report, failing: 3},
+		{failOn: "high", report: report, failing: 3},
+		{failOn: "critical", report: report, failing: 2},
+		{failOn: failNever, report: report},
+		{failOn: "low", report: unchecked, failing: 6},
+		{failOn: "critical", report: unchecked, failing: 4},
+		{failOn: "critical", report: Report{Unchecked: unchecked.Unchecked}, failing: 2},
+		{failOn: failNever, report: unchecked},
+		{failOn: "low", report: Report{}},
+	}
+
+	for _, tt := range tests {
+		c := &Command{FailOn: tt.failOn}
+		if failing := c.failures(tt.report); failing != tt.failing {
+			t.Errorf("--fail-on %s with %d findings and %d unchecked: failures() = %d, want %d", tt.failOn, len(tt.report.Findings), len(tt.report.Unchecked), failing, tt.failing)
+		}
+	}
+}
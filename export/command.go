@@ -15,7 +15,9 @@ import (
 type Command struct {
 	OutputDirectory    string   `short:"o" type:"existingdir" default:"." help:"The directory to write the exported files to"`
 	SkipProviderOutput bool     `default:"false" help:"If true, do not write the provider terraform file for the plugin"`
-	CommandName        string   `arg:"" help:"The name of the command to use for export"`
+	LockFile           string   `short:"l" default:"${lock_file}" help:"The project lock file that pins the versions and checksums of the plugins used"`
+	UpdateLock         bool     `default:"false" help:"If set, record the installed plugin in the lock file even if it differs from the locked one"`
+	CommandName        string   `arg:"" help:"The name of the command to use for export, optionally qualified as plugin/command or plugin@version/command"`
 	CommandArgs        []string `arg:"" help:"The args to pass to the command" passthrough:"true"`
 }
 
@@ -42,7 +44,12 @@ func (c *Command) Run(ctx *kong.Context) error {
 		return fmt.Errorf("multiple plugins provide command %q. Valid choices are %q", c.CommandName, strings.Join(options, ", "))
 	}
 
-	pDef, err := runner.LoadPlugin(matching[0][0], nil)
+	pluginRef, err := c.checkLock(matching[0][0])
+	if err != nil {
+		return err
+	}
+
+	pDef, err := runner.LoadPlugin(pluginRef, nil)
 	if err != nil {
 		return err
 	}
@@ -78,3 +85,44 @@ func (c *Command) Run(ctx *kong.Context) error {
 
 	return nil
 }
+
+// checkLock verifies the plugin against the project lock file, and returns the
+// reference of the plugin version to run. If the locked version is installed
+// next to a different active version, the locked version is used
+func (c *Command) checkLock(pluginRef string) (string, error) {
+	lock, err := runner.LoadLockFile(c.LockFile)
+	if err != nil {
+		return "", err
+	}
+
+	pluginName, version, err := runner.ParsePluginRef(pluginRef)
+	if err != nil {
+		return "", err
+	}
+
+	if locked, ok := lock.Get(pluginName); ok && version == "" && !c.UpdateLock {
+		lockedRef := runner.PluginRef(pluginName, locked.Version)
+		if _, err := runner.LoadPluginBOM(lockedRef); err == nil {
+			pluginRef = lockedRef
+		}
+	}
+
+	bom, err := runner.LoadPluginBOM(pluginRef)
+	if err != nil {
+		return "", err
+	}
+
+	if !c.UpdateLock {
+		found, err := lock.Verify(bom)
+		if err != nil {
+			return "", fmt.Errorf("%w. Install the locked plugin or re-run with --update-lock", err)
+		}
+
+		if found {
+			return pluginRef, nil
+		}
+	}
+
+	lock.Set(runner.LockFromBOM(bom))
+	return pluginRef, lock.Save()
+}
@@ -9,7 +9,7 @@ import (
 )
 
 type Command struct {
-	CommandName string `arg:"" help:"The name of the command to use for export"`
+	CommandName string `arg:"" help:"The name of the command to show help for, optionally qualified as plugin/command or plugin@version/command"`
 }
 
 func (c *Command) Run(ctx *kong.Context) error {
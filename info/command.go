@@ -0,0 +1,353 @@
+package info
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter/registry"
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+type Command struct {
+	Plugin   string `arg:"" help:"The plugin to describe, as plugin, plugin@version or registry/plugin. A version selects which installed version's bill of materials is shown"`
+	Registry string `short:"r" help:"The registry to look the plugin up in. Defaults to the registry the plugin was installed from, or the first registry by priority that has the plugin"`
+	Output   string `short:"o" default:"table" enum:"table,json" help:"How to print the information. One of table or json"`
+	r        *registry.PluginRegistries
+}
+
+// PluginInfo is everything known about a plugin, from its installs and from a
+// registry. Either may be missing
+type PluginInfo struct {
+	Name      string         `json:"name"`
+	Installed *InstalledInfo `json:"installed,omitempty"`
+	Registry  *RegistryInfo  `json:"registry,omitempty"`
+}
+
+type InstalledInfo struct {
+	ActiveVersion string   `json:"activeVersion,omitempty"`
+	Versions      []string `json:"versions"`
+	BOM           BOMInfo  `json:"bom"`
+}
+
+type BOMInfo struct {
+	Version     string         `json:"version"`
+	Type        string         `json:"type"`
+	InstallType string         `json:"installType"`
+	Source      SourceInfo     `json:"source"`
+	Integrity   *IntegrityInfo `json:"integrity,omitempty"`
+	Signer      *SignerInfo    `json:"signer,omitempty"`
+	Interpreter string         `json:"interpreter,omitempty"`
+	EntryPoint  string         `json:"entryPoint,omitempty"`
+	Commands    []CommandInfo  `json:"commands"`
+}
+
+type SourceInfo struct {
+	Type   string `json:"type"`
+	Name   string `json:"name,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Mirror string `json:"mirror,omitempty"`
+}
+
+type IntegrityInfo struct {
+	Algorithm string `json:"algorithm"`
+	Checksum  string `json:"checksum"`
+	Scope     string `json:"scope,omitempty"`
+}
+
+type SignerInfo struct {
+	Identity string `json:"identity"`
+	KeyID    string `json:"keyId"`
+	Format   string `json:"format"`
+}
+
+type CommandInfo struct {
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type RegistryInfo struct {
+	Name        string         `json:"name"`
+	URL         string         `json:"url"`
+	Description string         `json:"description,omitempty"`
+	Homepage    string         `json:"homepage,omitempty"`
+	Authors     []string       `json:"authors,omitempty"`
+	Commands    []CommandInfo  `json:"commands,omitempty"`
+	Versions    []VersionInfo  `json:"versions"`
+	Advisories  []AdvisoryInfo `json:"advisories,omitempty"`
+}
+
+type VersionInfo struct {
+	Version string `json:"version"`
+	// Compatible is true if one of the version's artifacts runs on this machine
+	Compatible bool           `json:"compatible"`
+	Yanked     bool           `json:"yanked,omitempty"`
+	Deprecated bool           `json:"deprecated,omitempty"`
+	Notice     string         `json:"notice,omitempty"`
+	Artifacts  []ArtifactInfo `json:"artifacts"`
+}
+
+type AdvisoryInfo struct {
+	ID       string `json:"id"`
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Affected string `json:"affected"`
+	Fixed    string `json:"fixed,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+type ArtifactInfo struct {
+	Architecture string            `json:"architecture"`
+	Type         string            `json:"type"`
+	InstallType  string            `json:"installType"`
+	Locator      string            `json:"locator"`
+	Checksums    map[string]string `json:"checksums,omitempty"`
+	Signature    string            `json:"signature,omitempty"`
+}
+
+func (c *Command) BeforeApply() error {
+	var err error
+	c.r, err = registry.LoadFromDisk()
+	return err
+}
+
+func (c *Command) Run(ctx *kong.Context) error {
+	if registryName, ref := registry.SplitPluginRef(c.Plugin); registryName != "" {
+		if c.Registry != "" && c.Registry != registryName {
+			return fmt.Errorf("%s names registry %q, but --registry is %q", c.Plugin, registryName, c.Registry)
+		}
+
+		c.Registry = registryName
+		c.Plugin = ref
+	}
+
+	pluginName, _, err := runner.ParsePluginRef(c.Plugin)
+	if err != nil {
+		return err
+	}
+
+	info := PluginInfo{Name: pluginName}
+
+	installed, err := c.installedInfo()
+	if err != nil {
+		return err
+	}
+	info.Installed = installed
+
+	registryName := c.Registry
+	if registryName == "" && installed != nil && installed.BOM.Source.Type == "registry" {
+		registryName = installed.BOM.Source.Name
+	}
+
+	if registryName == "" {
+		registryName, _, err = c.r.ResolveRegistry(pluginName)
+	}
+
+	if err == nil {
+		info.Registry, err = c.registryInfo(registryName, pluginName)
+	}
+
+	if err != nil {
+		// what's installed is still worth showing when the registry isn't
+		// there, unless it was asked for explicitly
+		if installed == nil || c.Registry != "" {
+			return err
+		}
+
+		fmt.Fprintf(ctx.Stderr, "warning: %v\n", err)
+	}
+
+	if c.Output == "json" {
+		return writeJSON(ctx, info)
+	}
+
+	return writeTables(ctx, info)
+}
+
+// installedInfo describes the installed plugin, or returns nil if it isn't
+// installed
+func (c *Command) installedInfo() (*InstalledInfo, error) {
+	pluginName, version, err := runner.ParsePluginRef(c.Plugin)
+	if err != nil {
+		return nil, err
+	}
+
+	bom, err := runner.LoadPluginBOM(c.Plugin)
+	if err != nil {
+		if errors.Is(err, runner.ErrPluginNotFound) || (version != "" && errors.Is(err, runner.ErrPluginVersionNotFound)) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	active, err := runner.ActiveVersion(pluginName)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := runner.InstalledVersions(pluginName)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &InstalledInfo{
+		ActiveVersion: active,
+		Versions:      versions,
+		BOM: BOMInfo{
+			Version:     bom.Version.String(),
+			Type:        string(bom.Type),
+			InstallType: installType(string(bom.Type)),
+			Source: SourceInfo{
+				Type:   bom.Source.Type,
+				Name:   bom.Source.Name,
+				URL:    runner.RedactURL(bom.Source.URL),
+				Mirror: runner.RedactURL(bom.Source.Mirror),
+			},
+			Interpreter: bom.Interpreter,
+			EntryPoint:  bom.EntryPoint,
+			Commands:    make([]CommandInfo, 0, len(bom.Provides)),
+		},
+	}
+
+	if bom.Integrity != nil {
+		algorithm := bom.Integrity.Algorithm
+		if algorithm == "" {
+			algorithm = runner.DefaultHashAlgorithm
+		}
+
+		info.BOM.Integrity = &IntegrityInfo{
+			Algorithm: algorithm,
+			Checksum:  bom.Integrity.Checksum,
+			Scope:     bom.Integrity.Scope,
+		}
+	}
+
+	if bom.Signer != nil {
+		info.BOM.Signer = &SignerInfo{
+			Identity: bom.Signer.Identity,
+			KeyID:    bom.Signer.KeyID,
+			Format:   bom.Signer.Format,
+		}
+	}
+
+	for _, p := range bom.Provides {
+		info.BOM.Commands = append(info.BOM.Commands, CommandInfo{
+			Name:        p.Name,
+			Version:     p.Version.String(),
+			Summary:     p.Summary,
+			Description: p.Description,
+		})
+	}
+
+	return info, nil
+}
+
+func (c *Command) registryInfo(registryName, pluginName string) (*RegistryInfo, error) {
+	reg := c.r.Get(registryName)
+	if reg == nil {
+		return nil, fmt.Errorf("registry %q not installed", registryName)
+	}
+
+	if err := reg.LazyLoad(); err != nil {
+		return nil, fmt.Errorf("could not load registry %q: %w", registryName, err)
+	}
+
+	p, ok := reg.FindPlugin(pluginName)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not in registry %q", runner.ErrPluginNotFound, pluginName, registryName)
+	}
+
+	info := &RegistryInfo{
+		Name:        registryName,
+		URL:         reg.URL.Redacted(),
+		Description: p.Description,
+		Homepage:    p.Homepage,
+		Versions:    make([]VersionInfo, 0, len(p.Versions)),
+	}
+
+	for _, a := range p.Authors {
+		author := a.Name
+		if a.Email != "" {
+			author = fmt.Sprintf("%s <%s>", a.Name, a.Email)
+		}
+		info.Authors = append(info.Authors, author)
+	}
+
+	for _, cmd := range p.Commands {
+		info.Commands = append(info.Commands, CommandInfo{Name: cmd.Name, Summary: cmd.Summary})
+	}
+
+	versions := make([]registry.PluginVersion, len(p.Versions))
+	copy(versions, p.Versions)
+	registry.SortVersions(versions)
+
+	for _, v := range versions {
+		_, compatible := registry.CompatibleExecutable(v)
+		vi := VersionInfo{
+			Version:    v.Version,
+			Compatible: compatible,
+			Yanked:     v.Yanked,
+			Deprecated: v.Deprecated,
+			Notice:     v.Notice,
+			Artifacts:  make([]ArtifactInfo, 0, len(v.DownloadInfo)),
+		}
+
+		for arch, exe := range v.DownloadInfo {
+			artifact := ArtifactInfo{
+				Architecture: string(arch),
+				Type:         string(exe.Type),
+				InstallType:  installType(string(exe.Type)),
+				Locator:      exe.Locator,
+				Checksums:    exe.AllChecksums(),
+			}
+
+			if exe.Info.Signature != nil && exe.Info.Signature.Locator != "" {
+				artifact.Signature = exe.Info.Signature.Format
+				if artifact.Signature == "" {
+					artifact.Signature = runner.SignatureMinisign
+				}
+			}
+
+			vi.Artifacts = append(vi.Artifacts, artifact)
+		}
+
+		sort.Slice(vi.Artifacts, func(i, j int) bool {
+			return vi.Artifacts[i].Architecture < vi.Artifacts[j].Architecture
+		})
+
+		info.Versions = append(info.Versions, vi)
+	}
+
+	for _, a := range reg.Advisories {
+		if a.Plugin != pluginName {
+			continue
+		}
+
+		info.Advisories = append(info.Advisories, AdvisoryInfo{
+			ID:       a.ID,
+			Severity: a.Severity,
+			Summary:  a.Summary,
+			Affected: a.Affected,
+			Fixed:    a.Fixed,
+			URL:      a.URL,
+		})
+	}
+
+	return info, nil
+}
+
+// installType is how a plugin of the given type is installed
+func installType(pluginType string) string {
+	switch strings.ToLower(pluginType) {
+	case string(runner.NodeJS):
+		return "npm"
+	case string(runner.Python):
+		return "pypi"
+	}
+
+	return pluginType
+}
@@ -0,0 +1,221 @@
+package info
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/olekukonko/tablewriter"
+)
+
+func writeJSON(ctx *kong.Context, info PluginInfo) error {
+	encoder := json.NewEncoder(ctx.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(info)
+}
+
+func writeTables(ctx *kong.Context, info PluginInfo) error {
+	if info.Installed != nil {
+		writeInstalled(ctx.Stdout, info.Name, info.Installed)
+	}
+
+	if info.Registry != nil {
+		if info.Installed != nil {
+			fmt.Fprintln(ctx.Stdout)
+		}
+		writeRegistry(ctx.Stdout, info.Name, info.Registry)
+	}
+
+	return nil
+}
+
+func writeInstalled(w io.Writer, name string, installed *InstalledInfo) {
+	bom := installed.BOM
+
+	fmt.Fprintf(w, "Installed %s@%s\n", name, bom.Version)
+
+	rows := [][]string{
+		{"Active Version", installed.ActiveVersion},
+		{"Installed Versions", strings.Join(installed.Versions, ", ")},
+		{"Type", typeLabel(bom.Type, bom.InstallType)},
+		{"Source", describeSource(bom.Source)},
+	}
+
+	if bom.Source.Mirror != "" {
+		rows = append(rows, []string{"Mirror", bom.Source.Mirror})
+	}
+
+	if bom.Integrity != nil {
+		integrity := fmt.Sprintf("%s:%s", bom.Integrity.Algorithm, bom.Integrity.Checksum)
+		if bom.Integrity.Scope != "" {
+			integrity += fmt.Sprintf(" (%s)", bom.Integrity.Scope)
+		}
+		rows = append(rows, []string{"Integrity", integrity})
+	} else {
+		rows = append(rows, []string{"Integrity", "not recorded"})
+	}
+
+	if bom.Signer != nil {
+		rows = append(rows, []string{"Signed By", fmt.Sprintf("%s (%s, %s)", bom.Signer.Identity, bom.Signer.KeyID, bom.Signer.Format)})
+	}
+
+	if bom.Interpreter != "" {
+		rows = append(rows, []string{"Interpreter", bom.Interpreter})
+	}
+
+	if bom.EntryPoint != "" {
+		rows = append(rows, []string{"Entry Point", bom.EntryPoint})
+	}
+
+	writeFields(w, rows)
+
+	if len(bom.Commands) > 0 {
+		table := newTable(w, "Command", "Version", "Summary")
+		for _, c := range bom.Commands {
+			table.Append([]string{c.Name, c.Version, c.Summary})
+		}
+		table.Render()
+	}
+}
+
+func writeRegistry(w io.Writer, name string, reg *RegistryInfo) {
+	fmt.Fprintf(w, "%s in registry %s\n", name, reg.Name)
+
+	rows := [][]string{
+		{"Registry URL", reg.URL},
+		{"Description", reg.Description},
+	}
+
+	if reg.Homepage != "" {
+		rows = append(rows, []string{"Homepage", reg.Homepage})
+	}
+
+	if len(reg.Authors) > 0 {
+		rows = append(rows, []string{"Authors", strings.Join(reg.Authors, ", ")})
+	}
+
+	if len(reg.Commands) > 0 {
+		commands := make([]string, 0, len(reg.Commands))
+		for _, c := range reg.Commands {
+			commands = append(commands, c.Name)
+		}
+		rows = append(rows, []string{"Commands", strings.Join(commands, ", ")})
+	}
+
+	writeFields(w, rows)
+
+	table := newTable(w, "Version", "Architecture", "Type", "Checksums", "Signature")
+	for _, v := range reg.Versions {
+		version := v.Version
+		switch {
+		case v.Yanked:
+			version += " (yanked)"
+		case v.Deprecated:
+			version += " (deprecated)"
+		}
+
+		if !v.Compatible {
+			version += " (not for this machine)"
+		}
+
+		for i, a := range v.Artifacts {
+			if i > 0 {
+				version = ""
+			}
+
+			table.Append([]string{version, a.Architecture, typeLabel(a.Type, a.InstallType), formatChecksums(a.Checksums), a.Signature})
+		}
+
+		if len(v.Artifacts) == 0 {
+			table.Append([]string{version, "", "", "", ""})
+		}
+	}
+	table.Render()
+
+	for _, v := range reg.Versions {
+		if v.Notice != "" && (v.Yanked || v.Deprecated) {
+			fmt.Fprintf(w, "%s: %s\n", v.Version, v.Notice)
+		}
+	}
+
+	if len(reg.Advisories) > 0 {
+		table = newTable(w, "Advisory", "Severity", "Affected", "Fixed In", "Summary")
+		for _, a := range reg.Advisories {
+			summary := a.Summary
+			if a.URL != "" {
+				summary += "\n" + a.URL
+			}
+
+			table.Append([]string{a.ID, a.Severity, a.Affected, a.Fixed, summary})
+		}
+		table.Render()
+	}
+}
+
+// typeLabel names a plugin type, with how it's installed when that isn't
+// obvious from the type
+func typeLabel(pluginType, installType string) string {
+	if pluginType == installType {
+		return pluginType
+	}
+
+	return fmt.Sprintf("%s (%s)", pluginType, installType)
+}
+
+func describeSource(s SourceInfo) string {
+	switch {
+	case s.Name != "" && s.URL != "":
+		return fmt.Sprintf("%s %s (%s)", s.Type, s.Name, s.URL)
+	case s.Name != "":
+		return fmt.Sprintf("%s %s", s.Type, s.Name)
+	case s.URL != "":
+		return fmt.Sprintf("%s %s", s.Type, s.URL)
+	}
+
+	return s.Type
+}
+
+func formatChecksums(checksums map[string]string) string {
+	algorithms := make([]string, 0, len(checksums))
+	for alg := range checksums {
+		algorithms = append(algorithms, alg)
+	}
+	sort.Strings(algorithms)
+
+	lines := make([]string, 0, len(algorithms))
+	for _, alg := range algorithms {
+		lines = append(lines, fmt.Sprintf("%s:%s", alg, checksums[alg]))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// writeFields prints name and value pairs as a borderless two column table
+func writeFields(w io.Writer, rows [][]string) {
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetBorder(false)
+	table.SetColumnSeparator("")
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.AppendBulk(rows)
+	table.Render()
+}
+
+func newTable(w io.Writer, headers ...string) *tablewriter.Table {
+	headerColors := make([]tablewriter.Colors, len(headers))
+	for i := range headers {
+		headerColors[i] = tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor}
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.SetAutoWrapText(false)
+	table.SetHeader(headers)
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetHeaderColor(headerColors...)
+	table.SetHeaderLine(true)
+
+	return table
+}
@@ -14,15 +14,23 @@ import (
 var ErrPluginAlreadyInstalled = errors.New("plugin already installed")
 
 type Command struct {
-	LocalFile     bool                       `short:"f" help:"If true, treat the plugin-name arg as the path to a local file"`
-	Registry      string                     `short:"r" default:"default" help:"The name of the registry to install the plugin from"`
-	PluginVersion string                     `help:"The version to install from the registry. Ignored if --local-file is set"`
-	PluginName    string                     `arg:"" help:"The name of the plugin to install if --registry is true, or the path to the executable plugin if --local-file is set"`
-	pluginHomeDir string                     `kong:"-"`
-	out           io.Writer                  `kong:"-"`
-	err           io.Writer                  `kong:"-"`
-	in            io.Reader                  `kong:"-"`
-	r             *registry.PluginRegistries `kong:"-"`
+	LocalFile        bool                       `short:"f" help:"If true, treat the plugin-name arg as the path to a local file"`
+	Registry         string                     `short:"r" help:"The name of the registry to install the plugin from. Defaults to the first registry by priority that has the plugin"`
+	PluginVersion    string                     `help:"The version or version constraint (e.g. \"~> 1.2\", \">=1.0,<2\" or \"^0.3\") to install from the registry. Ignored if --local-file is set"`
+	AllowPrerelease  bool                       `help:"If set, pre-release versions may satisfy --plugin-version"`
+	AllowYanked      bool                       `help:"If set, a version that was yanked from the registry may be installed"`
+	HashAlgorithm    string                     `default:"sha256" enum:"sha256,sha384,sha512,blake2b" help:"The hash algorithm used to record the plugin's integrity. One of sha256, sha384, sha512 or blake2b"`
+	RequireSignature bool                       `help:"If set, refuse to install a plugin unless its signature is verified with a key the registry trusts"`
+	PluginName       string                     `arg:"" help:"The name of the plugin to install, optionally as registry/plugin, or the path to the executable plugin if --local-file is set"`
+	pluginHomeDir    string                     `kong:"-"`
+	out              io.Writer                  `kong:"-"`
+	err              io.Writer                  `kong:"-"`
+	in               io.Reader                  `kong:"-"`
+	r                *registry.PluginRegistries `kong:"-"`
+
+	// Replace reinstalls a local file's version if it's already installed, for
+	// updates from a file rebuilt with the same version
+	Replace bool `kong:"-"`
 }
 
 func (i *Command) BeforeApply(ctx *kong.Context) error {
@@ -44,11 +52,64 @@ func (i *Command) Run() error {
 	}
 	i.pluginHomeDir = pluginHome
 
+	// commands that install on the user's behalf, like update and sync, may
+	// leave the algorithm unset
+	if i.HashAlgorithm == "" {
+		i.HashAlgorithm = runner.DefaultHashAlgorithm
+	}
+
+	if err = runner.ValidateHashAlgorithm(i.HashAlgorithm); err != nil {
+		return err
+	}
+
+	if !i.LocalFile {
+		if err = i.resolveRegistry(); err != nil {
+			return err
+		}
+	}
+
 	fmt.Fprintf(i.out, "Installing %s\n\n", i.PluginName)
 
 	if i.LocalFile {
+		if i.RequireSignature {
+			return fmt.Errorf("%w, but local files are not signed", runner.ErrSignatureRequired)
+		}
+
 		return i.localInstall()
 	}
 
 	return i.registryInstall()
 }
+
+// resolveRegistry picks the registry to install from. It's named with
+// --registry or as registry/plugin, or found by searching the registries in
+// priority order
+func (i *Command) resolveRegistry() error {
+	registryName, pluginName := registry.SplitPluginRef(i.PluginName)
+	if registryName != "" {
+		if i.Registry != "" && i.Registry != registryName {
+			return fmt.Errorf("%s names registry %q, but --registry is %q", i.PluginName, registryName, i.Registry)
+		}
+
+		i.Registry = registryName
+		i.PluginName = pluginName
+	}
+
+	if i.Registry != "" {
+		return nil
+	}
+
+	resolved, others, err := i.r.ResolveRegistry(i.PluginName)
+	if err != nil {
+		return err
+	}
+	i.Registry = resolved
+
+	if len(others) > 0 {
+		fmt.Fprintf(i.err, "%s is also in %s. Installing from %s, the highest priority registry\n", i.PluginName, strings.Join(others, ", "), resolved)
+	} else {
+		fmt.Fprintf(i.out, "Found %s in registry %s\n", i.PluginName, resolved)
+	}
+
+	return nil
+}
@@ -1,13 +1,12 @@
 package install
 
 import (
-	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 
-	"github.com/BurntSushi/toml"
 	"github.com/gideaworx/terraform-exporter/runner"
 )
 
@@ -17,18 +16,24 @@ func (i *Command) localInstall() error {
 		return err
 	}
 
-	pluginDir := filepath.Join(i.pluginHomeDir, filepath.Base(path))
-	if err := os.MkdirAll(pluginDir, 0777); err != nil {
+	pluginName := filepath.Base(path)
+	stagingDir, err := runner.StageInstall(pluginName)
+	if err != nil {
 		return err
 	}
+	defer runner.DiscardStagedInstall(stagingDir)
 
-	targetFile, err := os.OpenFile(filepath.Join(pluginDir, "export-plugin"), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0777)
+	stagedExecutable := filepath.Join(stagingDir, "export-plugin")
+	targetFile, err := os.OpenFile(stagedExecutable, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0777)
 	if err != nil {
 		return err
 	}
 	defer targetFile.Close()
 
-	hasher := sha256.New()
+	hasher, err := runner.NewHash(i.HashAlgorithm)
+	if err != nil {
+		return err
+	}
 	writer := io.MultiWriter(targetFile, hasher)
 
 	contents, err := os.Open(path)
@@ -44,16 +49,16 @@ func (i *Command) localInstall() error {
 
 	integrity := &runner.PluginIntegrity{
 		Checksum:  hex.EncodeToString(hasher.Sum(nil)),
-		Algorithm: "sha256",
+		Algorithm: i.HashAlgorithm,
 	}
 
-	info, err := runner.LoadPluginInfo(filepath.Base(path), integrity)
+	info, err := runner.LoadPluginInfo(stagedExecutable, integrity)
 	if err != nil {
 		return err
 	}
 
 	bom := runner.BillOfMaterials{
-		Name: filepath.Base(path),
+		Name: pluginName,
 		Type: runner.Native,
 		Source: runner.PluginSource{
 			Type: "local-file",
@@ -64,11 +69,13 @@ func (i *Command) localInstall() error {
 		Provides:  info.Provides,
 	}
 
-	bomFile, err := os.Create(filepath.Join(pluginDir, "export-plugin.bom"))
-	if err != nil {
+	if err = runner.WriteBOM(stagingDir, bom); err != nil {
 		return err
 	}
-	defer bomFile.Close()
 
-	return toml.NewEncoder(bomFile).Encode(bom)
+	if _, err = runner.LoadPluginBOM(runner.PluginRef(pluginName, info.Version.String())); err == nil && !i.Replace {
+		return fmt.Errorf("%w: %s", ErrPluginAlreadyInstalled, runner.PluginRef(pluginName, info.Version.String()))
+	}
+
+	return runner.CommitStagedVersion(pluginName, info.Version.String(), stagingDir)
 }
@@ -0,0 +1,94 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	localreg "github.com/gideaworx/terraform-exporter/registry"
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+const venvDirName = "venv"
+
+// installPyPI installs a python plugin into its own virtual environment inside
+// the plugin directory, so it never touches the system python's packages
+func (i *Command) installPyPI(pluginDir string, exe localreg.PluginExecutable, reg *localreg.PluginRegistry, version string) (runner.BillOfMaterials, error) {
+	var (
+		pythonPath string
+		err        error
+	)
+
+	if pythonPath, err = exec.LookPath("python3"); err != nil {
+		if pythonPath, err = exec.LookPath("python"); err != nil {
+			return runner.BillOfMaterials{}, errors.New(`could not find "python" or "python3" on the system PATH`)
+		}
+	}
+
+	venvDir := filepath.Join(pluginDir, venvDirName)
+	venvCmd := exec.Command(pythonPath, "-m", "venv", venvDir)
+	venvCmd.Stdout = i.out
+	venvCmd.Stderr = i.err
+	if err = venvCmd.Run(); err != nil {
+		return runner.BillOfMaterials{}, fmt.Errorf("could not create a python virtual environment with %s, make sure the venv module is installed (e.g. the python3-venv package): %w", pythonPath, err)
+	}
+
+	interpreter, entryPoint := venvPaths()
+	pipArgs := append([]string{"-m", "pip", "install", fmt.Sprintf("%s==%s", exe.Locator, version)}, exe.Info.ExtraArgs...)
+
+	pipInstaller := exec.Command(filepath.Join(pluginDir, interpreter), pipArgs...)
+	pipInstaller.Stdout = i.out
+	pipInstaller.Stderr = i.err
+	if err := pipInstaller.Run(); err != nil {
+		return runner.BillOfMaterials{}, err
+	}
+
+	if _, err = os.Stat(filepath.Join(pluginDir, entryPoint)); err != nil {
+		return runner.BillOfMaterials{}, fmt.Errorf("package %s does not provide an export-plugin entry point: %w", exe.Locator, err)
+	}
+
+	checksum, err := runner.TreeHash(pluginDir, i.HashAlgorithm)
+	if err != nil {
+		return runner.BillOfMaterials{}, err
+	}
+
+	bom := runner.BillOfMaterials{
+		Name: i.PluginName,
+		Type: runner.Python,
+		Source: runner.PluginSource{
+			Type: "registry",
+			Name: i.Registry,
+			URL:  reg.URL.String(),
+		},
+		Integrity: &runner.PluginIntegrity{
+			Checksum:  checksum,
+			Algorithm: i.HashAlgorithm,
+			Scope:     runner.IntegrityTree,
+		},
+		Interpreter: interpreter,
+		EntryPoint:  entryPoint,
+	}
+
+	info, err := runner.LoadPluginInfoFromDir(pluginDir, bom)
+	if err != nil {
+		return runner.BillOfMaterials{}, err
+	}
+
+	bom.Version = info.Version
+	bom.Provides = info.Provides
+
+	return bom, nil
+}
+
+// venvPaths returns the interpreter and entry point script of a virtual
+// environment, relative to the plugin directory
+func venvPaths() (string, string) {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(venvDirName, "Scripts", "python.exe"), filepath.Join(venvDirName, "Scripts", "export-plugin.exe")
+	}
+
+	return filepath.Join(venvDirName, "bin", "python"), filepath.Join(venvDirName, "bin", "export-plugin")
+}
@@ -1,8 +1,6 @@
 package install
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -10,13 +8,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"sort"
-	"strings"
 
-	"github.com/BurntSushi/toml"
-	"github.com/blang/semver/v4"
 	plugin "github.com/gideaworx/terraform-exporter-plugin-go"
-	"github.com/gideaworx/terraform-exporter-plugin-registry/registry"
 	localreg "github.com/gideaworx/terraform-exporter/registry"
 	"github.com/gideaworx/terraform-exporter/runner"
 )
@@ -24,67 +17,64 @@ import (
 func (i *Command) registryInstall() error {
 	reg := i.r.Get(i.Registry)
 	if reg == nil {
-		return fmt.Errorf("no plugin %q found", i.Registry)
+		return fmt.Errorf("no registry %q found", i.Registry)
 	}
 
 	if err := reg.LazyLoad(); err != nil {
 		return err
 	}
 
-	var plugin registry.Plugin
-	var version registry.PluginVersion
-	found := false
-	for _, p := range reg.Plugins {
-		if p.Name == i.PluginName {
-			plugin = p
-			if strings.EqualFold(strings.TrimSpace(i.PluginVersion), "") {
-				found = true
-				break
-			}
-
-			for _, v := range p.Versions {
-				if v.Version == i.PluginVersion {
-					version = v
-					found = true
-					break
-				}
-			}
-		}
+	constraint, err := localreg.ParseConstraint(i.PluginVersion)
+	if err != nil {
+		return err
 	}
 
-	if !found {
-		return fmt.Errorf("could not find version %q of plugin %q in registry %q", i.PluginVersion, i.PluginName, i.Registry)
+	plugin, ok := reg.FindPlugin(i.PluginName)
+	if !ok {
+		return fmt.Errorf("could not find plugin %q in registry %q", i.PluginName, i.Registry)
 	}
 
-	if strings.EqualFold(strings.TrimSpace(i.PluginVersion), "") {
-		sort.Slice(plugin.Versions, func(i, j int) bool {
-			si, _ := semver.ParseTolerant(plugin.Versions[i].Version)
-			sj, _ := semver.ParseTolerant(plugin.Versions[j].Version)
-
-			return si.GT(sj)
-		})
+	version, err := localreg.ResolveVersion(plugin, constraint, i.AllowPrerelease, i.AllowYanked)
+	if errors.Is(err, localreg.ErrVersionYanked) {
+		return fmt.Errorf("%w. Install it anyway with --allow-yanked", err)
+	}
 
-		version = plugin.Versions[0]
+	if err != nil {
+		return fmt.Errorf("%w in registry %q", err, i.Registry)
 	}
 
-	exe := i.getPluginExecutable(version)
-	if exe.Locator == "" {
+	i.warnAbout(reg, version)
+
+	exe, ok := localreg.CompatibleExecutable(version)
+	if !ok || exe.Locator == "" {
 		return fmt.Errorf("plugin %s, version %s is not compatible with architecture %s/%s", plugin.Name, version.Version, runtime.GOOS, runtime.GOARCH)
 	}
 
-	pluginDir := filepath.Join(i.pluginHomeDir, i.PluginName)
-	if err := os.MkdirAll(pluginDir, 0777); err != nil {
+	if i.RequireSignature && exe.Type != localreg.Native {
+		return fmt.Errorf("%w, but only native plugins can be signed and %s %s is a %s plugin", runner.ErrSignatureRequired, plugin.Name, version.Version, exe.Type)
+	}
+
+	ref := runner.PluginRef(i.PluginName, version.Version)
+	if _, err := runner.LoadPluginBOM(ref); err == nil {
+		return fmt.Errorf("%w: %s. Run \"use %s\" to make it the active version", ErrPluginAlreadyInstalled, ref, ref)
+	}
+
+	// everything is installed into a staging directory first, so a failed
+	// download or handshake never leaves a half-installed plugin behind
+	pluginDir, err := runner.StageInstall(i.PluginName)
+	if err != nil {
 		return err
 	}
+	defer runner.DiscardStagedInstall(pluginDir)
 
-	var installer func(string, registry.PluginExecutable, *localreg.PluginRegistry, string) (runner.BillOfMaterials, error)
+	var installer func(string, localreg.PluginExecutable, *localreg.PluginRegistry, string) (runner.BillOfMaterials, error)
 
 	switch exe.Type {
-	case registry.Native:
+	case localreg.Native:
 		installer = i.installNative
-	case registry.NodeJS:
+	case localreg.NodeJS:
 		installer = i.installNPM
-	case registry.Python:
+	case localreg.Python:
 		installer = i.installPyPI
 	// case registry.Java:
 	// 	return i.installMaven(exe, reg, version.Version)
@@ -97,149 +87,83 @@ func (i *Command) registryInstall() error {
 		return err
 	}
 
-	bomFile, err := os.Create(filepath.Join(pluginDir, "export-plugin.bom"))
-	if err != nil {
+	if err = runner.WriteBOM(pluginDir, bom); err != nil {
 		return err
 	}
-	defer bomFile.Close()
 
-	return toml.NewEncoder(bomFile).Encode(bom)
+	return runner.CommitStagedVersion(i.PluginName, version.Version, pluginDir)
 }
 
-func (i *Command) getPluginExecutable(v registry.PluginVersion) registry.PluginExecutable {
-	targetArch := registry.TargetArchitecture(fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH))
-	if exe, ok := v.DownloadInfo[targetArch]; ok {
-		return exe
-	}
-
-	if maExe, ok := v.DownloadInfo[registry.MultiArch]; ok {
-		return maExe
-	}
-
-	// if we're on darwin/arm64, we can try darwin/amd64 and let Rosetta take the wheel
-	if runtime.GOOS == "darwin" && runtime.GOARCH == "arm64" {
-		return v.DownloadInfo[registry.DarwinAmd64]
-	}
-
-	return registry.PluginExecutable{}
-}
-
-func (i *Command) installNative(pluginDir string, exe registry.PluginExecutable, reg *localreg.PluginRegistry, version string) (runner.BillOfMaterials, error) {
+func (i *Command) installNative(pluginDir string, exe localreg.PluginExecutable, reg *localreg.PluginRegistry, version string) (runner.BillOfMaterials, error) {
 	targetFile, err := os.OpenFile(filepath.Join(pluginDir, "export-plugin"), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0777)
 	if err != nil {
 		return runner.BillOfMaterials{}, err
 	}
 	defer targetFile.Close()
 
-	hasher := sha256.New()
-	writer := io.MultiWriter(targetFile, hasher)
-
-	resp, err := runner.GetHTTPClient().Get(exe.Locator)
-	if err != nil {
-		return runner.BillOfMaterials{}, err
+	checksums := exe.AllChecksums()
+	algorithms := []string{i.HashAlgorithm}
+	for alg := range checksums {
+		algorithms = append(algorithms, alg)
 	}
-	defer resp.Body.Close()
 
-	if _, err = io.Copy(writer, resp.Body); err != nil {
-		return runner.BillOfMaterials{}, err
-	}
-	targetFile.Close()
-
-	calculatedSHASum := hex.EncodeToString(hasher.Sum(nil))
-	if exe.Info.Checksum != "" && exe.Info.Checksum != calculatedSHASum {
-		return runner.BillOfMaterials{}, fmt.Errorf("calculated sha256 checksum %q does not match provided checksum %q", calculatedSHASum, exe.Info.Checksum)
-	}
-
-	integrity := &runner.PluginIntegrity{
-		Checksum:  calculatedSHASum,
-		Algorithm: "sha256",
+	hasher, err := runner.NewMultiHash(algorithms...)
+	if err != nil {
+		return runner.BillOfMaterials{}, fmt.Errorf("registry %q lists a checksum for %s %s that can't be verified: %w", i.Registry, i.PluginName, version, err)
 	}
+	writer := io.MultiWriter(targetFile, hasher)
 
-	info, err := runner.LoadPluginInfo(i.PluginName, integrity)
+	body, downloaded, err := reg.OpenArtifact(exe.Locator)
 	if err != nil {
 		return runner.BillOfMaterials{}, err
 	}
+	defer body.Close()
 
-	bom := runner.BillOfMaterials{
-		Name:    i.PluginName,
-		Version: plugin.FromString(version),
-		Type:    runner.Native,
-		Source: runner.PluginSource{
-			Type: "registry",
-			Name: i.Registry,
-			URL:  reg.URL.String(),
-		},
-		Integrity: integrity,
-		Provides:  info.Provides,
-	}
-
-	return bom, nil
-}
-
-func (i *Command) installPyPI(pluginDir string, exe registry.PluginExecutable, reg *localreg.PluginRegistry, version string) (runner.BillOfMaterials, error) {
-	var (
-		pythonPath string
-		err        error
-	)
-
-	if pythonPath, err = exec.LookPath("python3"); err != nil {
-		if pythonPath, err = exec.LookPath("python"); err != nil {
-			return runner.BillOfMaterials{}, errors.New(`could not find "python" or "python3" on the system PATH`)
-		}
-	}
-
-	ensurePipCmd := exec.Command(pythonPath, "-m", "ensurepip")
-	ensurePipCmd.Stdout = i.out
-	ensurePipCmd.Stderr = i.err
-	if err = ensurePipCmd.Run(); err != nil {
-		return runner.BillOfMaterials{}, fmt.Errorf("could not ensure pip is installed: %w", err)
-	}
-
-	pipArgs := append([]string{"-m", "pip", "install", "--target", pluginDir, fmt.Sprintf("%s==%s", exe.Locator, version)}, exe.Info.ExtraArgs...)
-
-	pipInstaller := exec.Command(pythonPath, pipArgs...)
-	pipInstaller.Stdout = i.out
-	pipInstaller.Stderr = i.err
-	if err := pipInstaller.Run(); err != nil {
+	if _, err = io.Copy(writer, body); err != nil {
 		return runner.BillOfMaterials{}, err
 	}
+	targetFile.Close()
 
-	if err = os.Symlink(filepath.Join(pluginDir, "bin", "export-plugin"), filepath.Join(pluginDir, "export-plugin")); err != nil {
+	if err = hasher.Verify(checksums); err != nil {
 		return runner.BillOfMaterials{}, err
 	}
 
-	hasher := sha256.New()
-	contents, err := os.ReadFile(filepath.Join(pluginDir, "bin", "export-plugin"))
+	signer, err := i.verifySignature(filepath.Join(pluginDir, "export-plugin"), exe, reg)
 	if err != nil {
 		return runner.BillOfMaterials{}, err
 	}
 
-	checksum := hasher.Sum(contents)
 	integrity := &runner.PluginIntegrity{
-		Checksum:  hex.EncodeToString(checksum),
-		Algorithm: "sha256",
+		Checksum:  hasher.Sum(i.HashAlgorithm),
+		Algorithm: i.HashAlgorithm,
 	}
 
-	info, err := runner.LoadPluginInfo(i.PluginName, integrity)
+	info, err := runner.LoadPluginInfo(filepath.Join(pluginDir, "export-plugin"), integrity)
 	if err != nil {
 		return runner.BillOfMaterials{}, err
 	}
 
-	return runner.BillOfMaterials{
+	bom := runner.BillOfMaterials{
 		Name:    i.PluginName,
-		Version: info.Version,
-		Type:    runner.Python,
+		Version: plugin.FromString(version),
+		Type:    runner.Native,
 		Source: runner.PluginSource{
 			Type: "registry",
 			Name: i.Registry,
 			URL:  reg.URL.String(),
+			// a mirror is only recorded when the registry itself couldn't
+			// serve the plugin
+			Mirror: reg.MirrorOf(downloaded),
 		},
 		Integrity: integrity,
+		Signer:    signer,
 		Provides:  info.Provides,
-	}, nil
+	}
+
+	return bom, nil
 }
 
-func (i *Command) installNPM(pluginDir string, exe registry.PluginExecutable, reg *localreg.PluginRegistry, version string) (runner.BillOfMaterials, error) {
+func (i *Command) installNPM(pluginDir string, exe localreg.PluginExecutable, reg *localreg.PluginRegistry, version string) (runner.BillOfMaterials, error) {
 	var (
 		npmPath string
 		bom     runner.BillOfMaterials
@@ -268,37 +192,74 @@ func (i *Command) installNPM(pluginDir string, exe registry.PluginExecutable, re
 		return bom, err
 	}
 
-	if err = os.Symlink(filepath.Join(nmDir, ".bin", "export-plugin"), filepath.Join(pluginDir, "export-plugin")); err != nil {
+	if err = os.Symlink(filepath.Join("node_modules", ".bin", "export-plugin"), filepath.Join(pluginDir, "export-plugin")); err != nil {
 		return bom, err
 	}
 
-	hasher := sha256.New()
-	contents, err := os.ReadFile(filepath.Join(nmDir, ".bin", "export-plugin"))
-	if err != nil {
-		return runner.BillOfMaterials{}, err
-	}
-
-	checksum := hasher.Sum(contents)
-	integrity := &runner.PluginIntegrity{
-		Checksum:  hex.EncodeToString(checksum),
-		Algorithm: "sha256",
+	if _, err = os.Stat(filepath.Join(nmDir, ".bin", "export-plugin")); err != nil {
+		return bom, fmt.Errorf("package %s does not provide an export-plugin executable: %w", exe.Locator, err)
 	}
 
-	info, err := runner.LoadPluginInfo(i.PluginName, integrity)
+	checksum, err := runner.TreeHash(pluginDir, i.HashAlgorithm)
 	if err != nil {
-		return runner.BillOfMaterials{}, err
+		return bom, err
 	}
 
-	return runner.BillOfMaterials{
-		Name:    i.PluginName,
-		Version: info.Version,
-		Type:    runner.NodeJS,
+	bom = runner.BillOfMaterials{
+		Name: i.PluginName,
+		Type: runner.NodeJS,
 		Source: runner.PluginSource{
 			Type: "registry",
 			Name: i.Registry,
 			URL:  reg.URL.String(),
 		},
-		Integrity: integrity,
-		Provides:  info.Provides,
-	}, nil
+		Integrity: &runner.PluginIntegrity{
+			Checksum:  checksum,
+			Algorithm: i.HashAlgorithm,
+			Scope:     runner.IntegrityTree,
+		},
+	}
+
+	info, err := runner.LoadPluginInfoFromDir(pluginDir, bom)
+	if err != nil {
+		return runner.BillOfMaterials{}, err
+	}
+
+	bom.Version = info.Version
+	bom.Provides = info.Provides
+
+	return bom, nil
+}
+
+// warnAbout tells the user when the version being installed was yanked or
+// deprecated, or has security advisories
+func (i *Command) warnAbout(reg *localreg.PluginRegistry, version localreg.PluginVersion) {
+	ref := runner.PluginRef(i.PluginName, version.Version)
+
+	notice := ""
+	if version.Notice != "" {
+		notice = ": " + version.Notice
+	}
+
+	switch {
+	case version.Yanked:
+		fmt.Fprintf(i.err, "warning: %s was yanked from registry %q%s\n", ref, i.Registry, notice)
+	case version.Deprecated:
+		fmt.Fprintf(i.err, "warning: %s is deprecated%s\n", ref, notice)
+	}
+
+	// a broken advisory in the index shouldn't block installing anything
+	advisories, err := reg.AdvisoriesFor(i.PluginName, version.Version)
+	if err != nil {
+		fmt.Fprintf(i.err, "warning: registry %q: %v\n", i.Registry, err)
+	}
+
+	for _, a := range advisories {
+		fixed := "There is no fixed version yet"
+		if a.Fixed != "" {
+			fixed = fmt.Sprintf("Fixed in %s", a.Fixed)
+		}
+
+		fmt.Fprintf(i.err, "warning: %s is affected by %s (%s): %s. %s\n", ref, a.ID, a.Severity, a.Summary, fixed)
+	}
 }
@@ -0,0 +1,92 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+
+	localreg "github.com/gideaworx/terraform-exporter/registry"
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+// verifySignature checks the downloaded executable against the signature the
+// registry publishes for it. A registry with trusted keys must sign every
+// native plugin, otherwise removing the signature from the index would skip
+// verification. For registries without trusted keys, an unsigned plugin is
+// only an error with --require-signature
+func (i *Command) verifySignature(path string, exe localreg.PluginExecutable, reg *localreg.PluginRegistry) (*runner.PluginSigner, error) {
+	sig := exe.Info.Signature
+	if sig == nil || sig.Locator == "" {
+		if len(reg.TrustedKeys) > 0 {
+			return nil, fmt.Errorf("%w, registry %q has trusted keys but publishes no signature for %s", runner.ErrSignatureRequired, i.Registry, i.PluginName)
+		}
+
+		if i.RequireSignature {
+			return nil, fmt.Errorf("%w, but registry %q publishes no signature for %s", runner.ErrSignatureRequired, i.Registry, i.PluginName)
+		}
+
+		return nil, nil
+	}
+
+	if len(reg.TrustedKeys) == 0 {
+		if i.RequireSignature {
+			return nil, fmt.Errorf("%w, but registry %q has no trusted keys. Add one with \"registry trust-key\"", runner.ErrSignatureRequired, i.Registry)
+		}
+
+		fmt.Fprintf(i.err, "warning: %s is signed, but registry %q has no trusted keys to verify the signature with\n", i.PluginName, i.Registry)
+		return nil, nil
+	}
+
+	exeLocator, err := reg.ResolveLocator(exe.Locator)
+	if err != nil {
+		return nil, err
+	}
+
+	// signature locators are relative to the executable they sign
+	sigLocator, err := url.Parse(sig.Locator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature locator %q: %w", sig.Locator, err)
+	}
+
+	signature, err := download(reg, exeLocator.ResolveReference(sigLocator).String())
+	if err != nil {
+		return nil, fmt.Errorf("could not download the signature of %s: %w", i.PluginName, err)
+	}
+
+	artifact, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := runner.VerifySignature(sig.Format, artifact, signature, reg.TrustedKeys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", i.PluginName, err)
+	}
+
+	fmt.Fprintf(i.out, "Verified signature of %s by %s\n", i.PluginName, signer)
+	return signer, nil
+}
+
+// signatures are tiny, anything bigger than this isn't one
+const maxSignatureSize = 64 * 1024
+
+func download(reg *localreg.PluginRegistry, locator string) ([]byte, error) {
+	body, _, err := reg.OpenArtifact(locator)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	contents, err := io.ReadAll(io.LimitReader(body, maxSignatureSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contents) > maxSignatureSize {
+		return nil, errors.New("signature is too large")
+	}
+
+	return contents, nil
+}
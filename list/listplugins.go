@@ -29,15 +29,26 @@ func (l *ListPluginsCommand) Run(context *kong.Context) error {
 			cmdNames = append(cmdNames, c.Name)
 		}
 		sort.Strings(cmdNames)
-		tableData = append(tableData, []string{p.Name, p.Version.String(), strings.Join(cmdNames, ", ")})
+
+		versions, err := runner.InstalledVersions(p.Name)
+		if err != nil {
+			return err
+		}
+
+		if len(versions) == 0 {
+			versions = []string{p.Version.String()}
+		}
+
+		tableData = append(tableData, []string{p.Name, p.Version.String(), strings.Join(versions, ", "), strings.Join(cmdNames, ", ")})
 	}
 
 	table := tablewriter.NewWriter(context.Stdout)
-	table.SetHeader([]string{"Plugin", "Version", "Provided Exporters"})
+	table.SetHeader([]string{"Plugin", "Active Version", "Installed Versions", "Provided Exporters"})
 	table.SetHeaderColor(
 		tablewriter.Colors{tablewriter.Bold},
 		tablewriter.Colors{tablewriter.Bold},
 		tablewriter.Colors{tablewriter.Bold},
+		tablewriter.Colors{tablewriter.Bold},
 	)
 	table.SetHeaderLine(true)
 	table.SetBorder(true)
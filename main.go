@@ -3,15 +3,25 @@ package main
 import (
 	"io"
 	"os"
+	"time"
 
 	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter/audit"
 	"github.com/gideaworx/terraform-exporter/export"
 	"github.com/gideaworx/terraform-exporter/help"
+	"github.com/gideaworx/terraform-exporter/info"
 	"github.com/gideaworx/terraform-exporter/install"
 	"github.com/gideaworx/terraform-exporter/list"
+	"github.com/gideaworx/terraform-exporter/pluginsync"
 	"github.com/gideaworx/terraform-exporter/registry"
+	"github.com/gideaworx/terraform-exporter/rehash"
 	"github.com/gideaworx/terraform-exporter/remove"
+	"github.com/gideaworx/terraform-exporter/rollback"
+	"github.com/gideaworx/terraform-exporter/runner"
+	"github.com/gideaworx/terraform-exporter/sbom"
+	"github.com/gideaworx/terraform-exporter/search"
 	"github.com/gideaworx/terraform-exporter/update"
+	"github.com/gideaworx/terraform-exporter/use"
 )
 
 var Version = "0.0.0-local"
@@ -21,16 +31,33 @@ var cli struct {
 	InstallPlugin *install.Command           `cmd:"" aliases:"install,i" help:"Install a plugin"`
 	RemovePlugin  *remove.Command            `cmd:"" aliases:"remove,rm" help:"Uninstall a plugin"`
 	UpdatePlugin  *update.Command            `cmd:"" aliases:"update,up" help:"Update a plugin"`
+	Outdated      *update.OutdatedCommand    `cmd:"" help:"List installed plugins that have newer versions in their registry"`
+	Audit         *audit.Command             `cmd:"" help:"Check installed plugins for security advisories and yanked versions"`
+	Sbom          *sbom.Command              `cmd:"" help:"Export a software bill of materials of the installed plugins, in CycloneDX or SPDX format"`
+	Search        *search.Command            `cmd:"" help:"Search every registry for plugins"`
+	Use           *use.Command               `cmd:"" help:"Switch the active version of an installed plugin"`
+	Sync          *pluginsync.Command        `cmd:"" help:"Install, update and remove plugins to match a plugins.yaml file"`
+	Rollback      *rollback.Command          `cmd:"" help:"Switch a plugin back to the version that was active before the last install, update or use"`
+	Rehash        *rehash.Command            `cmd:"" help:"Verify installed plugins and record their integrity with a different hash algorithm"`
+	Info          *info.Command              `cmd:"" help:"Show the versions, architectures and checksums of a plugin, and the bill of materials of its install"`
 	Help          *help.Command              `cmd:"" aliases:"h" help:"Show help for a plugin's exporter command"`
 	ListPlugins   *list.ListPluginsCommand   `cmd:"" aliases:"ls" help:"List installed plugins"`
 	ListCommands  *list.ListExportersCommand `cmd:"" aliases:"lc" help:"List commands provided by installed plugins"`
 	Registry      *registry.Command          `cmd:"" help:"Work with plugin registries"`
 	Version       kong.VersionFlag           `short:"v" optional:"true" help:"Show the version and quit"`
+	Offline       bool                       `help:"Only use cached registry indexes, never download them"`
+	CacheTTL      time.Duration              `default:"1h" env:"TFE_REGISTRY_CACHE_TTL" help:"How long a cached registry index is used before it's revalidated"`
 }
 
 func main() {
 	ctx := kong.Parse(&cli, kong.BindTo(os.Stdin, (*io.Reader)(nil)), kong.Vars{
-		"version": Version,
+		"version":   Version,
+		"lock_file": runner.DEFAULT_LOCK_FILE,
+	})
+
+	registry.ConfigureCache(registry.CacheOptions{
+		TTL:     cli.CacheTTL,
+		Offline: cli.Offline,
 	})
 
 	ctx.FatalIfErrorf(ctx.Run())
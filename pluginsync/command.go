@@ -0,0 +1,175 @@
+package pluginsync
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter/install"
+	"github.com/gideaworx/terraform-exporter/registry"
+	"github.com/gideaworx/terraform-exporter/remove"
+	"github.com/gideaworx/terraform-exporter/runner"
+	"github.com/gideaworx/terraform-exporter/update"
+	"github.com/olekukonko/tablewriter"
+)
+
+type Command struct {
+	File             string                     `arg:"" optional:"" type:"path" default:"plugins.yaml" help:"The file declaring the plugins that should be installed"`
+	NonInteractive   bool                       `short:"y" default:"false" help:"Apply the changes without asking first"`
+	DryRun           bool                       `short:"n" default:"false" help:"Only show the changes that would be made"`
+	NoPrune          bool                       `default:"false" help:"Do not remove installed plugins that are missing from the file"`
+	RequireSignature bool                       `help:"If set, every plugin installed from a registry must have a verified signature"`
+	ctx              *kong.Context              `kong:"-"`
+	in               io.Reader                  `kong:"-"`
+	r                *registry.PluginRegistries `kong:"-"`
+}
+
+func (c *Command) BeforeApply(ctx *kong.Context, stdin io.Reader) error {
+	c.ctx = ctx
+	c.in = stdin
+
+	var err error
+	c.r, err = registry.LoadFromDisk()
+
+	return err
+}
+
+func (c *Command) Run() error {
+	declared, err := loadPluginsFile(c.File, c.r.DefaultName())
+	if err != nil {
+		return err
+	}
+
+	changes, err := c.plan(declared)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, ch := range changes {
+		if ch.action != actionNone {
+			pending++
+		}
+	}
+
+	c.printPlan(changes)
+	if pending == 0 {
+		fmt.Fprintf(c.ctx.Stdout, "\nInstalled plugins already match %s\n", c.File)
+		return nil
+	}
+
+	if c.DryRun {
+		return nil
+	}
+
+	if !c.NonInteractive {
+		scanner := bufio.NewScanner(c.in)
+
+		fmt.Fprintf(c.ctx.Stdout, "\nApply %d changes? type 'y' or 'yes' (case insensitive): ", pending)
+		scanner.Scan()
+		input := strings.TrimSpace(scanner.Text())
+
+		if !strings.EqualFold("y", input) && !strings.EqualFold("yes", input) {
+			fmt.Fprintf(c.ctx.Stdout, "\nYou answered %q, bailing out...\n\n", input)
+			return nil
+		}
+	}
+
+	failed := 0
+	for _, ch := range changes {
+		if ch.action == actionNone {
+			continue
+		}
+
+		if err := c.apply(ch); err != nil {
+			failed++
+			fmt.Fprintf(c.ctx.Stderr, "could not %s %s: %v\n", ch.action, ch.plugin.Name, err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d changes failed", failed, pending)
+	}
+
+	return nil
+}
+
+func (c *Command) printPlan(changes []change) {
+	table := tablewriter.NewWriter(c.ctx.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Plugin", "Action", "From", "To", "Notes"})
+	table.SetHeaderLine(true)
+	table.SetBorder(true)
+
+	for _, ch := range changes {
+		table.Append([]string{ch.plugin.Name, string(ch.action), ch.from, ch.to, ch.comment})
+	}
+
+	table.Render()
+}
+
+func (c *Command) apply(ch change) error {
+	p := ch.plugin
+	switch ch.action {
+	case actionActivate:
+		if err := runner.SetActiveVersion(p.Name, ch.to); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.ctx.Stdout, "Now using %s\n", runner.PluginRef(p.Name, ch.to))
+		return nil
+	case actionRemove:
+		r := &remove.Command{
+			PluginName:     p.Name,
+			NonInteractive: true,
+		}
+		if err := r.BeforeApply(c.ctx, c.in); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(c.ctx.Stdout, "Removing %s\n", p.Name)
+		return r.Run()
+	case actionInstall:
+		i := &install.Command{
+			LocalFile:        p.Source == sourceLocalFile,
+			Registry:         p.Registry,
+			PluginVersion:    ch.to,
+			AllowPrerelease:  p.AllowPrerelease,
+			AllowYanked:      p.AllowYanked,
+			RequireSignature: p.RequireSignature || c.RequireSignature,
+			PluginName:       p.Name,
+		}
+		if i.LocalFile {
+			i.PluginName = p.Path
+		}
+
+		if err := i.BeforeApply(c.ctx); err != nil {
+			return err
+		}
+
+		return i.Run()
+	case actionUpdate:
+		u := &update.Command{
+			LocalFile:        p.Source == sourceLocalFile,
+			Registry:         p.Registry,
+			PluginVersion:    ch.to,
+			AllowPrerelease:  p.AllowPrerelease,
+			AllowYanked:      p.AllowYanked,
+			AllowDowngrades:  true,
+			RequireSignature: p.RequireSignature || c.RequireSignature,
+			PluginName:       p.Name,
+		}
+		if u.LocalFile {
+			u.PluginName = p.Path
+		}
+
+		if err := u.BeforeApply(c.ctx); err != nil {
+			return err
+		}
+
+		return u.Run()
+	}
+
+	return nil
+}
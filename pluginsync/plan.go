@@ -0,0 +1,249 @@
+package pluginsync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/gideaworx/terraform-exporter/registry"
+	"github.com/gideaworx/terraform-exporter/runner"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	sourceRegistry  = "registry"
+	sourceLocalFile = "local-file"
+)
+
+type action string
+
+const (
+	actionNone     action = "none"
+	actionInstall  action = "install"
+	actionUpdate   action = "update"
+	actionActivate action = "activate"
+	actionRemove   action = "remove"
+)
+
+// DeclaredPlugin is a plugin entry in a plugins.yaml file
+type DeclaredPlugin struct {
+	Name             string `yaml:"name"`
+	Source           string `yaml:"source,omitempty"`
+	Registry         string `yaml:"registry,omitempty"`
+	Version          string `yaml:"version,omitempty"`
+	Path             string `yaml:"path,omitempty"`
+	AllowPrerelease  bool   `yaml:"allow-prerelease,omitempty"`
+	AllowYanked      bool   `yaml:"allow-yanked,omitempty"`
+	RequireSignature bool   `yaml:"require-signature,omitempty"`
+}
+
+type pluginsFile struct {
+	Plugins []DeclaredPlugin `yaml:"plugins"`
+}
+
+type change struct {
+	plugin  DeclaredPlugin
+	action  action
+	from    string
+	to      string
+	comment string
+}
+
+func loadPluginsFile(path, defaultRegistry string) ([]DeclaredPlugin, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var pf pluginsFile
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&pf); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	seen := map[string]bool{}
+	baseDir := filepath.Dir(path)
+	for i := range pf.Plugins {
+		p := &pf.Plugins[i]
+		if p.Source == "" {
+			p.Source = sourceRegistry
+		}
+
+		switch p.Source {
+		case sourceRegistry:
+			if p.Name == "" {
+				return nil, fmt.Errorf("plugin #%d in %s has no name", i+1, path)
+			}
+			if p.Registry == "" {
+				p.Registry = defaultRegistry
+			}
+			if _, err = registry.ParseConstraint(p.Version); err != nil {
+				return nil, fmt.Errorf("plugin %q in %s: %w", p.Name, path, err)
+			}
+		case sourceLocalFile:
+			if p.Path == "" {
+				return nil, fmt.Errorf("plugin #%d in %s has source %q but no path", i+1, path, sourceLocalFile)
+			}
+			if !filepath.IsAbs(p.Path) {
+				p.Path = filepath.Join(baseDir, p.Path)
+			}
+			if p.Name == "" {
+				p.Name = filepath.Base(p.Path)
+			}
+			if p.Name != filepath.Base(p.Path) {
+				return nil, fmt.Errorf("plugin %q in %s: local plugins are named after their file, %q", p.Name, path, filepath.Base(p.Path))
+			}
+		default:
+			return nil, fmt.Errorf("plugin %q in %s has unknown source %q, expected %s or %s", p.Name, path, p.Source, sourceRegistry, sourceLocalFile)
+		}
+
+		if seen[p.Name] {
+			return nil, fmt.Errorf("plugin %q is declared more than once in %s", p.Name, path)
+		}
+		seen[p.Name] = true
+	}
+
+	return pf.Plugins, nil
+}
+
+// plan compares the declared plugins with the plugin home and works out what
+// has to change for them to match
+func (c *Command) plan(declared []DeclaredPlugin) ([]change, error) {
+	boms, err := runner.LoadInstalledBOMs()
+	if err != nil {
+		return nil, err
+	}
+
+	installed := map[string]runner.BillOfMaterials{}
+	for _, bom := range boms {
+		installed[bom.Name] = bom
+	}
+
+	changes := []change{}
+	for _, p := range declared {
+		var ch change
+		var err error
+		if p.Source == sourceLocalFile {
+			ch, err = planLocal(p, installed)
+		} else {
+			ch, err = c.planRegistry(p, installed)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not plan plugin %q: %w", p.Name, err)
+		}
+
+		changes = append(changes, ch)
+		delete(installed, p.Name)
+	}
+
+	if !c.NoPrune {
+		for _, bom := range installed {
+			changes = append(changes, change{
+				plugin: DeclaredPlugin{Name: bom.Name},
+				action: actionRemove,
+				from:   bom.Version.String(),
+			})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].plugin.Name < changes[j].plugin.Name
+	})
+
+	return changes, nil
+}
+
+func (c *Command) planRegistry(p DeclaredPlugin, installed map[string]runner.BillOfMaterials) (change, error) {
+	ch := change{plugin: p, action: actionNone}
+
+	reg := c.r.Get(p.Registry)
+	if reg == nil {
+		return ch, fmt.Errorf("registry %q is not configured", p.Registry)
+	}
+
+	if err := reg.LazyLoad(); err != nil {
+		return ch, err
+	}
+
+	rp, ok := reg.FindPlugin(p.Name)
+	if !ok {
+		return ch, fmt.Errorf("plugin not found in registry %q", p.Registry)
+	}
+
+	constraint, err := registry.ParseConstraint(p.Version)
+	if err != nil {
+		return ch, err
+	}
+
+	bom, isInstalled := installed[p.Name]
+	if isInstalled {
+		ch.from = bom.Version.String()
+
+		// an installed version that already satisfies the constraint is left
+		// alone, so syncing doesn't upgrade plugins behind the user's back
+		current, err := semver.ParseTolerant(ch.from)
+		if err == nil && bom.Source.Type == sourceRegistry && bom.Source.Name == p.Registry && constraint.Allows(current, p.AllowPrerelease) {
+			ch.to = ch.from
+			return ch, nil
+		}
+	}
+
+	target, err := registry.ResolveVersion(rp, constraint, p.AllowPrerelease, p.AllowYanked)
+	if err != nil {
+		return ch, err
+	}
+	ch.to = runner.NormalizeVersion(target.Version)
+
+	switch {
+	case !isInstalled:
+		ch.action = actionInstall
+	case bom.Source.Type != sourceRegistry || bom.Source.Name != p.Registry:
+		ch.action = actionUpdate
+		ch.comment = fmt.Sprintf("source changes from %s to registry %s", bom.Source, p.Registry)
+	default:
+		ch.action = actionUpdate
+		if _, err := runner.LoadPluginBOM(runner.PluginRef(p.Name, ch.to)); err == nil {
+			ch.action = actionActivate
+		}
+	}
+
+	return ch, nil
+}
+
+func planLocal(p DeclaredPlugin, installed map[string]runner.BillOfMaterials) (change, error) {
+	ch := change{plugin: p, action: actionInstall, to: p.Path}
+
+	bom, isInstalled := installed[p.Name]
+	if !isInstalled {
+		return ch, nil
+	}
+
+	ch.from = bom.Version.String()
+	algorithm := runner.DefaultHashAlgorithm
+	if bom.Integrity != nil {
+		algorithm = bom.Integrity.Algorithm
+	}
+
+	// the file is hashed the way the installed plugin was, or it never matches
+	checksum, err := runner.FileChecksum(p.Path, algorithm)
+	if err != nil {
+		return ch, err
+	}
+
+	if bom.Source.Type == sourceLocalFile && bom.Source.Name == p.Path && bom.Integrity != nil && strings.EqualFold(bom.Integrity.Checksum, checksum) {
+		ch.action = actionNone
+		ch.to = ch.from
+		return ch, nil
+	}
+
+	ch.action = actionUpdate
+	return ch, nil
+}
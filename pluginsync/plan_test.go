@@ -0,0 +1,255 @@
+package pluginsync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	plugin "github.com/gideaworx/terraform-exporter-plugin-go"
+	"github.com/gideaworx/terraform-exporter/registry"
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+const planIndex = `name: local
+plugins:
+  - name: aws
+    description: Exports AWS resources
+    versions:
+      - version: 1.0.0
+        download:
+          multi-arch: {locator: aws-1.0.0, type: native}
+      - version: 1.1.0
+        download:
+          multi-arch: {locator: aws-1.1.0, type: native}
+      - version: 2.0.0
+        download:
+          multi-arch: {locator: aws-2.0.0, type: native}
+`
+
+func registryBOM(name, version, registryName string) runner.BillOfMaterials {
+	return runner.BillOfMaterials{
+		Name:    name,
+		Type:    runner.Native,
+		Version: plugin.FromString(version),
+		Source:  runner.PluginSource{Type: sourceRegistry, Name: registryName},
+	}
+}
+
+func localBOM(path, version, checksum, algorithm string) runner.BillOfMaterials {
+	return runner.BillOfMaterials{
+		Name:      filepath.Base(path),
+		Type:      runner.Native,
+		Version:   plugin.FromString(version),
+		Source:    runner.PluginSource{Type: sourceLocalFile, Name: path},
+		Integrity: &runner.PluginIntegrity{Checksum: checksum, Algorithm: algorithm},
+	}
+}
+
+func TestPlan(t *testing.T) {
+	work := t.TempDir()
+	registryDir := filepath.Join(work, "registry")
+	if err := os.MkdirAll(registryDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(registryDir, "index.yaml"), []byte(planIndex), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	localPlugin := filepath.Join(work, "bin", "custom")
+	if err := os.MkdirAll(filepath.Dir(localPlugin), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(localPlugin, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	sha256sum, err := runner.FileChecksum(localPlugin, runner.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sha512sum, err := runner.FileChecksum(localPlugin, runner.SHA512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aws := func(version string) DeclaredPlugin {
+		return DeclaredPlugin{Name: "aws", Source: sourceRegistry, Registry: "local", Version: version}
+	}
+	custom := DeclaredPlugin{Name: "custom", Source: sourceLocalFile, Path: localPlugin}
+
+	tests := []struct {
+		name     string
+		declared []DeclaredPlugin
+		// installed are installed in order, so the last version of a plugin is
+		// the active one
+		installed []runner.BillOfMaterials
+		noPrune   bool
+		// changes are "name action from to" for every planned change
+		changes []string
+		err     string
+	}{
+		{
+			name:     "install the newest matching version",
+			declared: []DeclaredPlugin{aws("~> 1.0")},
+			changes:  []string{"aws install  1.1.0"},
+		},
+		{
+			name:     "install the newest version",
+			declared: []DeclaredPlugin{aws("")},
+			changes:  []string{"aws install  2.0.0"},
+		},
+		{
+			name:      "installed version satisfies the constraint",
+			declared:  []DeclaredPlugin{aws("~> 1.0")},
+			installed: []runner.BillOfMaterials{registryBOM("aws", "1.0.0", "local")},
+			changes:   []string{"aws none 1.0.0 1.0.0"},
+		},
+		{
+			name:      "update",
+			declared:  []DeclaredPlugin{aws(">= 2")},
+			installed: []runner.BillOfMaterials{registryBOM("aws", "1.0.0", "local")},
+			changes:   []string{"aws update 1.0.0 2.0.0"},
+		},
+		{
+			name:     "activate an installed version",
+			declared: []DeclaredPlugin{aws("2.0.0")},
+			installed: []runner.BillOfMaterials{
+				registryBOM("aws", "2.0.0", "local"),
+				registryBOM("aws", "1.0.0", "local"),
+			},
+			changes: []string{"aws activate 1.0.0 2.0.0"},
+		},
+		{
+			name:      "installed from another registry",
+			declared:  []DeclaredPlugin{aws("~> 1.0")},
+			installed: []runner.BillOfMaterials{registryBOM("aws", "1.0.0", "default")},
+			changes:   []string{"aws update 1.0.0 1.1.0"},
+		},
+		{
+			name:     "remove undeclared plugins",
+			declared: []DeclaredPlugin{aws("~> 1.0")},
+			installed: []runner.BillOfMaterials{
+				registryBOM("aws", "1.0.0", "local"),
+				registryBOM("gcp", "0.1.0", "local"),
+			},
+			changes: []string{"aws none 1.0.0 1.0.0", "gcp remove 0.1.0 "},
+		},
+		{
+			name:     "keep undeclared plugins",
+			declared: []DeclaredPlugin{aws("~> 1.0")},
+			installed: []runner.BillOfMaterials{
+				registryBOM("aws", "1.0.0", "local"),
+				registryBOM("gcp", "0.1.0", "local"),
+			},
+			noPrune: true,
+			changes: []string{"aws none 1.0.0 1.0.0"},
+		},
+		{
+			name:     "install a local file",
+			declared: []DeclaredPlugin{custom},
+			changes:  []string{fmt.Sprintf("custom install  %s", localPlugin)},
+		},
+		{
+			name:      "local file unchanged",
+			declared:  []DeclaredPlugin{custom},
+			installed: []runner.BillOfMaterials{localBOM(localPlugin, "0.1.0", sha256sum, runner.SHA256)},
+			changes:   []string{"custom none 0.1.0 0.1.0"},
+		},
+		{
+			name:      "local file unchanged, hashed with another algorithm",
+			declared:  []DeclaredPlugin{custom},
+			installed: []runner.BillOfMaterials{localBOM(localPlugin, "0.1.0", strings.ToUpper(sha512sum), runner.SHA512)},
+			changes:   []string{"custom none 0.1.0 0.1.0"},
+		},
+		{
+			name:      "local file rebuilt",
+			declared:  []DeclaredPlugin{custom},
+			installed: []runner.BillOfMaterials{localBOM(localPlugin, "0.1.0", strings.Repeat("0", 64), runner.SHA256)},
+			changes:   []string{fmt.Sprintf("custom update 0.1.0 %s", localPlugin)},
+		},
+		{
+			name:      "local file moved",
+			declared:  []DeclaredPlugin{custom},
+			installed: []runner.BillOfMaterials{localBOM(filepath.Join(work, "old", "custom"), "0.1.0", sha256sum, runner.SHA256)},
+			changes:   []string{fmt.Sprintf("custom update 0.1.0 %s", localPlugin)},
+		},
+		{
+			name:     "unknown registry",
+			declared: []DeclaredPlugin{{Name: "aws", Source: sourceRegistry, Registry: "missing"}},
+			err:      `registry "missing" is not configured`,
+		},
+		{
+			name:     "unknown plugin",
+			declared: []DeclaredPlugin{{Name: "azure", Source: sourceRegistry, Registry: "local"}},
+			err:      `plugin not found in registry "local"`,
+		},
+		{
+			name:     "no matching version",
+			declared: []DeclaredPlugin{aws(">= 3")},
+			err:      "no version of plugin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(runner.PLUGIN_HOME, t.TempDir())
+
+			r, err := registry.LoadFromDisk()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			location, err := runner.FileURL(registryDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = r.Add("local", location); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, bom := range tt.installed {
+				dir, err := runner.PluginVersionDir(bom.Name, bom.Version.String(), true)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err = runner.WriteBOM(dir, bom); err != nil {
+					t.Fatal(err)
+				}
+
+				if err = runner.SetActiveVersion(bom.Name, bom.Version.String()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c := &Command{NoPrune: tt.noPrune, r: r}
+			changes, err := c.plan(tt.declared)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := make([]string, 0, len(changes))
+			for _, ch := range changes {
+				got = append(got, fmt.Sprintf("%s %s %s %s", ch.plugin.Name, ch.action, ch.from, ch.to))
+			}
+
+			if !reflect.DeepEqual(got, tt.changes) {
+				t.Errorf("changes = %q, want %q", got, tt.changes)
+			}
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package registry
 
 import (
+	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
+	"os"
+	"strings"
 
 	"github.com/alecthomas/kong"
 	"github.com/gideaworx/terraform-exporter/runner"
@@ -11,8 +13,21 @@ import (
 
 type AddRegistryCommand struct {
 	Name string   `short:"n" help:"The name of the registry"`
-	URL  *url.URL `short:"u" help:"The HTTPS URL hosting the registry's index.yaml. Do not include index.yaml in the URL"`
-	r    *PluginRegistries
+	URL  *url.URL `short:"u" help:"The HTTPS URL hosting the registry's index.yaml, an HTTP URL on localhost, or the file:// URL or path of a directory holding it. Do not include index.yaml in the URL"`
+	Key  string   `short:"k" type:"existingfile" help:"The public key that signs the registry's index.yaml. Defaults to the key the registry publishes as index.yaml.pub, trusted on first use"`
+
+	Force   bool       `help:"Add the registry even if its index has errors"`
+	Mirrors []*url.URL `name:"mirror" help:"A mirror serving the same index and executables, tried when the registry can't be reached. Repeat for more mirrors, in the order they should be tried"`
+
+	TokenEnv         string `group:"Authentication" help:"The environment variable holding a bearer token for the registry"`
+	Username         string `group:"Authentication" help:"The username to authenticate to the registry with basic authentication"`
+	PasswordEnv      string `group:"Authentication" help:"The environment variable holding the password for --username"`
+	Netrc            bool   `group:"Authentication" help:"Authenticate with the registry host's entry in $NETRC or ~/.netrc"`
+	CredentialHelper string `group:"Authentication" help:"A command that prints a token, or a JSON object with a token or a username and password, for the registry URL it's passed"`
+
+	HTTPFlags `embed:""`
+
+	r *PluginRegistries
 }
 
 func (a *AddRegistryCommand) BeforeApply() error {
@@ -22,24 +37,156 @@ func (a *AddRegistryCommand) BeforeApply() error {
 }
 
 func (a *AddRegistryCommand) Run(ctx *kong.Context) error {
-	testURL := a.URL.JoinPath("index.yaml")
-	if testURL.Scheme != "https" {
-		return fmt.Errorf("specified url %s has scheme %q, but it must have https", a.URL, a.URL.Scheme)
+	if err := validateName(strings.TrimSpace(a.Name)); err != nil {
+		return err
+	}
+
+	var err error
+	if a.URL, err = ParseLocation(a.URL); err != nil {
+		return err
 	}
 
-	resp, err := runner.GetHTTPClient().Get(testURL.String())
+	for i, m := range a.Mirrors {
+		if a.Mirrors[i], err = ParseLocation(m); err != nil {
+			return err
+		}
+	}
+
+	auth, err := a.auth()
+	if err != nil {
+		return err
+	}
+
+	options, err := a.apply(runner.HTTPOptions{})
 	if err != nil {
-		return fmt.Errorf("error validating registry URL: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf(`expected http status "200 OK" from index.yaml, but got %q`, resp.Status)
+	reg := &PluginRegistry{URL: a.URL, Auth: auth, HTTP: options, Mirrors: a.Mirrors}
+	key, err := pinKey(ctx, reg, a.Key)
+	if err != nil {
+		return fmt.Errorf("error validating registry: %w", err)
+	}
+
+	if key == "" {
+		fmt.Fprintf(ctx.Stderr, "warning: %s does not sign its index, so its plugin listings can't be verified\n", a.URL)
+	}
+
+	if err := lintRegistry(ctx, reg, a.Force, "add the registry"); err != nil {
+		return err
 	}
 
 	if err := a.r.Add(a.Name, a.URL); err != nil {
 		return err
 	}
 
+	if err := a.r.PinIndexKey(a.Name, key); err != nil {
+		return err
+	}
+
+	if err := a.r.SetAuth(a.Name, auth); err != nil {
+		return err
+	}
+
+	if err := a.r.SetHTTPOptions(a.Name, options); err != nil {
+		return err
+	}
+
+	if err := a.r.SetMirrors(a.Name, a.Mirrors); err != nil {
+		return err
+	}
+
 	return a.r.SaveToDisk()
 }
+
+// pinKey finds the index key to pin for the registry at u, from keyFile or
+// trusted on first use, and tells the user which key it is. The key is empty
+// if the registry doesn't sign its index
+func pinKey(ctx *kong.Context, reg *PluginRegistry, keyFile string) (string, error) {
+	u := reg.URL
+	var explicit string
+	if keyFile != "" {
+		contents, err := os.ReadFile(keyFile)
+		if err != nil {
+			return "", err
+		}
+		explicit = string(contents)
+	}
+
+	key, err := reg.PinnableIndexKey(explicit)
+	if err != nil {
+		return "", err
+	}
+
+	if key == "" {
+		return "", nil
+	}
+
+	_, id, _ := runner.ParsePublicKey(key)
+	if explicit == "" {
+		fmt.Fprintf(ctx.Stdout, "Pinned index key %s published by %s. Check it with the registry's maintainers, it will be required from now on\n", id, u)
+	} else {
+		fmt.Fprintf(ctx.Stdout, "Pinned index key %s for %s\n", id, u)
+	}
+
+	return key, nil
+}
+
+// lintRegistry checks the registry's index the way "registry lint" does, so a
+// broken registry isn't saved unless force is set. action says how to force it
+func lintRegistry(ctx *kong.Context, reg *PluginRegistry, force bool, action string) error {
+	contents, err := reg.fetchRegistryFile(reg.URL, indexFileName)
+	if err != nil {
+		return fmt.Errorf("error validating registry: %w", err)
+	}
+
+	result := LintIndex(contents)
+	reg.CheckLocators(result)
+	if len(result.Issues) == 0 {
+		return nil
+	}
+
+	writeLintIssues(ctx, result)
+
+	errs := result.Errors()
+	if errs > 0 && !force {
+		return fmt.Errorf("the index of %s has %d errors, fix them or %s with --force", reg.URL, errs, action)
+	}
+
+	return nil
+}
+
+// auth returns the registry's authentication from the flags, or nil for a
+// public registry
+func (a *AddRegistryCommand) auth() (*RegistryAuth, error) {
+	auth := &RegistryAuth{
+		TokenEnv:    a.TokenEnv,
+		Username:    a.Username,
+		PasswordEnv: a.PasswordEnv,
+		Netrc:       a.Netrc,
+		Helper:      a.CredentialHelper,
+	}
+
+	if err := auth.validate(); err != nil {
+		return nil, err
+	}
+	auth.Helper = strings.TrimSpace(auth.Helper)
+
+	methods := 0
+	for _, set := range []bool{auth.TokenEnv != "", auth.Username != "", auth.Netrc, auth.Helper != ""} {
+		if set {
+			methods++
+		}
+	}
+
+	switch {
+	case methods == 0 && auth.PasswordEnv == "":
+		return nil, nil
+	case methods > 1:
+		return nil, errors.New("only one of --token-env, --username, --netrc and --credential-helper can be set")
+	case auth.PasswordEnv != "" && auth.Username == "":
+		return nil, errors.New("--password-env requires --username")
+	}
+
+	return auth, nil
+}
@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+const (
+	AdvisoryLow      = "low"
+	AdvisoryMedium   = "medium"
+	AdvisoryHigh     = "high"
+	AdvisoryCritical = "critical"
+)
+
+var (
+	ErrVersionYanked   = errors.New("version was yanked from the registry")
+	ErrInvalidAdvisory = errors.New("advisories could not be checked")
+)
+
+var advisorySeverities = map[string]int{
+	AdvisoryLow:      1,
+	AdvisoryMedium:   2,
+	AdvisoryHigh:     3,
+	AdvisoryCritical: 4,
+}
+
+// SeverityRank orders advisory severities from low to critical. Unknown
+// severities rank lowest, at 0
+func SeverityRank(severity string) int {
+	return advisorySeverities[strings.ToLower(severity)]
+}
+
+// Affects reports whether a version of the advisory's plugin is affected
+func (a Advisory) Affects(version string) (bool, error) {
+	constraint, err := ParseConstraint(a.Affected)
+	if err != nil {
+		return false, fmt.Errorf("advisory %s: %w", a.ID, err)
+	}
+
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		return false, err
+	}
+
+	return constraint.Allows(v, true), nil
+}
+
+// AdvisoriesFor returns the advisories of a loaded registry that affect a
+// version of a plugin, most severe first. Advisories that can't be evaluated
+// don't hide the others, they're returned along with an error naming them
+func (r *PluginRegistry) AdvisoriesFor(pluginName, version string) ([]Advisory, error) {
+	affecting := []Advisory{}
+	invalid := []string{}
+	for _, a := range r.Advisories {
+		if a.Plugin != pluginName {
+			continue
+		}
+
+		affected, err := a.Affects(version)
+		if err != nil {
+			invalid = append(invalid, err.Error())
+			continue
+		}
+
+		if affected {
+			affecting = append(affecting, a)
+		}
+	}
+
+	sortAdvisories(affecting)
+	if len(invalid) > 0 {
+		return affecting, fmt.Errorf("%w: %s", ErrInvalidAdvisory, strings.Join(invalid, "; "))
+	}
+
+	return affecting, nil
+}
+
+func sortAdvisories(advisories []Advisory) {
+	sort.SliceStable(advisories, func(i, j int) bool {
+		return SeverityRank(advisories[i].Severity) > SeverityRank(advisories[j].Severity)
+	})
+}
+
+func noticeSuffix(v PluginVersion) string {
+	if v.Notice == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" (%s)", v.Notice)
+}
@@ -0,0 +1,362 @@
+package registry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+var (
+	ErrNoCredentials = errors.New("no credentials found")
+	ErrBlankHelper   = errors.New("credential helper is blank")
+)
+
+// RegistryAuth describes how to authenticate to a registry. Secrets are
+// referenced rather than stored wherever possible: a token or password can be
+// read from an environment variable, a netrc file or a credential helper
+type RegistryAuth struct {
+	// TokenEnv names the environment variable holding a bearer token
+	TokenEnv string `yaml:"tokenEnv,omitempty"`
+	// Token is a bearer token stored in the registries file
+	Token string `yaml:"token,omitempty"`
+	// Username is sent with basic authentication
+	Username string `yaml:"username,omitempty"`
+	// PasswordEnv names the environment variable holding the basic auth password
+	PasswordEnv string `yaml:"passwordEnv,omitempty"`
+	// Password is a basic auth password stored in the registries file
+	Password string `yaml:"password,omitempty"`
+	// Netrc looks up the registry's host in $NETRC or ~/.netrc
+	Netrc bool `yaml:"netrc,omitempty"`
+	// Helper is a command that prints a token, or a JSON object with a token or
+	// a username and password. It's run with the registry URL as its argument
+	Helper string `yaml:"helper,omitempty"`
+
+	once     sync.Once
+	resolved credentials
+	err      error
+}
+
+type credentials struct {
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Describe summarizes how the registry is authenticated without revealing any
+// secret
+func (a *RegistryAuth) Describe() string {
+	if a == nil {
+		return "none"
+	}
+
+	switch {
+	case a.Helper != "":
+		return fmt.Sprintf("credential helper %q", helperName(a.Helper))
+	case a.Netrc:
+		return "netrc"
+	case a.TokenEnv != "":
+		return fmt.Sprintf("bearer token from $%s", a.TokenEnv)
+	case a.Token != "":
+		return "bearer token (stored)"
+	case a.Username != "" && a.PasswordEnv != "":
+		return fmt.Sprintf("basic as %s, password from $%s", a.Username, a.PasswordEnv)
+	case a.Username != "":
+		return fmt.Sprintf("basic as %s", a.Username)
+	}
+
+	return "none"
+}
+
+// authorizer returns the function that adds credentials to requests for files
+// of the registry served from sources, its URL and mirrors. Requests to any
+// other host, like a CDN serving the executables, are sent without them
+func (a *RegistryAuth) authorizer(sources []*url.URL) func(*http.Request) error {
+	if a == nil {
+		return nil
+	}
+
+	base := sources[0]
+	return func(req *http.Request) error {
+		trusted := false
+		for _, s := range sources {
+			if req.URL.Scheme == s.Scheme && req.URL.Host == s.Host {
+				trusted = true
+				break
+			}
+		}
+
+		if !trusted {
+			return nil
+		}
+
+		a.once.Do(func() {
+			a.resolved, a.err = a.resolve(base)
+		})
+
+		if a.err != nil {
+			return fmt.Errorf("could not get the credentials for %s: %w", base, a.err)
+		}
+
+		if a.resolved.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+a.resolved.Token)
+		} else {
+			req.SetBasicAuth(a.resolved.Username, a.resolved.Password)
+		}
+
+		return nil
+	}
+}
+
+func (a *RegistryAuth) resolve(base *url.URL) (credentials, error) {
+	switch {
+	case a.Helper != "":
+		return runCredentialHelper(a.Helper, base)
+	case a.Netrc:
+		return netrcCredentials(base.Hostname())
+	case a.TokenEnv != "":
+		token := os.Getenv(a.TokenEnv)
+		if token == "" {
+			return credentials{}, fmt.Errorf("%w: $%s is not set", ErrNoCredentials, a.TokenEnv)
+		}
+		return credentials{Token: token}, nil
+	case a.Token != "":
+		return credentials{Token: a.Token}, nil
+	case a.Username != "":
+		password := a.Password
+		if a.PasswordEnv != "" {
+			if password = os.Getenv(a.PasswordEnv); password == "" {
+				return credentials{}, fmt.Errorf("%w: $%s is not set", ErrNoCredentials, a.PasswordEnv)
+			}
+		}
+		return credentials{Username: a.Username, Password: password}, nil
+	}
+
+	return credentials{}, ErrNoCredentials
+}
+
+// validate returns an error for authentication that can never succeed
+func (a *RegistryAuth) validate() error {
+	if a != nil && a.Helper != "" && strings.TrimSpace(a.Helper) == "" {
+		return ErrBlankHelper
+	}
+
+	return nil
+}
+
+// helperName is the command a credential helper runs, without its arguments
+func helperName(helper string) string {
+	args := strings.Fields(helper)
+	if len(args) == 0 {
+		return ""
+	}
+
+	return args[0]
+}
+
+func runCredentialHelper(helper string, base *url.URL) (credentials, error) {
+	args := strings.Fields(helper)
+	if len(args) == 0 {
+		return credentials{}, ErrBlankHelper
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	cmd := exec.Command(args[0], append(args[1:], base.String())...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return credentials{}, fmt.Errorf("credential helper %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	out := bytes.TrimSpace(stdout.Bytes())
+	if len(out) == 0 {
+		return credentials{}, fmt.Errorf("%w: credential helper %s printed nothing", ErrNoCredentials, args[0])
+	}
+
+	if out[0] != '{' {
+		return credentials{Token: string(out)}, nil
+	}
+
+	var c credentials
+	if err := json.Unmarshal(out, &c); err != nil {
+		return credentials{}, fmt.Errorf("could not parse the output of credential helper %s: %w", args[0], err)
+	}
+
+	if c.Token == "" && c.Username == "" {
+		return credentials{}, fmt.Errorf("%w: credential helper %s printed neither a token nor a username", ErrNoCredentials, args[0])
+	}
+
+	return c, nil
+}
+
+func netrcPath() (string, error) {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+
+	return filepath.Join(home, name), nil
+}
+
+// netrcCredentials finds the login and password for host in the user's netrc
+// file, falling back to its default entry
+func netrcCredentials(host string) (credentials, error) {
+	path, err := netrcPath()
+	if err != nil {
+		return credentials{}, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return credentials{}, err
+	}
+	defer file.Close()
+
+	machines, err := parseNetrc(file)
+	if err != nil {
+		return credentials{}, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	if c, ok := machines[host]; ok {
+		return c, nil
+	}
+
+	if c, ok := machines[""]; ok {
+		return c, nil
+	}
+
+	return credentials{}, fmt.Errorf("%w: %s has no entry for %s", ErrNoCredentials, path, host)
+}
+
+// parseNetrc returns the credentials of every machine in a netrc file. The
+// default entry is returned as machine ""
+func parseNetrc(r io.Reader) (map[string]credentials, error) {
+	machines := map[string]credentials{}
+
+	scanner := bufio.NewScanner(r)
+	var (
+		tokens  []string
+		inMacro bool
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if inMacro {
+			// a macro definition ends with an empty line
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i, f := range fields {
+			if strings.HasPrefix(f, "#") {
+				fields = fields[:i]
+				break
+			}
+		}
+
+		if len(fields) > 0 && fields[0] == "macdef" {
+			inMacro = true
+			continue
+		}
+		tokens = append(tokens, fields...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		machine string
+		current *credentials
+	)
+	flush := func() {
+		if current == nil {
+			return
+		}
+		if _, ok := machines[machine]; !ok {
+			machines[machine] = *current
+		}
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine", "default":
+			flush()
+			machine = ""
+			if tokens[i] == "machine" {
+				if i+1 >= len(tokens) {
+					return nil, errors.New("machine without a name")
+				}
+				i++
+				machine = tokens[i]
+			}
+			current = &credentials{}
+		case "login", "password", "account":
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("%s without a value", tokens[i])
+			}
+			if current == nil {
+				return nil, fmt.Errorf("%s outside a machine entry", tokens[i])
+			}
+
+			i++
+			switch tokens[i-1] {
+			case "login":
+				current.Username = tokens[i]
+			case "password":
+				current.Password = tokens[i]
+			}
+		}
+	}
+	flush()
+
+	return machines, nil
+}
+
+// Open opens a file of the registry, or one of its plugins' executables, with
+// the registry's credentials. Local files are only read for registries or
+// mirrors that are local themselves
+func (r *PluginRegistry) Open(u *url.URL) (io.ReadCloser, error) {
+	if u.Scheme == "file" && !r.hasLocalSource() {
+		return nil, fmt.Errorf("%w: %s", ErrLocalLocator, u)
+	}
+
+	client, err := r.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return runner.OpenWith(u, client, r.Auth.authorizer(r.Sources()))
+}
+
+func (r *PluginRegistry) hasLocalSource() bool {
+	for _, s := range r.Sources() {
+		if s.Scheme == "file" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,174 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+func TestParseNetrc(t *testing.T) {
+	tests := []struct {
+		name     string
+		netrc    string
+		machines map[string]credentials
+		err      string
+	}{
+		{
+			name:     "empty",
+			netrc:    "",
+			machines: map[string]credentials{},
+		},
+		{
+			name:  "one machine per line",
+			netrc: "machine plugins.example.com login alice password s3cret\n",
+			machines: map[string]credentials{
+				"plugins.example.com": {Username: "alice", Password: "s3cret"},
+			},
+		},
+		{
+			name: "tokens across lines",
+			netrc: `machine plugins.example.com
+  login alice
+  password s3cret
+machine mirror.example.com
+  login bob
+  account ignored
+  password hunter2
+`,
+			machines: map[string]credentials{
+				"plugins.example.com": {Username: "alice", Password: "s3cret"},
+				"mirror.example.com":  {Username: "bob", Password: "hunter2"},
+			},
+		},
+		{
+			name:  "default entry",
+			netrc: "machine plugins.example.com login alice password a\ndefault login anonymous password guest\n",
+			machines: map[string]credentials{
+				"plugins.example.com": {Username: "alice", Password: "a"},
+				"":                    {Username: "anonymous", Password: "guest"},
+			},
+		},
+		{
+			name:  "first entry wins",
+			netrc: "machine plugins.example.com login alice password a\nmachine plugins.example.com login bob password b\n",
+			machines: map[string]credentials{
+				"plugins.example.com": {Username: "alice", Password: "a"},
+			},
+		},
+		{
+			name: "comments",
+			netrc: `# work registry
+machine plugins.example.com login alice # the service account
+password s3cret
+`,
+			machines: map[string]credentials{
+				"plugins.example.com": {Username: "alice", Password: "s3cret"},
+			},
+		},
+		{
+			name: "macros are skipped",
+			netrc: `machine ftp.example.com login ftp password ftp
+macdef init
+cd /pub
+machine fake login x password y
+
+machine plugins.example.com login alice password s3cret
+`,
+			machines: map[string]credentials{
+				"ftp.example.com":     {Username: "ftp", Password: "ftp"},
+				"plugins.example.com": {Username: "alice", Password: "s3cret"},
+			},
+		},
+		{
+			name:  "machine without a name",
+			netrc: "machine",
+			err:   "machine without a name",
+		},
+		{
+			name:  "login without a value",
+			netrc: "machine plugins.example.com login",
+			err:   "login without a value",
+		},
+		{
+			name:  "password outside a machine",
+			netrc: "password s3cret machine plugins.example.com",
+			err:   "password outside a machine entry",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machines, err := parseNetrc(strings.NewReader(tt.netrc))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(machines, tt.machines) {
+				t.Errorf("machines = %+v, want %+v", machines, tt.machines)
+			}
+		})
+	}
+}
+
+func TestBlankCredentialHelper(t *testing.T) {
+	tests := []struct {
+		name   string
+		helper string
+		blank  bool
+	}{
+		{name: "unset"},
+		{name: "command", helper: "pass show registry"},
+		{name: "padded command", helper: "  pass show registry "},
+		{name: "spaces", helper: "   ", blank: true},
+		{name: "tab and newline", helper: "\t\n", blank: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := &RegistryAuth{Helper: tt.helper}
+			if err := auth.validate(); (err != nil) != tt.blank {
+				t.Errorf("validate() = %v, want blank = %v", err, tt.blank)
+			}
+
+			// a blank helper must be reported, not crash whatever shows or runs it
+			auth.Describe()
+			if tt.blank {
+				if _, err := runCredentialHelper(tt.helper, &url.URL{Scheme: "https", Host: "plugins.example.com"}); !errors.Is(err, ErrBlankHelper) {
+					t.Errorf("runCredentialHelper() = %v, want %v", err, ErrBlankHelper)
+				}
+			}
+
+			// nor be added, or loaded from a hand-edited registries file
+			add := &AddRegistryCommand{CredentialHelper: tt.helper}
+			if _, err := add.auth(); (err != nil) != tt.blank {
+				t.Errorf("auth() = %v, want blank = %v", err, tt.blank)
+			}
+
+			home := t.TempDir()
+			t.Setenv(runner.PLUGIN_HOME, home)
+
+			contents := fmt.Sprintf("registries:\n  - name: private\n    url: https://plugins.example.com\n    auth:\n      helper: %q\n", tt.helper)
+			if err := os.WriteFile(filepath.Join(home, registryFileName), []byte(contents), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := LoadFromDisk(); (err != nil) != tt.blank {
+				t.Errorf("LoadFromDisk() = %v, want blank = %v", err, tt.blank)
+			}
+		})
+	}
+}
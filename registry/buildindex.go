@@ -0,0 +1,390 @@
+package registry
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter-plugin-registry/registry"
+	"github.com/gideaworx/terraform-exporter/runner"
+	"gopkg.in/yaml.v3"
+)
+
+// artifactPattern matches executables named <plugin>_<version>_<os>_<arch>, as
+// release tools like goreleaser name them
+var artifactPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9.-]*)_(v?[0-9]+\.[0-9]+\.[0-9]+[^_]*)_([a-z0-9]+)_([a-z0-9]+)(\.exe)?$`)
+
+type BuildIndexCommand struct {
+	Dir        string   `arg:"" type:"existingdir" help:"The directory holding the plugin executables, named <plugin>_<version>_<os>_<arch>, in any subdirectory"`
+	Name       string   `short:"n" help:"The name of the registry. Defaults to the name in the existing index.yaml, or the directory's name"`
+	BaseURL    string   `help:"The URL the registry will be served from, recorded in the index"`
+	Hash       []string `short:"a" help:"Also publish checksums with these hash algorithms. sha256 checksums are always published"`
+	Output     string   `short:"o" type:"path" help:"Where to write the index. Defaults to index.yaml in the directory"`
+	SkipLaunch bool     `help:"Don't launch the executables that run on this machine to check their version and read the commands they provide"`
+}
+
+type artifact struct {
+	path      string
+	locator   string
+	plugin    string
+	version   string
+	arch      TargetArchitecture
+	checksums map[string]string
+	signature *ArtifactSignature
+}
+
+func (b *BuildIndexCommand) Run(ctx *kong.Context) error {
+	for _, alg := range b.Hash {
+		if err := runner.ValidateHashAlgorithm(alg); err != nil {
+			return err
+		}
+	}
+
+	dir, err := filepath.Abs(b.Dir)
+	if err != nil {
+		return err
+	}
+
+	output := b.Output
+	if output == "" {
+		output = filepath.Join(dir, indexFileName)
+	}
+
+	existing, err := readExistingIndex(output)
+	if err != nil {
+		return err
+	}
+
+	artifacts, err := b.scan(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	if len(artifacts) == 0 {
+		return fmt.Errorf("no plugin executables named <plugin>_<version>_<os>_<arch> found in %s", dir)
+	}
+
+	commands := map[string][]PluginCommand{}
+	if !b.SkipLaunch {
+		if commands, err = b.launch(ctx, artifacts); err != nil {
+			return err
+		}
+	}
+
+	index := b.buildIndex(ctx, dir, existing, artifacts, commands)
+
+	buf := &bytes.Buffer{}
+	encoder := yaml.NewEncoder(buf)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(index); err != nil {
+		return err
+	}
+
+	if err = writeFile(output, buf.Bytes()); err != nil {
+		return err
+	}
+
+	versions := 0
+	for _, p := range index.Plugins {
+		versions += len(p.Versions)
+	}
+	fmt.Fprintf(ctx.Stdout, "Wrote %d plugins with %d versions to %s\n", len(index.Plugins), versions, output)
+
+	if _, err = os.Stat(filepath.Join(filepath.Dir(output), indexSignatureFileName)); err == nil {
+		fmt.Fprintf(ctx.Stderr, "warning: %s no longer matches the index, sign the index again\n", indexSignatureFileName)
+	}
+
+	return nil
+}
+
+func readExistingIndex(path string) (*Index, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Index{}, nil
+		}
+		return nil, err
+	}
+
+	index := &Index{}
+	if err = yaml.Unmarshal(contents, index); err != nil {
+		return nil, fmt.Errorf("could not parse the existing index %s: %w", path, err)
+	}
+
+	return index, nil
+}
+
+// scan finds the plugin executables in dir and computes their checksums
+func (b *BuildIndexCommand) scan(ctx *kong.Context, dir string) ([]artifact, error) {
+	algorithms := append([]string{runner.SHA256}, b.Hash...)
+
+	artifacts := []artifact{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		matches := artifactPattern.FindStringSubmatch(d.Name())
+		if matches == nil {
+			if info, err := d.Info(); err == nil && info.Mode().Perm()&0o111 != 0 {
+				fmt.Fprintf(ctx.Stderr, "skipping %s: not named <plugin>_<version>_<os>_<arch>\n", path)
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		a := artifact{
+			path:    path,
+			locator: filepath.ToSlash(rel),
+			plugin:  matches[1],
+			version: runner.NormalizeVersion(matches[2]),
+			arch:    TargetArchitecture(fmt.Sprintf("%s/%s", matches[3], matches[4])),
+		}
+
+		if a.checksums, err = checksumFile(path, algorithms); err != nil {
+			return err
+		}
+
+		for _, sig := range [][2]string{{".minisig", runner.SignatureMinisign}, {".sig", runner.SignatureCosign}} {
+			if _, err := os.Stat(path + sig[0]); err == nil {
+				a.signature = &ArtifactSignature{Format: sig[1], Locator: d.Name() + sig[0]}
+				break
+			}
+		}
+
+		artifacts = append(artifacts, a)
+		return nil
+	})
+
+	return artifacts, err
+}
+
+func checksumFile(path string, algorithms []string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hasher, err := runner.NewMultiHash(algorithms...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = io.Copy(hasher, file); err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string, len(algorithms))
+	for _, alg := range algorithms {
+		checksums[alg] = hasher.Sum(alg)
+	}
+
+	return checksums, nil
+}
+
+// launch runs every executable built for this machine through the plugin
+// handshake, checks it reports the version in its name, and returns the
+// commands each plugin provides. Executables for other platforms can't be run
+// and are only checksummed
+func (b *BuildIndexCommand) launch(ctx *kong.Context, artifacts []artifact) (map[string][]PluginCommand, error) {
+	local := TargetArchitecture(fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH))
+
+	commands := map[string][]PluginCommand{}
+	for _, a := range artifacts {
+		if a.arch != local {
+			continue
+		}
+
+		info, err := runner.LoadPluginInfo(a.path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("could not launch %s: %w", a.path, err)
+		}
+
+		if reported := info.Version.String(); reported != a.version {
+			return nil, fmt.Errorf("%s is named as version %s but reports version %s", a.path, a.version, reported)
+		}
+
+		provided := make([]PluginCommand, 0, len(info.Provides))
+		for _, c := range info.Provides {
+			provided = append(provided, PluginCommand{Name: c.Name, Summary: c.Summary})
+		}
+
+		commands[runner.PluginRef(a.plugin, a.version)] = provided
+		fmt.Fprintf(ctx.Stdout, "Launched %s %s, which provides %d commands\n", a.plugin, a.version, len(provided))
+	}
+
+	return commands, nil
+}
+
+// buildIndex builds the index from the artifacts. What can't be read from the
+// artifacts, like descriptions, authors, yanked versions and advisories, is
+// kept from the existing index
+func (b *BuildIndexCommand) buildIndex(ctx *kong.Context, dir string, existing *Index, artifacts []artifact, commands map[string][]PluginCommand) Index {
+	previous := map[string]Plugin{}
+	for _, p := range existing.Plugins {
+		previous[p.Name] = p
+	}
+
+	plugins := map[string]*Plugin{}
+	versions := map[string]map[string]*PluginVersion{}
+	for _, a := range artifacts {
+		p, ok := plugins[a.plugin]
+		if !ok {
+			p = &Plugin{Name: a.plugin}
+			if prev, ok := previous[a.plugin]; ok {
+				p.Description = prev.Description
+				p.Homepage = prev.Homepage
+				p.Authors = prev.Authors
+				p.LastUpdated = prev.LastUpdated
+				p.Commands = prev.Commands
+			}
+			plugins[a.plugin] = p
+			versions[a.plugin] = map[string]*PluginVersion{}
+		}
+
+		v, ok := versions[a.plugin][a.version]
+		if !ok {
+			v = &PluginVersion{Version: a.version, DownloadInfo: map[TargetArchitecture]PluginExecutable{}}
+			if prev, ok := FindVersion(previous[a.plugin], a.version); ok {
+				v.Yanked = prev.Yanked
+				v.Deprecated = prev.Deprecated
+				v.Notice = prev.Notice
+			}
+			versions[a.plugin][a.version] = v
+		}
+
+		exe := PluginExecutable{
+			Locator: a.locator,
+			Type:    Native,
+			Info: ExecutableInfo{
+				Checksum:  a.checksums[runner.SHA256],
+				Signature: a.signature,
+			},
+		}
+
+		for alg, sum := range a.checksums {
+			if alg == runner.SHA256 {
+				continue
+			}
+			if exe.Info.Checksums == nil {
+				exe.Info.Checksums = map[string]string{}
+			}
+			exe.Info.Checksums[alg] = sum
+		}
+
+		exe.Info.ExtraArgs = previousArgs(previous[a.plugin], a.version, a.arch)
+		v.DownloadInfo[a.arch] = exe
+	}
+
+	index := Index{
+		Name:       b.Name,
+		BaseURL:    b.BaseURL,
+		Plugins:    make([]Plugin, 0, len(plugins)),
+		Advisories: existing.Advisories,
+	}
+
+	if index.Name == "" {
+		index.Name = existing.Name
+	}
+	if index.Name == "" {
+		index.Name = filepath.Base(dir)
+	}
+	if index.BaseURL == "" {
+		index.BaseURL = existing.BaseURL
+	}
+
+	for name, p := range plugins {
+		for _, v := range versions[name] {
+			p.Versions = append(p.Versions, *v)
+		}
+		SortVersions(p.Versions)
+
+		// the commands of the newest version that could be launched describe
+		// what the plugin provides
+		for _, v := range p.Versions {
+			if provided, ok := commands[runner.PluginRef(name, v.Version)]; ok {
+				p.Commands = provided
+				break
+			}
+		}
+
+		if prev, ok := previous[name]; !ok || !sameVersions(prev.Versions, p.Versions) {
+			p.LastUpdated = registry.ISO8601Time(time.Now().UTC().Truncate(time.Second))
+		}
+
+		if p.Description == "" {
+			fmt.Fprintf(ctx.Stderr, "warning: %s has no description. Add one to the index, it's kept when the index is built again\n", name)
+		}
+
+		index.Plugins = append(index.Plugins, *p)
+	}
+
+	sort.Slice(index.Plugins, func(i, j int) bool {
+		return index.Plugins[i].Name < index.Plugins[j].Name
+	})
+
+	return index
+}
+
+func previousArgs(p Plugin, version string, arch TargetArchitecture) []string {
+	for _, v := range p.Versions {
+		if runner.NormalizeVersion(v.Version) == version {
+			return v.DownloadInfo[arch].Info.ExtraArgs
+		}
+	}
+
+	return nil
+}
+
+// sameVersions reports whether two lists of versions have the same versions
+// with the same executables
+func sameVersions(a, b []PluginVersion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	executables := map[string]string{}
+	for _, v := range a {
+		for arch, exe := range v.DownloadInfo {
+			executables[fmt.Sprintf("%s %s", runner.NormalizeVersion(v.Version), arch)] = exe.Info.Checksum
+		}
+	}
+
+	count := 0
+	for _, v := range b {
+		for arch, exe := range v.DownloadInfo {
+			sum, ok := executables[fmt.Sprintf("%s %s", runner.NormalizeVersion(v.Version), arch)]
+			if !ok || sum != exe.Info.Checksum {
+				return false
+			}
+			count++
+		}
+	}
+
+	return count == len(executables)
+}
@@ -0,0 +1,386 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gideaworx/terraform-exporter/runner"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultCacheTTL = time.Hour
+
+	cacheDirName      = ".cache"
+	cacheMetaFileName = "cache.yaml"
+)
+
+var ErrNotCached = errors.New("no cached index")
+
+// CacheOptions control how registry indexes are cached
+type CacheOptions struct {
+	// TTL is how long a cached index is used before it's revalidated
+	TTL time.Duration
+	// Offline means only cached indexes are used, the network never is
+	Offline bool
+}
+
+var cacheOptions = CacheOptions{TTL: DefaultCacheTTL}
+
+// ConfigureCache sets how every registry caches its index
+func ConfigureCache(o CacheOptions) {
+	cacheOptions = o
+}
+
+type cacheMeta struct {
+	URL          string    `yaml:"url"`
+	ETag         string    `yaml:"etag,omitempty"`
+	LastModified string    `yaml:"lastModified,omitempty"`
+	FetchedAt    time.Time `yaml:"fetchedAt"`
+}
+
+type cachedIndex struct {
+	meta      cacheMeta
+	index     []byte
+	signature []byte
+}
+
+// RefreshResult describes what a refresh of a registry's index did
+type RefreshResult string
+
+const (
+	Cached      RefreshResult = "cached"
+	NotModified RefreshResult = "not modified"
+	Updated     RefreshResult = "updated"
+	Stale       RefreshResult = "stale"
+)
+
+// cacheDir is where the index of the registry at u is cached. Caches are kept
+// by URL, so a registry that moves never reads another registry's index
+func cacheDir(u *url.URL) (string, error) {
+	home, err := runner.PluginHome()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(u.String()))
+	return filepath.Join(home, cacheDirName, "registries", fmt.Sprintf("%x", sum[:8])), nil
+}
+
+func readCache(u *url.URL) (*cachedIndex, error) {
+	dir, err := cacheDir(u)
+	if err != nil {
+		return nil, err
+	}
+
+	metaBytes, err := os.ReadFile(filepath.Join(dir, cacheMetaFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w for %s", ErrNotCached, u)
+		}
+		return nil, err
+	}
+
+	c := &cachedIndex{}
+	if err = yaml.Unmarshal(metaBytes, &c.meta); err != nil {
+		return nil, fmt.Errorf("could not parse the cached index of %s: %w", u, err)
+	}
+
+	if c.index, err = os.ReadFile(filepath.Join(dir, indexFileName)); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w for %s", ErrNotCached, u)
+		}
+		return nil, err
+	}
+
+	c.signature, err = os.ReadFile(filepath.Join(dir, indexSignatureFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func writeCache(u *url.URL, c *cachedIndex) error {
+	dir, err := cacheDir(u)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	if c.index != nil {
+		if err = writeFile(filepath.Join(dir, indexFileName), c.index); err != nil {
+			return err
+		}
+
+		sigPath := filepath.Join(dir, indexSignatureFileName)
+		if c.signature != nil {
+			err = writeFile(sigPath, c.signature)
+		} else {
+			err = os.Remove(sigPath)
+		}
+
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	metaBytes, err := yaml.Marshal(c.meta)
+	if err != nil {
+		return err
+	}
+
+	// the metadata is written last, so a cache is only used once it's complete
+	return writeFile(filepath.Join(dir, cacheMetaFileName), metaBytes)
+}
+
+// DropCache removes the cached index of the registry at u
+func DropCache(u *url.URL) error {
+	dir, err := cacheDir(u)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
+
+func writeFile(path string, contents []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, contents, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// loadIndex returns the registry's index, from the cache while it's fresh and
+// from the registry otherwise. force revalidates the cache whatever its age
+func (r *PluginRegistry) loadIndex(force bool) ([]byte, RefreshResult, error) {
+	// there's no point caching what's already on disk, and it's available
+	// offline anyway
+	if r.URL.Scheme == "file" && len(r.Mirrors) == 0 {
+		fetched, err := r.readLocalIndex(r.URL)
+		if err != nil {
+			return nil, "", err
+		}
+
+		r.servedFrom = r.URL
+		return fetched.index, Updated, nil
+	}
+
+	cached, err := readCache(r.URL)
+	if err != nil && !errors.Is(err, ErrNotCached) {
+		return nil, "", err
+	}
+
+	if cached != nil {
+		r.servedFrom, _ = url.Parse(cached.meta.URL)
+	}
+
+	if cacheOptions.Offline {
+		if cached == nil {
+			return nil, "", fmt.Errorf("%w for %s, run \"registry refresh\" while online first", ErrNotCached, r.URL)
+		}
+
+		return cached.index, Cached, r.verifyIndex(cached.index, cached.signature)
+	}
+
+	if cached != nil && !force && time.Since(cached.meta.FetchedAt) < cacheOptions.TTL {
+		return cached.index, Cached, r.verifyIndex(cached.index, cached.signature)
+	}
+
+	fetched, result, err := r.fetchFromSources(cached)
+	if err != nil {
+		// a registry that can't be reached shouldn't stop anything that can be
+		// done with the index we already have, but one that refuses the
+		// credentials or serves a badly signed index should
+		var unreachable *url.Error
+		if cached == nil || !errors.As(err, &unreachable) {
+			return nil, "", err
+		}
+
+		fmt.Fprintf(os.Stderr, "warning: using the cached index of %s from %s: %v\n", r.URL, cached.meta.FetchedAt.Local().Format(time.RFC1123), err)
+		return cached.index, Stale, r.verifyIndex(cached.index, cached.signature)
+	}
+
+	if err = writeCache(r.URL, fetched); err != nil {
+		return nil, "", err
+	}
+
+	return fetched.index, result, nil
+}
+
+// fetchFromSources downloads the index from the registry, failing over to its
+// mirrors in order. An index from a mirror must agree with the last index
+// that was fetched about every plugin version both list. With nothing cached
+// to compare with, only a signed index, verified with the pinned key, is
+// accepted from a mirror
+func (r *PluginRegistry) fetchFromSources(cached *cachedIndex) (*cachedIndex, RefreshResult, error) {
+	sources := r.Sources()
+
+	var (
+		lastErr        error
+		failures       []string
+		allUnreachable = true
+	)
+	for i, base := range sources {
+		fetched, result, err := r.fetchIndex(base, cached)
+		if err == nil && i > 0 {
+			switch {
+			case cached != nil:
+				err = checkConsistency(cached.index, fetched.index)
+			case r.IndexKey == "":
+				err = fmt.Errorf("%w: the registry's index isn't signed and has never been fetched to compare with. Fetch it from %s once, or pin its key with \"registry pin-key\"", ErrMirrorUnverified, r.URL.Redacted())
+			}
+		}
+
+		if err == nil {
+			if i > 0 {
+				fmt.Fprintf(os.Stderr, "warning: %s failed over to mirror %s\n", r.URL, base)
+			}
+
+			r.servedFrom = base
+			return fetched, result, nil
+		}
+
+		var unreachable *url.Error
+		if !errors.As(err, &unreachable) {
+			allUnreachable = false
+		}
+
+		lastErr = err
+		failures = append(failures, fmt.Sprintf("%s: %v", base, err))
+	}
+
+	if len(sources) == 1 {
+		return nil, "", lastErr
+	}
+
+	if allUnreachable {
+		// keep the error a *url.Error so a cached index can still be used
+		return nil, "", &url.Error{Op: "Get", URL: r.URL.String(), Err: fmt.Errorf("no mirror could be reached: %s", strings.Join(failures, "; "))}
+	}
+
+	return nil, "", fmt.Errorf("%w. No mirror of the registry could be used: %s", lastErr, strings.Join(failures, "; "))
+}
+
+// fetchIndex downloads the index from base, revalidating the cached copy if
+// it came from there
+func (r *PluginRegistry) fetchIndex(base *url.URL, cached *cachedIndex) (*cachedIndex, RefreshResult, error) {
+	if base.Scheme == "file" {
+		fetched, err := r.readLocalIndex(base)
+		return fetched, Updated, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, base.JoinPath(indexFileName).String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// a cached index that doesn't verify, because it was tampered with or the
+	// pinned key changed, is downloaded again in full
+	if cached != nil && cached.meta.URL == base.String() && r.verifyIndex(cached.index, cached.signature) == nil {
+		if cached.meta.ETag != "" {
+			req.Header.Set("If-None-Match", cached.meta.ETag)
+		}
+		if cached.meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.meta.LastModified)
+		}
+	}
+
+	if authorize := r.Auth.authorizer(r.Sources()); authorize != nil {
+		if err = authorize(req); err != nil {
+			return nil, "", err
+		}
+	}
+
+	client, err := r.client()
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	fetched := &cachedIndex{
+		meta: cacheMeta{
+			URL:          base.String(),
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			FetchedAt:    time.Now().UTC(),
+		},
+	}
+	result := Updated
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		fetched.meta.ETag = cached.meta.ETag
+		fetched.meta.LastModified = cached.meta.LastModified
+		fetched.index = cached.index
+		result = NotModified
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, "", fmt.Errorf("%w: %s returned '%s'", runner.ErrUnauthorized, indexFileName, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", fmt.Errorf("expected status '200 OK' from %s, got '%s'", indexFileName, resp.Status)
+	default:
+		if fetched.index, err = io.ReadAll(io.LimitReader(resp.Body, maxIndexSize+1)); err != nil {
+			return nil, "", err
+		}
+
+		if len(fetched.index) > maxIndexSize {
+			return nil, "", fmt.Errorf("%s is larger than %d bytes", indexFileName, maxIndexSize)
+		}
+	}
+
+	// the signature is downloaded again even when the index hasn't changed,
+	// since the registry may have been signed or its key rotated since
+	if r.IndexKey != "" {
+		if fetched.signature, err = r.fetchRegistryFile(base, indexSignatureFileName); err != nil {
+			return nil, "", fmt.Errorf("%w: %v", ErrIndexSignature, err)
+		}
+
+		if err = r.verifyIndex(fetched.index, fetched.signature); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return fetched, result, nil
+}
+
+func (r *PluginRegistry) readLocalIndex(base *url.URL) (*cachedIndex, error) {
+	index, err := r.fetchRegistryFile(base, indexFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var signature []byte
+	if r.IndexKey != "" {
+		if signature, err = r.fetchRegistryFile(base, indexSignatureFileName); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrIndexSignature, err)
+		}
+	}
+
+	if err = r.verifyIndex(index, signature); err != nil {
+		return nil, err
+	}
+
+	return &cachedIndex{
+		meta:      cacheMeta{URL: base.String(), FetchedAt: time.Now().UTC()},
+		index:     index,
+		signature: signature,
+	}, nil
+}
@@ -0,0 +1,205 @@
+package registry
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+const (
+	cachedIndexContents = "name: example\nplugins: []\n"
+	servedIndexContents = "name: example\nplugins: []\n# updated\n"
+)
+
+func TestLoadIndexCache(t *testing.T) {
+	tests := []struct {
+		name string
+		// cachedAge is how long ago the cached index was fetched, nothing is
+		// cached if it's zero
+		cachedAge time.Duration
+		cachedTag string
+		force     bool
+		offline   bool
+		// down makes the registry unreachable
+		down   bool
+		result RefreshResult
+		index  string
+		err    error
+		// requests is how many times the index was requested
+		requests int
+		// revalidated is whether the request asked for the cached ETag
+		revalidated bool
+	}{
+		{
+			name:     "nothing cached",
+			result:   Updated,
+			index:    servedIndexContents,
+			requests: 1,
+		},
+		{
+			name:      "fresh cache",
+			cachedAge: 10 * time.Minute,
+			cachedTag: `"served"`,
+			result:    Cached,
+			index:     cachedIndexContents,
+		},
+		{
+			name:        "forced refresh of a fresh cache",
+			cachedAge:   10 * time.Minute,
+			cachedTag:   `"served"`,
+			force:       true,
+			result:      NotModified,
+			index:       cachedIndexContents,
+			requests:    1,
+			revalidated: true,
+		},
+		{
+			name:        "expired cache not modified",
+			cachedAge:   2 * time.Hour,
+			cachedTag:   `"served"`,
+			result:      NotModified,
+			index:       cachedIndexContents,
+			requests:    1,
+			revalidated: true,
+		},
+		{
+			name:        "expired cache modified",
+			cachedAge:   2 * time.Hour,
+			cachedTag:   `"old"`,
+			result:      Updated,
+			index:       servedIndexContents,
+			requests:    1,
+			revalidated: true,
+		},
+		{
+			name:      "expired cache, registry unreachable",
+			cachedAge: 2 * time.Hour,
+			cachedTag: `"served"`,
+			down:      true,
+			result:    Stale,
+			index:     cachedIndexContents,
+		},
+		{
+			name:   "nothing cached, registry unreachable",
+			down:   true,
+			result: "",
+		},
+		{
+			name:      "offline uses an expired cache",
+			cachedAge: 2 * time.Hour,
+			offline:   true,
+			result:    Cached,
+			index:     cachedIndexContents,
+		},
+		{
+			name:    "offline with nothing cached",
+			offline: true,
+			err:     ErrNotCached,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(runner.PLUGIN_HOME, t.TempDir())
+
+			ConfigureCache(CacheOptions{TTL: DefaultCacheTTL, Offline: tt.offline})
+			t.Cleanup(func() { ConfigureCache(CacheOptions{TTL: DefaultCacheTTL}) })
+
+			requests, revalidated := 0, false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				requests++
+				if tag := req.Header.Get("If-None-Match"); tag != "" {
+					revalidated = true
+					if tag == `"served"` {
+						w.WriteHeader(http.StatusNotModified)
+						return
+					}
+				}
+
+				w.Header().Set("ETag", `"served"`)
+				w.Write([]byte(servedIndexContents))
+			}))
+			defer server.Close()
+
+			u, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.down {
+				server.Close()
+			}
+
+			r := &PluginRegistry{URL: u}
+			if tt.cachedAge != 0 {
+				err = writeCache(u, &cachedIndex{
+					meta: cacheMeta{
+						URL:       u.String(),
+						ETag:      tt.cachedTag,
+						FetchedAt: time.Now().UTC().Add(-tt.cachedAge),
+					},
+					index: []byte(cachedIndexContents),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			index, result, err := r.loadIndex(tt.force)
+			switch {
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			case tt.result == "":
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if result != tt.result {
+				t.Errorf("result = %q, want %q", result, tt.result)
+			}
+
+			if string(index) != tt.index {
+				t.Errorf("index = %q, want %q", index, tt.index)
+			}
+
+			if requests != tt.requests {
+				t.Errorf("index was requested %d times, want %d", requests, tt.requests)
+			}
+
+			if revalidated != tt.revalidated {
+				t.Errorf("revalidated = %v, want %v", revalidated, tt.revalidated)
+			}
+
+			// whatever was used is what's cached now, fetched again if it was
+			// requested
+			cached, err := readCache(u)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(cached.index) != tt.index {
+				t.Errorf("cached index = %q, want %q", cached.index, tt.index)
+			}
+
+			if fresh := time.Since(cached.meta.FetchedAt) < time.Minute; fresh != (tt.requests > 0) {
+				t.Errorf("cache fetched at %s, want it refreshed = %v", cached.meta.FetchedAt, tt.requests > 0)
+			}
+
+			if tt.requests > 0 && cached.meta.ETag != `"served"` {
+				t.Errorf("cached ETag = %q, want %q", cached.meta.ETag, `"served"`)
+			}
+		})
+	}
+}
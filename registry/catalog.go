@@ -0,0 +1,337 @@
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/gideaworx/terraform-exporter/runner"
+	"gopkg.in/yaml.v3"
+)
+
+// catalogSignatureSuffix is appended to a catalog's URL to find its signature
+const catalogSignatureSuffix = ".sig"
+
+var (
+	ErrManaged          = errors.New("registry is managed by a catalog")
+	ErrCatalogSignature = errors.New("catalog signature verification failed")
+)
+
+// Catalog is a list of registries shared by an organization, so everyone
+// installs plugins from the same places. Secrets are only referenced
+type Catalog struct {
+	Registries []CatalogRegistry `yaml:"registries"`
+}
+
+type CatalogRegistry struct {
+	Name        string              `yaml:"name"`
+	URL         string              `yaml:"url"`
+	IndexKey    string              `yaml:"indexKey,omitempty"`
+	TrustedKeys []runner.TrustedKey `yaml:"trustedKeys,omitempty"`
+	Auth        *RegistryAuth       `yaml:"auth,omitempty"`
+	Mirrors     []string            `yaml:"mirrors,omitempty"`
+}
+
+// CatalogSource is where a catalog was synced from, and the key that signs it
+type CatalogSource struct {
+	Location string `yaml:"location"`
+	Key      string `yaml:"key,omitempty"`
+}
+
+// Catalog returns where the managed registries were synced from, or nil if no
+// catalog was ever synced
+func (p *PluginRegistries) Catalog() *CatalogSource {
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	if p.catalog == nil {
+		return nil
+	}
+
+	c := *p.catalog
+	return &c
+}
+
+// FetchCatalog downloads a catalog and checks its signature against key. A
+// catalog is only unsigned when key is empty
+func FetchCatalog(location *url.URL, key string) (*Catalog, error) {
+	// catalogs are fetched with the HTTP options shared by every registry
+	fetcher := &PluginRegistry{URL: location}
+
+	contents, err := fetcher.fetchCatalogFile(location)
+	if err != nil {
+		return nil, err
+	}
+
+	if key != "" {
+		sigURL := *location
+		sigURL.Path += catalogSignatureSuffix
+		signature, err := fetcher.fetchCatalogFile(&sigURL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCatalogSignature, err)
+		}
+
+		format, _, err := runner.ParsePublicKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: the catalog key is invalid: %v", ErrCatalogSignature, err)
+		}
+
+		if _, err = runner.VerifySignature(format, contents, signature, []runner.TrustedKey{{Name: "catalog", Key: key}}); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCatalogSignature, err)
+		}
+	}
+
+	return ParseCatalog(contents)
+}
+
+func (r *PluginRegistry) fetchCatalogFile(u *url.URL) ([]byte, error) {
+	body, err := r.Open(u)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s does not exist", u)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	contents, err := io.ReadAll(io.LimitReader(body, maxIndexSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contents) > maxIndexSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", u, maxIndexSize)
+	}
+
+	return contents, nil
+}
+
+// ParseCatalog parses a catalog and checks every registry in it could be added
+func ParseCatalog(contents []byte) (*Catalog, error) {
+	catalog := &Catalog{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(catalog); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("could not parse the catalog: %w", err)
+	}
+
+	names := map[string]bool{}
+	for _, reg := range catalog.Registries {
+		if err := validateName(reg.Name); err != nil {
+			return nil, fmt.Errorf("catalog: %w", err)
+		}
+
+		if names[reg.Name] {
+			return nil, fmt.Errorf("catalog: registry %s is listed more than once", reg.Name)
+		}
+		names[reg.Name] = true
+
+		u, err := url.Parse(reg.URL)
+		if err == nil {
+			_, err = ParseLocation(u)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("catalog: registry %s: %w", reg.Name, err)
+		}
+
+		if _, err = parseMirrors(reg.Mirrors); err != nil {
+			return nil, fmt.Errorf("catalog: registry %s: %w", reg.Name, err)
+		}
+
+		if reg.IndexKey != "" {
+			if _, _, err = runner.ParsePublicKey(reg.IndexKey); err != nil {
+				return nil, fmt.Errorf("catalog: registry %s: index key: %w", reg.Name, err)
+			}
+		}
+
+		for _, k := range reg.TrustedKeys {
+			if _, _, err = runner.ParsePublicKey(k.Key); err != nil {
+				return nil, fmt.Errorf("catalog: registry %s: trusted key %q: %w", reg.Name, k.Name, err)
+			}
+		}
+
+		if reg.Auth != nil && (reg.Auth.Token != "" || reg.Auth.Password != "") {
+			return nil, fmt.Errorf("catalog: registry %s: a catalog can only reference secrets, use tokenEnv, passwordEnv, netrc or helper", reg.Name)
+		}
+
+		if err = reg.Auth.validate(); err != nil {
+			return nil, fmt.Errorf("catalog: registry %s: %w", reg.Name, err)
+		}
+	}
+
+	return catalog, nil
+}
+
+type CatalogAction string
+
+const (
+	CatalogAdd    CatalogAction = "add"
+	CatalogAdopt  CatalogAction = "adopt"
+	CatalogUpdate CatalogAction = "update"
+	CatalogRemove CatalogAction = "remove"
+	CatalogNone   CatalogAction = "none"
+)
+
+// CatalogChange is what syncing a catalog does to one registry
+type CatalogChange struct {
+	Name   string
+	Action CatalogAction
+	// Changes names what an update changes
+	Changes []string
+	// Helper is the credential helper the catalog sets, shown so it isn't run
+	// without being seen
+	Helper string
+
+	registry *PluginRegistry
+}
+
+// PlanCatalog compares a catalog with the installed registries. Registries
+// only in the catalog are added, managed registries are updated to match it,
+// and managed registries that left it are removed. A registry that was added
+// by hand is adopted if the catalog agrees on its URL, and is a conflict
+// otherwise
+func (p *PluginRegistries) PlanCatalog(catalog *Catalog) ([]CatalogChange, error) {
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	changes := []CatalogChange{}
+	listed := map[string]bool{}
+	for _, entry := range catalog.Registries {
+		listed[entry.Name] = true
+
+		wanted, err := entry.registry()
+		if err != nil {
+			return nil, err
+		}
+
+		change := CatalogChange{Name: entry.Name, registry: wanted}
+		if wanted.Auth != nil {
+			change.Helper = wanted.Auth.Helper
+		}
+
+		current, ok := p.r[entry.Name]
+		switch {
+		case !ok:
+			change.Action = CatalogAdd
+		case !current.Managed && current.URL.String() != wanted.URL.String():
+			return nil, fmt.Errorf("registry %s is already installed from %s, but the catalog has it at %s. Rename or remove it before syncing", entry.Name, current.URL, wanted.URL)
+		default:
+			change.Changes = registryDifferences(current, wanted)
+			change.Action = CatalogUpdate
+			if !current.Managed {
+				change.Action = CatalogAdopt
+			} else if len(change.Changes) == 0 {
+				change.Action = CatalogNone
+			}
+
+			// only a new or changed helper needs to be seen again
+			if current.Auth != nil && wanted.Auth != nil && current.Auth.Helper == wanted.Auth.Helper {
+				change.Helper = ""
+			}
+		}
+
+		changes = append(changes, change)
+	}
+
+	for name, reg := range p.r {
+		if reg.Managed && !listed[name] {
+			changes = append(changes, CatalogChange{Name: name, Action: CatalogRemove})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+
+	return changes, nil
+}
+
+// registry is the catalog entry as a managed registry
+func (c CatalogRegistry) registry() (*PluginRegistry, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u, err = ParseLocation(u); err != nil {
+		return nil, err
+	}
+
+	mirrors, err := parseMirrors(c.Mirrors)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PluginRegistry{
+		URL:         u,
+		IndexKey:    strings.TrimSpace(c.IndexKey),
+		TrustedKeys: c.TrustedKeys,
+		Auth:        c.Auth,
+		Mirrors:     mirrors,
+		Managed:     true,
+	}, nil
+}
+
+func registryDifferences(current, wanted *PluginRegistry) []string {
+	changed := []string{}
+	if current.URL.String() != wanted.URL.String() {
+		changed = append(changed, "url")
+	}
+
+	if strings.TrimSpace(current.IndexKey) != wanted.IndexKey {
+		changed = append(changed, "index key")
+	}
+
+	if !reflect.DeepEqual(trustedKeyMap(current.TrustedKeys), trustedKeyMap(wanted.TrustedKeys)) {
+		changed = append(changed, "trusted keys")
+	}
+
+	if current.Auth.Describe() != wanted.Auth.Describe() || (current.Auth != nil && wanted.Auth != nil && current.Auth.Helper != wanted.Auth.Helper) {
+		changed = append(changed, "authentication")
+	}
+
+	if strings.Join(mirrorStrings(current.Mirrors), " ") != strings.Join(mirrorStrings(wanted.Mirrors), " ") {
+		changed = append(changed, "mirrors")
+	}
+
+	return changed
+}
+
+func trustedKeyMap(keys []runner.TrustedKey) map[string]string {
+	m := make(map[string]string, len(keys))
+	for _, k := range keys {
+		m[k.Name] = strings.TrimSpace(k.Key)
+	}
+
+	return m
+}
+
+// ApplyCatalog makes the installed registries match a planned catalog sync
+// and remembers where the catalog came from. The HTTP options of an adopted
+// registry are kept, they belong to this machine rather than the catalog
+func (p *PluginRegistries) ApplyCatalog(source CatalogSource, changes []CatalogChange) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for _, c := range changes {
+		switch c.Action {
+		case CatalogAdd:
+			p.r[c.Name] = c.registry
+		case CatalogAdopt, CatalogUpdate:
+			c.registry.HTTP = p.r[c.Name].HTTP
+			p.r[c.Name] = c.registry
+		case CatalogRemove:
+			p.deleteLocked(c.Name)
+		}
+	}
+
+	p.catalog = &source
+}
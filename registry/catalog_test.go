@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+func TestPlanCatalog(t *testing.T) {
+	platform := CatalogRegistry{
+		Name:        "platform",
+		URL:         "https://plugins.example.com",
+		TrustedKeys: []runner.TrustedKey{{Name: "release", Key: "RWQ"}},
+		Auth:        &RegistryAuth{Helper: "example-token"},
+		Mirrors:     []string{"https://mirror.example.com"},
+	}
+	legacy := CatalogRegistry{Name: "legacy", URL: "https://legacy.example.com"}
+
+	tests := []struct {
+		name       string
+		registries []CatalogRegistry
+		// changes are "name action [changes] helper" for every planned change
+		changes  []string
+		conflict bool
+		// helpers are only added or changed by a verified catalog
+		needsKey bool
+	}{
+		{
+			name:       "unchanged",
+			registries: []CatalogRegistry{platform, legacy},
+			changes:    []string{"legacy none []", "platform none []"},
+		},
+		{
+			name: "added",
+			registries: []CatalogRegistry{platform, legacy, {
+				Name: "security",
+				URL:  "https://security.example.com",
+				Auth: &RegistryAuth{Helper: "security-token"},
+			}},
+			changes:  []string{"legacy none []", "platform none []", "security add [] security-token"},
+			needsKey: true,
+		},
+		{
+			name:       "removed",
+			registries: []CatalogRegistry{platform},
+			changes:    []string{"legacy remove []", "platform none []"},
+		},
+		{
+			name: "updated",
+			registries: []CatalogRegistry{legacy, func() CatalogRegistry {
+				c := platform
+				c.URL = "https://plugins.example.com/v2"
+				c.IndexKey = "RWQindex"
+				c.Mirrors = nil
+				return c
+			}()},
+			changes: []string{"legacy none []", "platform update [url index key mirrors]"},
+		},
+		{
+			name: "new credential helper",
+			registries: []CatalogRegistry{legacy, func() CatalogRegistry {
+				c := platform
+				c.Auth = &RegistryAuth{Helper: "other-token"}
+				c.TrustedKeys = nil
+				return c
+			}()},
+			changes:  []string{"legacy none []", "platform update [trusted keys authentication] other-token"},
+			needsKey: true,
+		},
+		{
+			name:       "adopted",
+			registries: []CatalogRegistry{platform, legacy, {Name: "personal", URL: "https://plugins.alice.dev"}},
+			changes:    []string{"legacy none []", "personal adopt []", "platform none []"},
+		},
+		{
+			name:       "adopted with changes",
+			registries: []CatalogRegistry{platform, legacy, {Name: "personal", URL: "https://plugins.alice.dev", IndexKey: "RWQ"}},
+			changes:    []string{"legacy none []", "personal adopt [index key]", "platform none []"},
+		},
+		{
+			name:       "conflict",
+			registries: []CatalogRegistry{platform, legacy, {Name: "personal", URL: "https://plugins.example.org"}},
+			conflict:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := testRegistries(t).PlanCatalog(&Catalog{Registries: tt.registries})
+			if tt.conflict {
+				if err == nil {
+					t.Fatal("expected a conflict")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := make([]string, 0, len(changes))
+			for _, c := range changes {
+				got = append(got, strings.TrimSpace(fmt.Sprintf("%s %s %v %s", c.Name, c.Action, c.Changes, c.Helper)))
+			}
+
+			if !reflect.DeepEqual(got, tt.changes) {
+				t.Errorf("changes = %q, want %q", got, tt.changes)
+			}
+
+			if err = checkHelpers(CatalogSource{}, changes); errors.Is(err, ErrUnverifiedHelper) != tt.needsKey {
+				t.Errorf("unverified catalog: checkHelpers() = %v, want refused = %v", err, tt.needsKey)
+			}
+
+			if err = checkHelpers(CatalogSource{Key: "RWQcatalog"}, changes); err != nil {
+				t.Errorf("verified catalog: checkHelpers() = %v", err)
+			}
+		})
+	}
+}
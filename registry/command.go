@@ -5,4 +5,19 @@ type Command struct {
 	AvailablePlugins *ListAvailablePlugins    `cmd:"" help:"List all plugins available in a registry"`
 	Add              *AddRegistryCommand      `cmd:"" help:"Add a registry from which plugins can be installed"`
 	Remove           *RemoveRegistryCommand   `cmd:"" aliases:"rm" help:"Remove a registry from the local catalog"`
+	Sync             *SyncCommand             `cmd:"" help:"Add and update registries from a catalog shared by your organization"`
+	SetURL           *SetURLCommand           `cmd:"" name:"set-url" help:"Move a registry to a new URL, updating the plugins installed from it"`
+	Rename           *RenameCommand           `cmd:"" help:"Rename a registry, updating the plugins installed from it"`
+	SetDefault       *SetDefaultCommand       `cmd:"" help:"Set the registry used when none is named"`
+	Priority         *PriorityCommand         `cmd:"" help:"Set the order registries are searched for plugins that are installed without naming a registry"`
+	Refresh          *RefreshCommand          `cmd:"" help:"Download the latest index of registries into the local cache"`
+	ConfigureHTTP    *ConfigureHTTPCommand    `cmd:"" name:"configure-http" help:"Set the CA bundle, client certificate and proxy for a registry, or for every registry"`
+	SetMirrors       *SetMirrorsCommand       `cmd:"" help:"Set the mirrors a registry fails over to, in priority order"`
+	CheckMirrors     *CheckMirrorsCommand     `cmd:"" help:"Check that every mirror of a registry is reachable and serves the same plugins"`
+	Lint             *LintCommand             `cmd:"" help:"Check a registry's index for problems"`
+	BuildIndex       *BuildIndexCommand       `cmd:"" help:"Generate a registry's index.yaml from a directory of plugin executables"`
+	Serve            *ServeCommand            `cmd:"" help:"Serve a registry directory over HTTP or HTTPS"`
+	PinKey           *PinKeyCommand           `cmd:"" help:"Pin the key that signs a registry's index, or change it"`
+	TrustKey         *TrustKeyCommand         `cmd:"" help:"Trust a public key to sign the plugins of a registry"`
+	UntrustKey       *UntrustKeyCommand       `cmd:"" help:"Stop trusting a public key for a registry"`
 }
@@ -0,0 +1,247 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// VersionConstraint is a set of version requirements a plugin version must
+// satisfy. Besides the comparison operators understood by semver.ParseRange,
+// it supports pessimistic (~> 1.2), tilde (~1.2.3) and caret (^0.3) operators,
+// partial versions (1.2 is the same as ~1.2), and commas as a logical AND
+type VersionConstraint struct {
+	expr       string
+	r          semver.Range
+	prerelease bool
+}
+
+var (
+	operatorSpacing = regexp.MustCompile(`(~>|\^|~|>=|<=|!=|==|=|>|<|!)\s+`)
+	termPattern     = regexp.MustCompile(`^(~>|\^|~|>=|<=|!=|==|=|>|<|!)?(.+)$`)
+)
+
+// ParseConstraint parses a version constraint. The empty string, "*" and
+// "latest" match every version
+func ParseConstraint(expr string) (VersionConstraint, error) {
+	c := VersionConstraint{expr: strings.TrimSpace(expr)}
+	if c.expr == "" || c.expr == "*" || strings.EqualFold(c.expr, "latest") {
+		c.r = func(semver.Version) bool { return true }
+		return c, nil
+	}
+
+	normalized := operatorSpacing.ReplaceAllString(c.expr, "$1")
+	orGroups := strings.Split(normalized, "||")
+	translated := make([]string, 0, len(orGroups))
+	for _, group := range orGroups {
+		terms := strings.FieldsFunc(group, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(terms) == 0 {
+			return c, fmt.Errorf("invalid version constraint %q: empty expression", c.expr)
+		}
+
+		ranges := []string{}
+		for _, term := range terms {
+			r, pre, err := translateTerm(term)
+			if err != nil {
+				return c, fmt.Errorf("invalid version constraint %q: %w", c.expr, err)
+			}
+
+			c.prerelease = c.prerelease || pre
+			ranges = append(ranges, r...)
+		}
+
+		translated = append(translated, strings.Join(ranges, " "))
+	}
+
+	r, err := semver.ParseRange(strings.Join(translated, " || "))
+	if err != nil {
+		return c, fmt.Errorf("invalid version constraint %q: %w", c.expr, err)
+	}
+
+	c.r = r
+	return c, nil
+}
+
+// Allows reports whether v satisfies the constraint. Pre-release versions are
+// only allowed if allowPrerelease is set or the constraint names a pre-release
+func (c VersionConstraint) Allows(v semver.Version, allowPrerelease bool) bool {
+	if len(v.Pre) > 0 && !allowPrerelease && !c.prerelease {
+		return false
+	}
+
+	return c.r(v)
+}
+
+func (c VersionConstraint) String() string {
+	if c.expr == "" {
+		return "latest"
+	}
+
+	return c.expr
+}
+
+// ResolveVersion returns the newest version of p that satisfies the constraint
+// and can run on this machine. Yanked versions are skipped unless allowed, and
+// ErrVersionYanked is returned if only yanked versions match
+func ResolveVersion(p Plugin, c VersionConstraint, allowPrerelease, allowYanked bool) (PluginVersion, error) {
+	matched := false
+	v, ok := latestCompatible(p, func(sv semver.Version) bool {
+		if c.Allows(sv, allowPrerelease) {
+			matched = true
+			return true
+		}
+		return false
+	}, allowYanked)
+
+	if ok {
+		return v, nil
+	}
+
+	if !allowYanked {
+		if y, ok := latestCompatible(p, func(sv semver.Version) bool {
+			return c.Allows(sv, allowPrerelease)
+		}, true); ok {
+			return PluginVersion{}, fmt.Errorf("%w: %s@%s%s", ErrVersionYanked, p.Name, y.Version, noticeSuffix(y))
+		}
+	}
+
+	if matched {
+		return PluginVersion{}, fmt.Errorf("no version of plugin %q matching %q is compatible with this architecture", p.Name, c)
+	}
+
+	return PluginVersion{}, fmt.Errorf("no version of plugin %q matches %q", p.Name, c)
+}
+
+// partialVersion is a version where the minor and patch numbers may be missing
+type partialVersion struct {
+	parts [3]uint64
+	given int
+	rest  string
+}
+
+func parsePartial(s string) (partialVersion, error) {
+	pv := partialVersion{}
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+
+	core := s
+	if idx := strings.IndexAny(s, "-+"); idx >= 0 {
+		core, pv.rest = s[:idx], s[idx:]
+	}
+
+	for i, part := range strings.Split(core, ".") {
+		if i > 2 {
+			return pv, fmt.Errorf("%q has too many version components", s)
+		}
+
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return pv, fmt.Errorf("%q is not a valid version", s)
+		}
+
+		pv.parts[i] = n
+		pv.given++
+	}
+
+	if pv.given < 3 && pv.rest != "" {
+		return pv, fmt.Errorf("%q must be a full version to have a pre-release or build", s)
+	}
+
+	return pv, nil
+}
+
+func (pv partialVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d%s", pv.parts[0], pv.parts[1], pv.parts[2], pv.rest)
+}
+
+func semverString(major, minor, patch uint64) string {
+	return fmt.Sprintf("%d.%d.%d", major, minor, patch)
+}
+
+// translateTerm converts a single constraint term into semver.ParseRange terms.
+// It also reports whether the term names a pre-release
+func translateTerm(term string) ([]string, bool, error) {
+	m := termPattern.FindStringSubmatch(term)
+	if m == nil {
+		return nil, false, fmt.Errorf("could not parse %q", term)
+	}
+
+	op := m[1]
+	pv, err := parsePartial(m[2])
+	if err != nil {
+		return nil, false, err
+	}
+
+	pre := strings.HasPrefix(pv.rest, "-")
+	major, minor, patch := pv.parts[0], pv.parts[1], pv.parts[2]
+	lower := ">=" + pv.String()
+
+	if pv.given == 0 {
+		if op == "" || op == "=" || op == "==" {
+			return []string{">=0.0.0"}, pre, nil
+		}
+		return nil, false, fmt.Errorf("%q needs a version", term)
+	}
+
+	switch op {
+	case "~>":
+		switch pv.given {
+		case 3:
+			return []string{lower, "<" + semverString(major, minor+1, 0)}, pre, nil
+		default:
+			return []string{lower, "<" + semverString(major+1, 0, 0)}, pre, nil
+		}
+	case "~":
+		if pv.given == 1 {
+			return []string{lower, "<" + semverString(major+1, 0, 0)}, pre, nil
+		}
+		return []string{lower, "<" + semverString(major, minor+1, 0)}, pre, nil
+	case "^":
+		switch {
+		case major > 0 || pv.given == 1:
+			return []string{lower, "<" + semverString(major+1, 0, 0)}, pre, nil
+		case minor > 0 || pv.given == 2:
+			return []string{lower, "<" + semverString(0, minor+1, 0)}, pre, nil
+		default:
+			return []string{lower, "<" + semverString(0, 0, patch+1)}, pre, nil
+		}
+	case "", "=", "==":
+		switch pv.given {
+		case 1:
+			return []string{lower, "<" + semverString(major+1, 0, 0)}, pre, nil
+		case 2:
+			return []string{lower, "<" + semverString(major, minor+1, 0)}, pre, nil
+		default:
+			return []string{"=" + pv.String()}, pre, nil
+		}
+	case ">":
+		// >1.2 means anything after the 1.2 series
+		switch pv.given {
+		case 1:
+			return []string{">=" + semverString(major+1, 0, 0)}, pre, nil
+		case 2:
+			return []string{">=" + semverString(major, minor+1, 0)}, pre, nil
+		}
+	case "<=":
+		switch pv.given {
+		case 1:
+			return []string{"<" + semverString(major+1, 0, 0)}, pre, nil
+		case 2:
+			return []string{"<" + semverString(major, minor+1, 0)}, pre, nil
+		}
+	case "!", "!=":
+		if pv.given < 3 {
+			return nil, false, fmt.Errorf("%q must exclude a full version", term)
+		}
+	}
+
+	return []string{op + pv.String()}, pre, nil
+}
@@ -0,0 +1,102 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+)
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		expr    string
+		allowed []string
+		denied  []string
+		invalid bool
+	}{
+		{expr: "", allowed: []string{"0.0.1", "1.0.0", "99.1.2"}},
+		{expr: "*", allowed: []string{"0.0.1", "99.1.2"}},
+		{expr: "latest", allowed: []string{"1.0.0"}},
+		{expr: "1.2.3", allowed: []string{"1.2.3"}, denied: []string{"1.2.4", "1.2.2"}},
+		{expr: "=1.2.3", allowed: []string{"1.2.3"}, denied: []string{"1.2.4"}},
+		{expr: "v1.2.3", allowed: []string{"1.2.3"}, denied: []string{"1.2.4"}},
+		{expr: "1.2", allowed: []string{"1.2.0", "1.2.9"}, denied: []string{"1.3.0", "1.1.9"}},
+		{expr: "1", allowed: []string{"1.0.0", "1.9.9"}, denied: []string{"2.0.0", "0.9.0"}},
+		{expr: "1.x", allowed: []string{"1.0.0", "1.9.9"}, denied: []string{"2.0.0"}},
+		{expr: "~> 1.2", allowed: []string{"1.2.0", "1.9.0"}, denied: []string{"2.0.0", "1.1.0"}},
+		{expr: "~> 1.2.3", allowed: []string{"1.2.3", "1.2.9"}, denied: []string{"1.3.0", "1.2.2"}},
+		{expr: "~1.2.3", allowed: []string{"1.2.3", "1.2.9"}, denied: []string{"1.3.0"}},
+		{expr: "~1", allowed: []string{"1.0.0", "1.9.0"}, denied: []string{"2.0.0"}},
+		{expr: "^1.2.3", allowed: []string{"1.2.3", "1.9.0"}, denied: []string{"2.0.0", "1.2.2"}},
+		{expr: "^0.3", allowed: []string{"0.3.0", "0.3.9"}, denied: []string{"0.4.0", "0.2.9"}},
+		{expr: "^0.0.3", allowed: []string{"0.0.3"}, denied: []string{"0.0.4"}},
+		{expr: ">1.2", allowed: []string{"1.3.0"}, denied: []string{"1.2.9", "1.2.0"}},
+		{expr: "<=1.2", allowed: []string{"1.2.9", "1.0.0"}, denied: []string{"1.3.0"}},
+		{expr: ">= 1.0, < 2.0", allowed: []string{"1.0.0", "1.9.9"}, denied: []string{"0.9.9", "2.0.0"}},
+		{expr: ">=1.0.0 <2.0.0 !=1.5.0", allowed: []string{"1.4.0", "1.5.1"}, denied: []string{"1.5.0"}},
+		{expr: "1.x || >=3.0.0", allowed: []string{"1.2.0", "3.1.0"}, denied: []string{"2.0.0"}},
+		{expr: ">>> nope", invalid: true},
+		{expr: "1.2.3.4", invalid: true},
+		{expr: "1.2-beta", invalid: true},
+		{expr: "!=1.2", invalid: true},
+		{expr: "~>", invalid: true},
+		{expr: "1.0.0 ||", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			c, err := ParseConstraint(tt.expr)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("expected %q to be invalid", tt.expr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, v := range tt.allowed {
+				if !c.Allows(semver.MustParse(v), false) {
+					t.Errorf("%q should allow %s", tt.expr, v)
+				}
+			}
+
+			for _, v := range tt.denied {
+				if c.Allows(semver.MustParse(v), false) {
+					t.Errorf("%q should not allow %s", tt.expr, v)
+				}
+			}
+		})
+	}
+}
+
+func TestConstraintPrerelease(t *testing.T) {
+	tests := []struct {
+		expr            string
+		version         string
+		allowPrerelease bool
+		allowed         bool
+	}{
+		{expr: "", version: "2.0.0-beta.1"},
+		{expr: "", version: "2.0.0-beta.1", allowPrerelease: true, allowed: true},
+		{expr: ">=1.0.0", version: "2.0.0-rc.1"},
+		{expr: ">=1.0.0", version: "2.0.0-rc.1", allowPrerelease: true, allowed: true},
+		{expr: "2.0.0-beta.1", version: "2.0.0-beta.1", allowed: true},
+		{expr: ">=2.0.0-beta.1", version: "2.0.0-beta.2", allowed: true},
+		{expr: ">=2.0.0-beta.1", version: "2.0.0-alpha.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr+" "+tt.version, func(t *testing.T) {
+			c, err := ParseConstraint(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if allowed := c.Allows(semver.MustParse(tt.version), tt.allowPrerelease); allowed != tt.allowed {
+				t.Errorf("allowed = %v, want %v", allowed, tt.allowed)
+			}
+		})
+	}
+}
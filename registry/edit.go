@@ -0,0 +1,164 @@
+package registry
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+type SetURLCommand struct {
+	Name  string   `arg:"" help:"The name of the registry"`
+	URL   *url.URL `arg:"" help:"The new HTTPS URL hosting the registry's index.yaml, an HTTP URL on localhost, or the file:// URL or path of a directory holding it"`
+	Force bool     `help:"Move the registry even if the index at the new URL has errors"`
+	r     *PluginRegistries
+}
+
+func (s *SetURLCommand) BeforeApply() error {
+	var err error
+	s.r, err = LoadFromDisk()
+	return err
+}
+
+func (s *SetURLCommand) Run(ctx *kong.Context) error {
+	if err := s.r.checkMutable(s.Name); err != nil {
+		return err
+	}
+
+	reg := s.r.Get(s.Name)
+	location, err := ParseLocation(s.URL)
+	if err != nil {
+		return err
+	}
+
+	previous := reg.URL
+	if location.String() == previous.String() {
+		fmt.Fprintf(ctx.Stdout, "%s is already at %s\n", s.Name, location)
+		return nil
+	}
+
+	// the index at the new URL must still verify with the pinned key
+	moved := reg.Clone()
+	moved.URL = location
+	moved.servedFrom = nil
+	if _, err = moved.Refresh(); err != nil {
+		return fmt.Errorf("could not load the index at %s: %w", location, err)
+	}
+
+	if err = lintRegistry(ctx, moved, s.Force, "move the registry"); err != nil {
+		return err
+	}
+
+	migration, err := runner.MigrateBOMSources(func(source *runner.PluginSource) bool {
+		if source.Type != "registry" || source.Name != s.Name || source.URL != previous.String() {
+			return false
+		}
+
+		source.URL = location.String()
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = s.r.SetURL(s.Name, location); err == nil {
+		err = s.r.SaveToDisk()
+	}
+
+	if err != nil {
+		return rollback(migration, err)
+	}
+
+	fmt.Fprintf(ctx.Stdout, "Moved %s from %s to %s\n", s.Name, previous, location)
+	writeMigrated(ctx, migration)
+
+	return DropCache(previous)
+}
+
+type RenameCommand struct {
+	Name    string `arg:"" help:"The name of the registry"`
+	NewName string `arg:"" help:"The registry's new name"`
+	r       *PluginRegistries
+}
+
+func (r *RenameCommand) BeforeApply() error {
+	var err error
+	r.r, err = LoadFromDisk()
+	return err
+}
+
+func (r *RenameCommand) Run(ctx *kong.Context) error {
+	if err := r.r.Rename(r.Name, r.NewName); err != nil {
+		return err
+	}
+
+	newName := strings.TrimSpace(r.NewName)
+	migration, err := runner.MigrateBOMSources(func(source *runner.PluginSource) bool {
+		if source.Type != "registry" || source.Name != r.Name {
+			return false
+		}
+
+		source.Name = newName
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = r.r.SaveToDisk(); err != nil {
+		return rollback(migration, err)
+	}
+
+	fmt.Fprintf(ctx.Stdout, "Renamed %s to %s\n", r.Name, newName)
+	writeMigrated(ctx, migration)
+
+	if len(migration.Migrated) > 0 {
+		fmt.Fprintf(ctx.Stderr, "warning: plugins.yaml and lock files that name registry %s need to name %s instead\n", r.Name, newName)
+	}
+
+	return nil
+}
+
+type SetDefaultCommand struct {
+	Name string `arg:"" help:"The name of the registry to use when none is named"`
+	r    *PluginRegistries
+}
+
+func (s *SetDefaultCommand) BeforeApply() error {
+	var err error
+	s.r, err = LoadFromDisk()
+	return err
+}
+
+func (s *SetDefaultCommand) Run(ctx *kong.Context) error {
+	if err := s.r.SetDefault(s.Name); err != nil {
+		return err
+	}
+
+	if err := s.r.SaveToDisk(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(ctx.Stdout, "%s is now the default registry\n", s.Name)
+	return nil
+}
+
+// rollback undoes a migration of installed plugins when the registry catalog
+// couldn't be saved, so the two never disagree
+func rollback(migration *runner.SourceMigration, err error) error {
+	if rbErr := migration.Rollback(); rbErr != nil {
+		return fmt.Errorf("%w. The installed plugins could not be restored to match: %v", err, rbErr)
+	}
+
+	return err
+}
+
+func writeMigrated(ctx *kong.Context, migration *runner.SourceMigration) {
+	if len(migration.Migrated) == 0 {
+		return
+	}
+
+	fmt.Fprintf(ctx.Stdout, "Updated the source of %d installed plugins: %s\n", len(migration.Migrated), strings.Join(migration.Migrated, ", "))
+}
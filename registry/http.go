@@ -0,0 +1,99 @@
+package registry
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+// HTTPFlags are the HTTP options that can be set for one registry, or for all
+// of them
+type HTTPFlags struct {
+	CABundle   string `type:"existingfile" group:"TLS and proxy" help:"A PEM file of certificate authorities to trust in addition to the system's"`
+	ClientCert string `type:"existingfile" group:"TLS and proxy" help:"A PEM client certificate for mutual TLS. Requires --client-key"`
+	ClientKey  string `type:"existingfile" group:"TLS and proxy" help:"The PEM private key of --client-cert"`
+	Proxy      string `group:"TLS and proxy" help:"The URL of the proxy to use instead of $HTTPS_PROXY and $HTTP_PROXY"`
+	NoProxy    string `group:"TLS and proxy" help:"Hosts to reach without the proxy, in the format of $NO_PROXY"`
+}
+
+// apply returns o with every flag that was set replacing its option. Files are
+// stored as absolute paths, so they're found from any directory
+func (f HTTPFlags) apply(o runner.HTTPOptions) (runner.HTTPOptions, error) {
+	for _, p := range []struct {
+		flag   string
+		option *string
+	}{
+		{f.CABundle, &o.CABundle},
+		{f.ClientCert, &o.ClientCert},
+		{f.ClientKey, &o.ClientKey},
+	} {
+		if p.flag == "" {
+			continue
+		}
+
+		abs, err := filepath.Abs(p.flag)
+		if err != nil {
+			return o, err
+		}
+		*p.option = abs
+	}
+
+	if f.Proxy != "" {
+		o.Proxy = f.Proxy
+	}
+
+	if f.NoProxy != "" {
+		o.NoProxy = f.NoProxy
+	}
+
+	// building a client checks the files can be loaded before they're saved
+	if _, err := runner.NewHTTPClient(o); err != nil {
+		return o, err
+	}
+
+	return o, nil
+}
+
+type ConfigureHTTPCommand struct {
+	Name  string `arg:"" optional:"" help:"The registry to configure. Defaults to the options shared by every registry"`
+	Reset bool   `help:"Clear the options before setting the ones given"`
+
+	HTTPFlags `embed:""`
+
+	r *PluginRegistries
+}
+
+func (c *ConfigureHTTPCommand) BeforeApply() error {
+	var err error
+	c.r, err = LoadFromDisk()
+	return err
+}
+
+func (c *ConfigureHTTPCommand) Run(ctx *kong.Context) error {
+	current := c.r.HTTPOptions()
+	if c.Name != "" {
+		if err := c.r.checkMutable(c.Name); err != nil {
+			return fmt.Errorf("%w. Configure the options shared by every registry instead, by leaving out the registry", err)
+		}
+
+		reg := c.r.Get(c.Name)
+		current = reg.HTTP
+	}
+
+	if c.Reset {
+		current = runner.HTTPOptions{}
+	}
+
+	options, err := c.apply(current)
+	if err != nil {
+		return err
+	}
+
+	if err = c.r.SetHTTPOptions(c.Name, options); err != nil {
+		return err
+	}
+
+	return c.r.SaveToDisk()
+}
@@ -0,0 +1,124 @@
+package registry
+
+import (
+	"github.com/gideaworx/terraform-exporter-plugin-registry/registry"
+)
+
+// The types in this file mirror the index.yaml schema of
+// terraform-exporter-plugin-registry, extended with the fields the CLI
+// understands that the upstream schema doesn't have yet. Indexes written for
+// the upstream schema decode unchanged
+
+type PluginType = registry.PluginType
+
+const (
+	Native = registry.Native
+	Python = registry.Python
+	NodeJS = registry.NodeJS
+	Java   = registry.Java
+)
+
+type TargetArchitecture = registry.TargetArchitecture
+
+const (
+	DarwinAmd64  = registry.DarwinAmd64
+	DarwinArm64  = registry.DarwinArm64
+	LinuxAmd64   = registry.LinuxAmd64
+	LinuxArm64   = registry.LinuxArm64
+	WindowsAmd64 = registry.WindowsAmd64
+	WindowsArm64 = registry.WindowsArm64
+	MultiArch    = registry.MultiArch
+)
+
+type PluginAuthor = registry.PluginAuthor
+
+type ExecutableInfo struct {
+	ExtraArgs []string `yaml:"args,omitempty"`
+	Checksum  string   `yaml:"sha256sum,omitempty"`
+	// Checksums maps hash algorithms to checksums of the executable, for
+	// registries that publish more than a sha256 checksum
+	Checksums map[string]string `yaml:"checksums,omitempty"`
+	// Signature is a detached signature of the executable
+	Signature *ArtifactSignature `yaml:"signature,omitempty"`
+}
+
+type ArtifactSignature struct {
+	// Format is minisign (the default) or cosign
+	Format string `yaml:"format,omitempty"`
+	// Locator is the URL of the signature. A relative URL is resolved against
+	// the executable's locator
+	Locator string `yaml:"locator"`
+}
+
+type PluginExecutable struct {
+	Locator string         `yaml:"locator"`
+	Type    PluginType     `yaml:"type"`
+	Info    ExecutableInfo `yaml:"info"`
+}
+
+type PluginVersion struct {
+	Version      string                                  `yaml:"version"`
+	DownloadInfo map[TargetArchitecture]PluginExecutable `yaml:"download"`
+	// Yanked versions were withdrawn, because they're broken or malicious, and
+	// are only installed when asked for with --allow-yanked
+	Yanked bool `yaml:"yanked,omitempty"`
+	// Deprecated versions can still be installed, with a warning
+	Deprecated bool `yaml:"deprecated,omitempty"`
+	// Notice tells users why the version was yanked or deprecated
+	Notice string `yaml:"notice,omitempty"`
+}
+
+// PluginCommand is an exporter command a plugin provides
+type PluginCommand struct {
+	Name    string `yaml:"name"`
+	Summary string `yaml:"summary,omitempty"`
+}
+
+type Plugin struct {
+	Name        string               `yaml:"name"`
+	Description string               `yaml:"description"`
+	Homepage    string               `yaml:"homepage"`
+	LastUpdated registry.ISO8601Time `yaml:"lastUpdated"`
+	Authors     []PluginAuthor       `yaml:"authors"`
+	Versions    []PluginVersion      `yaml:"versions"`
+	// Commands lists the exporter commands the plugin provides, for registries
+	// that publish them
+	Commands []PluginCommand `yaml:"commands,omitempty"`
+}
+
+// Advisory is a security advisory for some versions of a plugin
+type Advisory struct {
+	ID     string `yaml:"id"`
+	Plugin string `yaml:"plugin"`
+	// Severity is one of low, medium, high or critical
+	Severity string `yaml:"severity"`
+	Summary  string `yaml:"summary"`
+	// Affected is a version constraint matching the affected versions
+	Affected string `yaml:"affected"`
+	// Fixed is the first version with the fix, empty if there's no fix yet
+	Fixed string `yaml:"fixed,omitempty"`
+	URL   string `yaml:"url,omitempty"`
+}
+
+type Index struct {
+	Name    string   `yaml:"name"`
+	BaseURL string   `yaml:"baseURL"`
+	Plugins []Plugin `yaml:"plugins,omitempty"`
+	// Advisories is the registry's feed of security advisories
+	Advisories []Advisory `yaml:"advisories,omitempty"`
+}
+
+// AllChecksums returns every checksum the registry publishes for the
+// executable, keyed by algorithm
+func (e PluginExecutable) AllChecksums() map[string]string {
+	checksums := make(map[string]string, len(e.Info.Checksums)+1)
+	for alg, sum := range e.Info.Checksums {
+		checksums[alg] = sum
+	}
+
+	if e.Info.Checksum != "" {
+		checksums["sha256"] = e.Info.Checksum
+	}
+
+	return checksums
+}
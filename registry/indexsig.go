@@ -0,0 +1,127 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+const (
+	indexFileName          = "index.yaml"
+	indexSignatureFileName = "index.yaml.sig"
+	// indexKeyFileName is where a registry publishes the key that signs its
+	// index, for trust on first use
+	indexKeyFileName = "index.yaml.pub"
+
+	// indexes are small, anything bigger than this is not an index
+	maxIndexSize = 32 * 1024 * 1024
+)
+
+var (
+	ErrNotPublished        = errors.New("not published by the registry")
+	ErrIndexSignature      = errors.New("index signature verification failed")
+	ErrIndexSignatureMatch = errors.New("the index is not signed by the expected key")
+)
+
+// fetchRegistryFile downloads a file from base, the registry's URL or one of
+// its mirrors. A missing file is reported as ErrNotPublished
+func (r *PluginRegistry) fetchRegistryFile(base *url.URL, name string) ([]byte, error) {
+	body, err := r.Open(base.JoinPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s is %w", name, ErrNotPublished)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	contents, err := io.ReadAll(io.LimitReader(body, maxIndexSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contents) > maxIndexSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", name, maxIndexSize)
+	}
+
+	return contents, nil
+}
+
+// verifyIndex checks the signature of an index against the key pinned for the
+// registry. Registries without a pinned key are not verified
+func (r *PluginRegistry) verifyIndex(index, signature []byte) error {
+	if r.IndexKey == "" {
+		return nil
+	}
+
+	if signature == nil {
+		return fmt.Errorf("%w: %s is %v", ErrIndexSignature, indexSignatureFileName, ErrNotPublished)
+	}
+
+	return verifyIndexSignature(r.URL, r.IndexKey, index, signature)
+}
+
+func verifyIndexSignature(base *url.URL, key string, index, signature []byte) error {
+	format, _, err := runner.ParsePublicKey(key)
+	if err != nil {
+		return fmt.Errorf("%w: the pinned key is invalid: %v", ErrIndexSignature, err)
+	}
+
+	_, err = runner.VerifySignature(format, index, signature, []runner.TrustedKey{{Name: "index", Key: key}})
+	if errors.Is(err, runner.ErrSignatureInvalid) {
+		return fmt.Errorf("%w of %s", ErrIndexSignatureMatch, base)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIndexSignature, err)
+	}
+
+	return nil
+}
+
+// PinnableIndexKey returns the key to pin for a registry and checks the
+// registry's current index is signed with it. Without an explicit key, the key
+// the registry publishes is trusted on first use. A registry that doesn't sign
+// its index has no key to pin, and an empty key is returned
+func (r *PluginRegistry) PinnableIndexKey(key string) (string, error) {
+	base := r.URL
+	index, err := r.fetchRegistryFile(base, indexFileName)
+	if err != nil {
+		return "", err
+	}
+
+	if key == "" {
+		published, err := r.fetchRegistryFile(base, indexKeyFileName)
+		if errors.Is(err, ErrNotPublished) {
+			if _, err = r.fetchRegistryFile(base, indexSignatureFileName); err == nil {
+				return "", fmt.Errorf("registry %s signs its index but does not publish %s, pass its key with --key", base, indexKeyFileName)
+			}
+
+			return "", nil
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		key = string(published)
+	}
+
+	key = strings.TrimSpace(key)
+	signature, err := r.fetchRegistryFile(base, indexSignatureFileName)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrIndexSignature, err)
+	}
+
+	if err = verifyIndexSignature(base, key, index, signature); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
@@ -0,0 +1,413 @@
+package registry
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kong"
+	"github.com/blang/semver/v4"
+	"github.com/gideaworx/terraform-exporter/runner"
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+
+	// how many locators are checked at once
+	locatorCheckers = 8
+)
+
+var knownArchitectures = map[TargetArchitecture]bool{
+	DarwinAmd64:  true,
+	DarwinArm64:  true,
+	LinuxAmd64:   true,
+	LinuxArm64:   true,
+	WindowsAmd64: true,
+	WindowsArm64: true,
+	MultiArch:    true,
+}
+
+// LintIssue is a problem found in a registry index
+type LintIssue struct {
+	Severity string
+	// Location is the plugin, version and architecture the issue is about,
+	// empty for the index as a whole
+	Location string
+	Message  string
+}
+
+// LintResult is the outcome of linting a registry index
+type LintResult struct {
+	Issues []LintIssue
+	// Index is the parsed index, nil if it couldn't be parsed
+	Index *Index
+}
+
+func (l *LintResult) add(severity, location, format string, args ...interface{}) {
+	l.Issues = append(l.Issues, LintIssue{Severity: severity, Location: location, Message: fmt.Sprintf(format, args...)})
+}
+
+// Errors returns how many of the issues are errors
+func (l *LintResult) Errors() int {
+	count := 0
+	for _, issue := range l.Issues {
+		if issue.Severity == SeverityError {
+			count++
+		}
+	}
+
+	return count
+}
+
+// LintIndex checks an index against the registry schema and the rules the CLI
+// relies on to install plugins from it
+func LintIndex(contents []byte) *LintResult {
+	result := &LintResult{}
+
+	index := &Index{}
+	if err := yaml.Unmarshal(contents, index); err != nil {
+		result.add(SeverityError, "", "the index is not valid YAML for the registry schema: %v", err)
+		return result
+	}
+	result.Index = index
+
+	if index.Name == "" {
+		result.add(SeverityWarning, "", "the index has no name")
+	}
+
+	if len(index.Plugins) == 0 {
+		result.add(SeverityWarning, "", "the index lists no plugins")
+	}
+
+	plugins := map[string]bool{}
+	for i, p := range index.Plugins {
+		location := p.Name
+		if p.Name == "" {
+			location = fmt.Sprintf("plugins[%d]", i)
+			result.add(SeverityError, location, "the plugin has no name")
+		} else if plugins[p.Name] {
+			result.add(SeverityError, location, "the plugin is listed more than once")
+		}
+		plugins[p.Name] = true
+
+		if p.Description == "" {
+			result.add(SeverityWarning, location, "the plugin has no description")
+		}
+
+		if len(p.Versions) == 0 {
+			result.add(SeverityError, location, "the plugin has no versions")
+		}
+
+		versions := map[string]bool{}
+		for _, v := range p.Versions {
+			vLocation := fmt.Sprintf("%s@%s", location, v.Version)
+			sv, err := semver.ParseTolerant(v.Version)
+			if err != nil {
+				result.add(SeverityError, vLocation, "%q is not a semantic version: %v", v.Version, err)
+			} else if versions[sv.String()] {
+				result.add(SeverityError, vLocation, "the version is listed more than once")
+			} else {
+				versions[sv.String()] = true
+			}
+
+			if len(v.DownloadInfo) == 0 {
+				result.add(SeverityError, vLocation, "the version has no downloads")
+			}
+
+			if v.Yanked && v.Deprecated {
+				result.add(SeverityWarning, vLocation, "the version is both yanked and deprecated, yanked wins")
+			}
+
+			if v.Notice != "" && !v.Yanked && !v.Deprecated {
+				result.add(SeverityWarning, vLocation, "the version has a notice but is neither yanked nor deprecated")
+			}
+
+			archs := make([]string, 0, len(v.DownloadInfo))
+			for arch := range v.DownloadInfo {
+				archs = append(archs, string(arch))
+			}
+			sort.Strings(archs)
+
+			for _, arch := range archs {
+				lintExecutable(result, fmt.Sprintf("%s %s", vLocation, arch), TargetArchitecture(arch), v.DownloadInfo[TargetArchitecture(arch)])
+			}
+		}
+	}
+
+	lintAdvisories(result, index, plugins)
+
+	return result
+}
+
+func lintAdvisories(result *LintResult, index *Index, plugins map[string]bool) {
+	ids := map[string]bool{}
+	for i, a := range index.Advisories {
+		location := fmt.Sprintf("advisory %s", a.ID)
+		if a.ID == "" {
+			location = fmt.Sprintf("advisories[%d]", i)
+			result.add(SeverityError, location, "the advisory has no id")
+		} else if ids[a.ID] {
+			result.add(SeverityError, location, "the advisory is listed more than once")
+		}
+		ids[a.ID] = true
+
+		if !plugins[a.Plugin] {
+			result.add(SeverityWarning, location, "plugin %q is not in the index", a.Plugin)
+		}
+
+		if SeverityRank(a.Severity) == 0 {
+			result.add(SeverityError, location, "unknown severity %q, use low, medium, high or critical", a.Severity)
+		}
+
+		if a.Summary == "" {
+			result.add(SeverityWarning, location, "the advisory has no summary")
+		}
+
+		if a.Affected == "" {
+			result.add(SeverityError, location, "the advisory doesn't say which versions are affected")
+		} else if _, err := ParseConstraint(a.Affected); err != nil {
+			result.add(SeverityError, location, "affected versions %q: %v", a.Affected, err)
+			continue
+		}
+
+		if a.Fixed != "" {
+			if _, err := semver.ParseTolerant(a.Fixed); err != nil {
+				result.add(SeverityError, location, "fixed version %q is not a semantic version: %v", a.Fixed, err)
+			} else if affected, _ := a.Affects(a.Fixed); affected {
+				result.add(SeverityError, location, "the fixed version %s is in the affected range %q", a.Fixed, a.Affected)
+			}
+		}
+	}
+}
+
+func lintExecutable(result *LintResult, location string, arch TargetArchitecture, exe PluginExecutable) {
+	if !knownArchitectures[arch] {
+		result.add(SeverityError, location, "unknown target architecture %q", arch)
+	}
+
+	if exe.Locator == "" {
+		result.add(SeverityError, location, "the download has no locator")
+	}
+
+	switch exe.Type {
+	case Native:
+	case Python, NodeJS:
+		// packages are checksummed by their package manager, not the index
+		return
+	case Java:
+		result.add(SeverityWarning, location, "java plugins can't be installed yet")
+		return
+	default:
+		result.add(SeverityError, location, "unknown plugin type %q", exe.Type)
+		return
+	}
+
+	checksums := exe.AllChecksums()
+	if len(checksums) == 0 {
+		result.add(SeverityError, location, "the executable has no checksum")
+	}
+
+	for alg, sum := range checksums {
+		h, err := runner.NewHash(alg)
+		if err != nil {
+			result.add(SeverityError, location, "%v", err)
+			continue
+		}
+
+		if decoded, err := hex.DecodeString(sum); err != nil || len(decoded) != h.Size() {
+			result.add(SeverityError, location, "the %s checksum %q is not %d hex encoded bytes", alg, sum, h.Size())
+		}
+	}
+
+	if sig := exe.Info.Signature; sig != nil {
+		switch strings.ToLower(sig.Format) {
+		case "", runner.SignatureMinisign, runner.SignatureCosign:
+		default:
+			result.add(SeverityError, location, "unknown signature format %q", sig.Format)
+		}
+
+		if sig.Locator == "" {
+			result.add(SeverityError, location, "the signature has no locator")
+		}
+	}
+}
+
+// CheckLocators makes sure every native executable and signature in the index
+// can be downloaded from the registry
+func (r *PluginRegistry) CheckLocators(result *LintResult) {
+	if result.Index == nil {
+		return
+	}
+
+	type check struct {
+		location string
+		locator  string
+	}
+
+	checks := []check{}
+	for _, p := range result.Index.Plugins {
+		for _, v := range p.Versions {
+			for arch, exe := range v.DownloadInfo {
+				if exe.Type != Native || exe.Locator == "" {
+					continue
+				}
+
+				location := fmt.Sprintf("%s@%s %s", p.Name, v.Version, arch)
+				checks = append(checks, check{location, exe.Locator})
+
+				if sig := exe.Info.Signature; sig != nil && sig.Locator != "" {
+					// signature locators are relative to the executable
+					if exeURL, err := r.ResolveLocator(exe.Locator); err == nil {
+						if sigURL, err := url.Parse(sig.Locator); err == nil {
+							checks = append(checks, check{location, exeURL.ResolveReference(sigURL).String()})
+						}
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].location < checks[j].location
+	})
+
+	unreachable := make([]string, len(checks))
+	work := make(chan int)
+	wg := &sync.WaitGroup{}
+	for n := 0; n < locatorCheckers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				body, _, err := r.OpenArtifact(checks[i].locator)
+				if err != nil {
+					unreachable[i] = err.Error()
+					continue
+				}
+				body.Close()
+			}
+		}()
+	}
+
+	for i := range checks {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	for i, c := range checks {
+		if unreachable[i] != "" {
+			result.add(SeverityError, c.location, "%s can't be downloaded: %s", c.locator, unreachable[i])
+		}
+	}
+}
+
+type LintCommand struct {
+	Registry     string `arg:"" help:"The registry to lint: the name of an installed registry, the URL of a registry, or the path of an index.yaml or a registry directory"`
+	SkipLocators bool   `help:"Don't check that every executable and signature can be downloaded"`
+	r            *PluginRegistries
+}
+
+func (l *LintCommand) BeforeApply() error {
+	var err error
+	l.r, err = LoadFromDisk()
+	return err
+}
+
+func (l *LintCommand) Run(ctx *kong.Context) error {
+	reg, contents, err := l.resolve()
+	if err != nil {
+		return err
+	}
+
+	result := LintIndex(contents)
+	if !l.SkipLocators {
+		reg.CheckLocators(result)
+	}
+
+	if len(result.Issues) == 0 {
+		fmt.Fprintf(ctx.Stdout, "%s: no problems found\n", reg.URL)
+		return nil
+	}
+
+	writeLintIssues(ctx, result)
+
+	if errs := result.Errors(); errs > 0 {
+		return fmt.Errorf("%s has %d errors and %d warnings", reg.URL, errs, len(result.Issues)-errs)
+	}
+
+	return nil
+}
+
+// resolve finds the registry to lint and reads its index. Installed registries
+// are read with their credentials and options, bypassing the cache
+func (l *LintCommand) resolve() (*PluginRegistry, []byte, error) {
+	reg := l.r.Get(l.Registry)
+	if reg == nil {
+		u, err := url.Parse(l.Registry)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if u.Scheme == "" {
+			path := l.Registry
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				// an index file is linted with its directory as the registry
+				contents, err := os.ReadFile(path)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				dir, err := runner.FileURL(filepath.Dir(path))
+				if err != nil {
+					return nil, nil, err
+				}
+
+				return &PluginRegistry{URL: dir}, contents, nil
+			}
+		}
+
+		if u, err = ParseLocation(u); err != nil {
+			return nil, nil, err
+		}
+		reg = &PluginRegistry{URL: u}
+	}
+
+	contents, err := reg.fetchRegistryFile(reg.URL, indexFileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return reg, contents, nil
+}
+
+func writeLintIssues(ctx *kong.Context, result *LintResult) {
+	sort.SliceStable(result.Issues, func(i, j int) bool {
+		return result.Issues[i].Severity < result.Issues[j].Severity
+	})
+
+	table := tablewriter.NewWriter(ctx.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Severity", "Location", "Problem"})
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetHeaderColor(tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor}, tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor}, tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor})
+	table.SetHeaderLine(true)
+
+	for _, issue := range result.Issues {
+		severity := tablewriter.Colors{tablewriter.Bold, tablewriter.FgYellowColor}
+		if issue.Severity == SeverityError {
+			severity = tablewriter.Colors{tablewriter.Bold, tablewriter.FgRedColor}
+		}
+
+		table.Rich([]string{issue.Severity, issue.Location, issue.Message}, []tablewriter.Colors{severity, {tablewriter.FgCyanColor}, {}})
+	}
+	table.Render()
+}
@@ -0,0 +1,230 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+)
+
+const lintSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func TestLintIndex(t *testing.T) {
+	tests := []struct {
+		name  string
+		index string
+		// issues are the expected issues as "severity: message", where the
+		// message only has to be the start of the actual one
+		issues []string
+	}{
+		{
+			name: "clean",
+			index: `name: example
+plugins:
+  - name: aws
+    description: Exports AWS resources
+    versions:
+      - version: 1.0.0
+        download:
+          linux/amd64:
+            locator: aws-1.0.0-linux-amd64
+            type: native
+            info:
+              sha256sum: ` + lintSHA256 + `
+              signature:
+                locator: aws-1.0.0-linux-amd64.minisig
+          multi-arch:
+            locator: aws
+            type: nodejs
+advisories:
+  - id: EX-1
+    plugin: aws
+    severity: high
+    summary: Leaks credentials
+    affected: "<1.0.0"
+    fixed: 1.0.0
+`,
+		},
+		{
+			name:   "not YAML",
+			index:  "plugins: [",
+			issues: []string{"error: the index is not valid YAML"},
+		},
+		{
+			name:   "empty",
+			index:  "{}",
+			issues: []string{"warning: the index has no name", "warning: the index lists no plugins"},
+		},
+		{
+			name: "plugin problems",
+			index: `name: example
+plugins:
+  - description: No name
+    versions: []
+  - name: aws
+    description: Exports AWS resources
+    versions:
+      - version: one
+        download:
+          linux/amd64:
+            locator: aws
+            type: native
+            info:
+              sha256sum: ` + lintSHA256 + `
+  - name: aws
+    description: Again
+    versions:
+      - version: 1.0.0
+        download: {}
+      - version: v1.0.0
+        yanked: true
+        deprecated: true
+        download:
+          linux/amd64:
+            locator: aws
+            type: native
+            info:
+              sha256sum: ` + lintSHA256 + `
+`,
+			issues: []string{
+				"error: the plugin has no name",
+				"error: the plugin has no versions",
+				`error: "one" is not a semantic version`,
+				"error: the plugin is listed more than once",
+				"error: the version has no downloads",
+				"error: the version is listed more than once",
+				"warning: the version is both yanked and deprecated",
+			},
+		},
+		{
+			name: "executable problems",
+			index: `name: example
+plugins:
+  - name: aws
+    description: Exports AWS resources
+    versions:
+      - version: 1.0.0
+        notice: Please upgrade
+        download:
+          plan9/amd64:
+            locator: aws-plan9
+            type: native
+            info:
+              sha256sum: ` + lintSHA256 + `
+          linux/amd64:
+            type: native
+          linux/arm64:
+            locator: aws-arm64
+            type: native
+            info:
+              sha256sum: abc
+              checksums:
+                md5: d41d8cd98f00b204e9800998ecf8427e
+              signature:
+                format: pgp
+          darwin/arm64:
+            locator: aws.jar
+            type: java
+          windows/amd64:
+            locator: aws.exe
+            type: dotnet
+`,
+			issues: []string{
+				"warning: the version has a notice but is neither yanked nor deprecated",
+				"warning: java plugins can't be installed yet",
+				"error: the download has no locator",
+				"error: the executable has no checksum",
+				`error: the sha256 checksum "abc" is not 32 hex encoded bytes`,
+				"error: unknown hash algorithm",
+				`error: unknown signature format "pgp"`,
+				"error: the signature has no locator",
+				`error: unknown target architecture "plan9/amd64"`,
+				`error: unknown plugin type "dotnet"`,
+			},
+		},
+		{
+			name: "advisory problems",
+			index: `name: example
+plugins:
+  - name: aws
+    description: Exports AWS resources
+    versions:
+      - version: 1.0.0
+        download:
+          multi-arch:
+            locator: aws
+            type: python
+advisories:
+  - plugin: gcp
+    severity: urgent
+    affected: "*"
+  - id: EX-1
+    plugin: aws
+    severity: low
+    summary: Something
+    affected: ">>> nope"
+  - id: EX-1
+    plugin: aws
+    severity: medium
+    summary: Something else
+  - id: EX-2
+    plugin: aws
+    severity: critical
+    summary: Still broken
+    affected: "<=1.2"
+    fixed: 1.1.0
+  - id: EX-3
+    plugin: aws
+    severity: high
+    summary: Bad fix
+    affected: "<1.0.0"
+    fixed: soon
+`,
+			issues: []string{
+				"error: the advisory has no id",
+				`warning: plugin "gcp" is not in the index`,
+				`error: unknown severity "urgent"`,
+				"warning: the advisory has no summary",
+				`error: affected versions ">>> nope"`,
+				"error: the advisory is listed more than once",
+				"error: the advisory doesn't say which versions are affected",
+				`error: the fixed version 1.1.0 is in the affected range "<=1.2"`,
+				`error: fixed version "soon" is not a semantic version`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := LintIndex([]byte(tt.index))
+
+			found := make([]bool, len(tt.issues))
+			errors := 0
+			for _, issue := range result.Issues {
+				if issue.Severity == SeverityError {
+					errors++
+				}
+
+				expected := false
+				for i, want := range tt.issues {
+					if strings.HasPrefix(issue.Severity+": "+issue.Message, want) {
+						found[i] = true
+						expected = true
+					}
+				}
+
+				if !expected {
+					t.Errorf("unexpected %s at %q: %s", issue.Severity, issue.Location, issue.Message)
+				}
+			}
+
+			for i, want := range tt.issues {
+				if !found[i] {
+					t.Errorf("missing issue %q", want)
+				}
+			}
+
+			if result.Errors() != errors {
+				t.Errorf("Errors() = %d, want %d", result.Errors(), errors)
+			}
+		})
+	}
+}
@@ -9,13 +9,12 @@ import (
 
 	"github.com/alecthomas/kong"
 	"github.com/blang/semver/v4"
-	"github.com/gideaworx/terraform-exporter-plugin-registry/registry"
 	"github.com/gideaworx/terraform-exporter/runner"
 	"github.com/olekukonko/tablewriter"
 )
 
 type ListAvailablePlugins struct {
-	Name                 string `short:"n" default:"default" help:"The registry to list available plugins from"`
+	Name                 string `short:"n" help:"The registry to list available plugins from. Defaults to the default registry"`
 	ExcludeInstalled     bool   `short:"x" help:"If true, hide plugins that are already installed"`
 	ShowAllArchitectures bool   `short:"a" help:"If true, show plugins available for any architecture, not just the local architecture"`
 	r                    *PluginRegistries
@@ -28,6 +27,10 @@ func (l *ListAvailablePlugins) BeforeApply() error {
 }
 
 func (l *ListAvailablePlugins) Run(ctx *kong.Context) error {
+	if l.Name == "" {
+		l.Name = l.r.DefaultName()
+	}
+
 	registry := l.r.Get(l.Name)
 	if registry == nil {
 		return fmt.Errorf("plugin registry %q not found", l.Name)
@@ -75,6 +78,12 @@ func (l *ListAvailablePlugins) Run(ctx *kong.Context) error {
 	table.SetColumnColor(columnColors...)
 
 	for _, plugin := range registry.Plugins {
+		// a plugin without versions can't be installed, "registry lint"
+		// reports it
+		if len(plugin.Versions) == 0 {
+			continue
+		}
+
 		if !l.exclude(plugin) {
 			row := []string{plugin.Name, plugin.Description, plugin.Versions[0].Version}
 			if !l.ExcludeInstalled {
@@ -93,7 +102,7 @@ func (l *ListAvailablePlugins) Run(ctx *kong.Context) error {
 	return nil
 }
 
-func (l *ListAvailablePlugins) exclude(plugin registry.Plugin) bool {
+func (l *ListAvailablePlugins) exclude(plugin Plugin) bool {
 	if l.ShowAllArchitectures && !l.ExcludeInstalled {
 		return true
 	}
@@ -104,9 +113,9 @@ func (l *ListAvailablePlugins) exclude(plugin registry.Plugin) bool {
 		goos := runtime.GOOS
 		goarch := runtime.GOARCH
 
-		arch := registry.TargetArchitecture(fmt.Sprintf("%s/%s", strings.ToLower(goos), strings.ToLower(goarch)))
+		arch := TargetArchitecture(fmt.Sprintf("%s/%s", strings.ToLower(goos), strings.ToLower(goarch)))
 		for availableArch := range latestVersion.DownloadInfo {
-			if arch == availableArch || availableArch == registry.MultiArch {
+			if arch == availableArch || availableArch == MultiArch {
 				foundArch = true
 				break
 			}
@@ -120,7 +129,7 @@ func (l *ListAvailablePlugins) exclude(plugin registry.Plugin) bool {
 	return l.ExcludeInstalled && l.isInstalled(plugin)
 }
 
-func (l *ListAvailablePlugins) isInstalled(plugin registry.Plugin) bool {
+func (l *ListAvailablePlugins) isInstalled(plugin Plugin) bool {
 	boms, err := runner.LoadInstalledBOMs()
 	if err != nil {
 		log.Println(err)
@@ -1,7 +1,10 @@
 package registry
 
 import (
+	"strings"
+
 	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter/runner"
 	"github.com/olekukonko/tablewriter"
 )
 
@@ -17,16 +20,42 @@ func (l *ListInstalledRegistries) BeforeApply() error {
 
 func (l *ListInstalledRegistries) Run(ctx *kong.Context) error {
 	registries := l.r.GetAll()
+	defaultName := l.r.DefaultName()
 
 	table := tablewriter.NewWriter(ctx.Stdout)
 	table.SetAutoWrapText(false)
-	table.SetHeader([]string{"Registry Name", "URL"})
+	table.SetHeader([]string{"Registry Name", "URL", "Index Key", "Trusted Keys", "Authentication"})
 	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
-	table.SetHeaderColor(tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor}, tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor})
-	table.SetColumnColor(tablewriter.Colors{tablewriter.Bold, tablewriter.FgCyanColor}, tablewriter.Colors{tablewriter.FgYellowColor})
+	table.SetHeaderColor(tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor}, tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor}, tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor}, tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor}, tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor})
+	table.SetColumnColor(tablewriter.Colors{tablewriter.Bold, tablewriter.FgCyanColor}, tablewriter.Colors{tablewriter.FgYellowColor}, tablewriter.Colors{}, tablewriter.Colors{}, tablewriter.Colors{})
 	table.SetHeaderLine(true)
 	for n, r := range registries {
-		table.Append([]string{n, r.URL.String()})
+		keys := make([]string, 0, len(r.TrustedKeys))
+		for _, k := range r.TrustedKeys {
+			keys = append(keys, k.Name)
+		}
+
+		indexKey := "unsigned"
+		if r.IndexKey != "" {
+			_, indexKey, _ = runner.ParsePublicKey(r.IndexKey)
+		}
+
+		// credentials in a URL never end up in a terminal or a CI log
+		location := r.URL.Redacted()
+		for _, m := range r.Mirrors {
+			location += "\n  mirror: " + m.Redacted()
+		}
+
+		name := n
+		if n == defaultName {
+			name += " (default)"
+		}
+
+		if r.Managed {
+			name += " (managed)"
+		}
+
+		table.Append([]string{name, location, indexKey, strings.Join(keys, ", "), r.Auth.Describe()})
 	}
 	table.Render()
 	return nil
@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/alecthomas/kong"
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+)
+
+type SetMirrorsCommand struct {
+	Name    string     `arg:"" help:"The name of the registry"`
+	Mirrors []*url.URL `arg:"" optional:"" help:"The mirrors of the registry, in the order they should be tried. No mirrors removes them all"`
+	r       *PluginRegistries
+}
+
+func (s *SetMirrorsCommand) BeforeApply() error {
+	var err error
+	s.r, err = LoadFromDisk()
+	return err
+}
+
+func (s *SetMirrorsCommand) Run(ctx *kong.Context) error {
+	if err := s.r.checkMutable(s.Name); err != nil {
+		return err
+	}
+
+	mirrors := make([]*url.URL, 0, len(s.Mirrors))
+	for _, m := range s.Mirrors {
+		u, err := ParseLocation(m)
+		if err != nil {
+			return err
+		}
+		mirrors = append(mirrors, u)
+	}
+
+	if err := s.r.SetMirrors(s.Name, mirrors); err != nil {
+		return err
+	}
+
+	if err := s.r.SaveToDisk(); err != nil {
+		return err
+	}
+
+	if len(mirrors) == 0 {
+		fmt.Fprintf(ctx.Stdout, "Removed the mirrors of %s\n", s.Name)
+	} else {
+		fmt.Fprintf(ctx.Stdout, "Set %d mirrors for %s, check them with \"registry check-mirrors %s\"\n", len(mirrors), s.Name, s.Name)
+	}
+
+	return nil
+}
+
+type CheckMirrorsCommand struct {
+	Name string `arg:"" help:"The name of the registry"`
+	r    *PluginRegistries
+}
+
+func (c *CheckMirrorsCommand) BeforeApply() error {
+	var err error
+	c.r, err = LoadFromDisk()
+	return err
+}
+
+func (c *CheckMirrorsCommand) Run(ctx *kong.Context) error {
+	if cacheOptions.Offline {
+		return fmt.Errorf("mirrors can't be checked with --offline")
+	}
+
+	reg := c.r.Get(c.Name)
+	if reg == nil {
+		return fmt.Errorf("registry %q not installed", c.Name)
+	}
+
+	table := tablewriter.NewWriter(ctx.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Source", "Status", "Plugins", "Details"})
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetHeaderColor(tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor}, tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor}, tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor}, tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor})
+	table.SetColumnColor(tablewriter.Colors{tablewriter.FgYellowColor}, tablewriter.Colors{tablewriter.Bold}, tablewriter.Colors{}, tablewriter.Colors{})
+	table.SetHeaderLine(true)
+
+	// every mirror is compared with the first source that could be read, the
+	// registry itself unless it's down
+	var reference []byte
+	failed := 0
+	for _, base := range reg.Sources() {
+		status, plugins, details := "ok", "", ""
+
+		fetched, _, err := reg.fetchIndex(base, nil)
+		if err == nil && reference != nil {
+			err = checkConsistency(reference, fetched.index)
+		}
+
+		var unreachable *url.Error
+		switch {
+		case errors.As(err, &unreachable):
+			status, details = "unreachable", err.Error()
+		case errors.Is(err, ErrMirrorInconsistent):
+			status, details = "inconsistent", err.Error()
+		case err != nil:
+			status, details = "error", err.Error()
+		default:
+			var index Index
+			if err = yaml.Unmarshal(fetched.index, &index); err != nil {
+				status, details = "error", err.Error()
+				break
+			}
+
+			plugins = fmt.Sprint(len(index.Plugins))
+			if reference == nil {
+				reference = fetched.index
+			}
+		}
+
+		if status != "ok" {
+			failed++
+		}
+
+		table.Append([]string{base.String(), status, plugins, details})
+	}
+	table.Render()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sources of %s can't be used", failed, len(reg.Sources()), c.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/gideaworx/terraform-exporter/runner"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrMirrorInconsistent = errors.New("mirror is inconsistent with the registry")
+	ErrLocalLocator       = errors.New("only file:// registries can list file:// locators")
+	ErrMirrorUnverified   = errors.New("mirror's index can't be verified")
+)
+
+// Sources returns the URLs the registry is served from, its own URL first and
+// then its mirrors in the order they're tried
+func (r *PluginRegistry) Sources() []*url.URL {
+	return append([]*url.URL{r.URL}, r.Mirrors...)
+}
+
+// ServedFrom returns the URL or mirror the registry's index was loaded from
+func (r *PluginRegistry) ServedFrom() *url.URL {
+	if r.servedFrom == nil {
+		return r.URL
+	}
+
+	return r.servedFrom
+}
+
+// OpenArtifact opens an executable or signature listed in the registry's
+// index, trying the source that served the index first and failing over to the
+// others. It returns the URL that was opened
+func (r *PluginRegistry) OpenArtifact(locator string) (io.ReadCloser, *url.URL, error) {
+	candidates, err := r.artifactCandidates(locator)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	failures := []string{}
+	var lastErr error
+	for _, u := range candidates {
+		body, err := r.Open(u)
+		if err == nil {
+			return body, u, nil
+		}
+
+		lastErr = err
+		failures = append(failures, fmt.Sprintf("%s: %v", u, err))
+	}
+
+	if len(candidates) == 1 {
+		return nil, nil, lastErr
+	}
+
+	return nil, nil, fmt.Errorf("%w. No mirror could serve %s: %s", lastErr, locator, strings.Join(failures, "; "))
+}
+
+// MirrorOf returns the mirror an artifact was downloaded from, or "" if it
+// came from the registry itself or a host outside the registry
+func (r *PluginRegistry) MirrorOf(downloaded *url.URL) string {
+	for _, m := range r.Mirrors {
+		if strings.HasPrefix(downloaded.String(), strings.TrimSuffix(m.String(), "/")+"/") {
+			return m.String()
+		}
+	}
+
+	return ""
+}
+
+// artifactCandidates returns the URLs a locator can be downloaded from. A
+// relative locator, or one under the URL of the registry or a mirror, is
+// available from every source. Any other locator is hosted elsewhere and has
+// a single URL. A registry served over http can't make this machine read
+// its own files, so only file:// registries can list file:// locators
+func (r *PluginRegistry) artifactCandidates(locator string) ([]*url.URL, error) {
+	ref, err := url.Parse(locator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid locator %q: %w", locator, err)
+	}
+
+	if ref.Scheme == "file" && r.URL.Scheme != "file" {
+		return nil, fmt.Errorf("%w: %s lists %s", ErrLocalLocator, r.URL.Redacted(), locator)
+	}
+
+	sources := []*url.URL{r.ServedFrom()}
+	for _, s := range r.Sources() {
+		if s.String() != sources[0].String() {
+			sources = append(sources, s)
+		}
+	}
+
+	var relative string
+	if !ref.IsAbs() {
+		relative = locator
+	} else {
+		for _, s := range sources {
+			prefix := strings.TrimSuffix(s.String(), "/") + "/"
+			if strings.HasPrefix(locator, prefix) {
+				relative = strings.TrimPrefix(locator, prefix)
+				break
+			}
+		}
+
+		if relative == "" {
+			return []*url.URL{ref}, nil
+		}
+	}
+
+	rel, err := url.Parse(relative)
+	if err != nil {
+		return nil, fmt.Errorf("invalid locator %q: %w", locator, err)
+	}
+
+	candidates := make([]*url.URL, 0, len(sources))
+	for _, s := range sources {
+		candidates = append(candidates, resolveAgainst(s, rel))
+	}
+
+	return candidates, nil
+}
+
+func resolveAgainst(base *url.URL, ref *url.URL) *url.URL {
+	b := *base
+	if !strings.HasSuffix(b.Path, "/") {
+		b.Path += "/"
+	}
+
+	return b.ResolveReference(ref)
+}
+
+// checkConsistency makes sure a mirror's index agrees with the registry's
+// about the executables of every plugin version both list. A mirror may lag
+// behind and miss versions, but must never serve different executables
+func checkConsistency(known, candidate []byte) error {
+	var knownIndex, candidateIndex Index
+	if err := yaml.Unmarshal(known, &knownIndex); err != nil {
+		return err
+	}
+
+	if err := yaml.Unmarshal(candidate, &candidateIndex); err != nil {
+		return fmt.Errorf("%w: %v", ErrMirrorInconsistent, err)
+	}
+
+	executables := map[string]PluginExecutable{}
+	for _, p := range knownIndex.Plugins {
+		for _, v := range p.Versions {
+			for arch, exe := range v.DownloadInfo {
+				executables[fmt.Sprintf("%s@%s %s", p.Name, v.Version, arch)] = exe
+			}
+		}
+	}
+
+	for _, p := range candidateIndex.Plugins {
+		for _, v := range p.Versions {
+			for arch, exe := range v.DownloadInfo {
+				key := fmt.Sprintf("%s@%s %s", p.Name, v.Version, arch)
+				expected, ok := executables[key]
+				if !ok {
+					continue
+				}
+
+				if exe.Type != expected.Type {
+					return fmt.Errorf("%w: %s is a %s plugin, not %s", ErrMirrorInconsistent, key, expected.Type, exe.Type)
+				}
+
+				expectedSums := expected.AllChecksums()
+				for alg, sum := range exe.AllChecksums() {
+					if want, ok := expectedSums[alg]; ok && !strings.EqualFold(want, sum) {
+						return fmt.Errorf("%w: the %s checksum of %s differs", ErrMirrorInconsistent, alg, key)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParseLocation parses the location of a registry or mirror: an HTTPS URL, a
+// file:// URL or the path of a directory
+func ParseLocation(u *url.URL) (*url.URL, error) {
+	switch u.Scheme {
+	case "https", "file":
+		return u, nil
+	case "http":
+		// plain HTTP is only trusted on this machine, for registries run with
+		// "registry serve"
+		if isLoopback(u.Hostname()) {
+			return u, nil
+		}
+	case "":
+		// a plain path is a registry directory on a local or network drive
+		return runner.FileURL(u.Path)
+	}
+
+	return nil, fmt.Errorf("specified url %s has scheme %q, but it must have https or file, or http on localhost", u, u.Scheme)
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func parseMirrors(mirrors []string) ([]*url.URL, error) {
+	urls := make([]*url.URL, 0, len(mirrors))
+	for _, m := range mirrors {
+		u, err := url.Parse(m)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mirror %q: %w", m, err)
+		}
+		urls = append(urls, u)
+	}
+
+	return urls, nil
+}
+
+func mirrorStrings(mirrors []*url.URL) []string {
+	strs := make([]string, 0, len(mirrors))
+	for _, m := range mirrors {
+		strs = append(strs, m.String())
+	}
+
+	return strs
+}
@@ -0,0 +1,245 @@
+package registry
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+const consistencyIndex = `name: example
+plugins:
+  - name: aws
+    versions:
+      - version: 1.0.0
+        download:
+          linux/amd64:
+            locator: aws-1.0.0-linux-amd64
+            type: native
+            info:
+              sha256sum: 1111111111111111111111111111111111111111111111111111111111111111
+      - version: 1.1.0
+        download:
+          linux/amd64:
+            locator: aws-1.1.0-linux-amd64
+            type: native
+            info:
+              sha256sum: 2222222222222222222222222222222222222222222222222222222222222222
+              checksums:
+                sha512: abcd
+`
+
+func TestCheckConsistency(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidate  string
+		consistent bool
+	}{
+		{
+			name:       "identical",
+			candidate:  consistencyIndex,
+			consistent: true,
+		},
+		{
+			name: "missing versions",
+			candidate: `name: example
+plugins:
+  - name: aws
+    versions:
+      - version: 1.0.0
+        download:
+          linux/amd64:
+            locator: https://mirror.example.com/aws-1.0.0-linux-amd64
+            type: native
+            info:
+              sha256sum: 1111111111111111111111111111111111111111111111111111111111111111
+`,
+			consistent: true,
+		},
+		{
+			name: "versions the registry doesn't have",
+			candidate: `plugins:
+  - name: gcp
+    versions:
+      - version: 1.0.0
+        download:
+          linux/amd64:
+            locator: gcp
+            type: native
+            info:
+              sha256sum: 3333333333333333333333333333333333333333333333333333333333333333
+`,
+			consistent: true,
+		},
+		{
+			name: "checksum case",
+			candidate: `plugins:
+  - name: aws
+    versions:
+      - version: 1.1.0
+        download:
+          linux/amd64:
+            locator: aws-1.1.0-linux-amd64
+            type: native
+            info:
+              sha256sum: 2222222222222222222222222222222222222222222222222222222222222222
+              checksums:
+                sha512: ABCD
+`,
+			consistent: true,
+		},
+		{
+			name: "different checksum",
+			candidate: `plugins:
+  - name: aws
+    versions:
+      - version: 1.0.0
+        download:
+          linux/amd64:
+            locator: aws-1.0.0-linux-amd64
+            type: native
+            info:
+              sha256sum: 9999999999999999999999999999999999999999999999999999999999999999
+`,
+		},
+		{
+			name: "different extra checksum",
+			candidate: `plugins:
+  - name: aws
+    versions:
+      - version: 1.1.0
+        download:
+          linux/amd64:
+            locator: aws-1.1.0-linux-amd64
+            type: native
+            info:
+              sha256sum: 2222222222222222222222222222222222222222222222222222222222222222
+              checksums:
+                sha512: ffff
+`,
+		},
+		{
+			name: "different type",
+			candidate: `plugins:
+  - name: aws
+    versions:
+      - version: 1.0.0
+        download:
+          linux/amd64:
+            locator: aws
+            type: nodejs
+`,
+		},
+		{
+			name:      "not YAML",
+			candidate: "plugins: [",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkConsistency([]byte(consistencyIndex), []byte(tt.candidate))
+			if tt.consistent && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if !tt.consistent && !errors.Is(err, ErrMirrorInconsistent) {
+				t.Errorf("err = %v, want %v", err, ErrMirrorInconsistent)
+			}
+		})
+	}
+}
+
+func TestArtifactCandidates(t *testing.T) {
+	mustParse := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	remote := &PluginRegistry{
+		URL:     mustParse("https://plugins.example.com/index"),
+		Mirrors: []*url.URL{mustParse("https://mirror.example.com/plugins")},
+	}
+	local := &PluginRegistry{URL: mustParse("file:///srv/plugins")}
+
+	tests := []struct {
+		name       string
+		registry   *PluginRegistry
+		locator    string
+		candidates []string
+		local      bool
+	}{
+		{
+			name:     "relative locator",
+			registry: remote,
+			locator:  "aws/aws-1.0.0",
+			candidates: []string{
+				"https://plugins.example.com/index/aws/aws-1.0.0",
+				"https://mirror.example.com/plugins/aws/aws-1.0.0",
+			},
+		},
+		{
+			name:     "absolute locator on the registry",
+			registry: remote,
+			locator:  "https://plugins.example.com/index/aws/aws-1.0.0",
+			candidates: []string{
+				"https://plugins.example.com/index/aws/aws-1.0.0",
+				"https://mirror.example.com/plugins/aws/aws-1.0.0",
+			},
+		},
+		{
+			name:       "absolute locator elsewhere",
+			registry:   remote,
+			locator:    "https://downloads.example.org/aws-1.0.0",
+			candidates: []string{"https://downloads.example.org/aws-1.0.0"},
+		},
+		{
+			name:     "file locator on a remote registry",
+			registry: remote,
+			locator:  "file:///etc/passwd",
+			local:    true,
+		},
+		{
+			name:       "file locator on a local registry",
+			registry:   local,
+			locator:    "file:///srv/plugins/aws-1.0.0",
+			candidates: []string{"file:///srv/plugins/aws-1.0.0"},
+		},
+		{
+			name:       "relative locator on a local registry",
+			registry:   local,
+			locator:    "aws-1.0.0",
+			candidates: []string{"file:///srv/plugins/aws-1.0.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates, err := tt.registry.artifactCandidates(tt.locator)
+			if tt.local {
+				if !errors.Is(err, ErrLocalLocator) {
+					t.Errorf("err = %v, want %v", err, ErrLocalLocator)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := mirrorStrings(candidates)
+			if len(got) != len(tt.candidates) {
+				t.Fatalf("candidates = %v, want %v", got, tt.candidates)
+			}
+
+			for i := range got {
+				if got[i] != tt.candidates[i] {
+					t.Errorf("candidates = %v, want %v", got, tt.candidates)
+					break
+				}
+			}
+		})
+	}
+}
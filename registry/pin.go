@@ -0,0 +1,46 @@
+package registry
+
+import (
+	"fmt"
+
+	"github.com/alecthomas/kong"
+)
+
+type PinKeyCommand struct {
+	Name  string `arg:"" help:"The name of the registry"`
+	Key   string `short:"k" type:"existingfile" help:"The public key that signs the registry's index.yaml. Defaults to the key the registry publishes as index.yaml.pub"`
+	Unpin bool   `help:"Stop verifying the registry's index"`
+	r     *PluginRegistries
+}
+
+func (p *PinKeyCommand) BeforeApply() error {
+	var err error
+	p.r, err = LoadFromDisk()
+	return err
+}
+
+func (p *PinKeyCommand) Run(ctx *kong.Context) error {
+	if err := p.r.checkMutable(p.Name); err != nil {
+		return err
+	}
+
+	reg := p.r.Get(p.Name)
+
+	var key string
+	if !p.Unpin {
+		var err error
+		if key, err = pinKey(ctx, reg, p.Key); err != nil {
+			return err
+		}
+
+		if key == "" {
+			return fmt.Errorf("registry %s does not sign its index, there is no key to pin", p.Name)
+		}
+	}
+
+	if err := p.r.PinIndexKey(p.Name, key); err != nil {
+		return err
+	}
+
+	return p.r.SaveToDisk()
+}
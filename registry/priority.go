@@ -0,0 +1,210 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kong"
+)
+
+var (
+	ErrPluginAmbiguous     = errors.New("plugin is in more than one registry")
+	ErrPluginNotInRegistry = errors.New("plugin not found in any registry")
+	ErrRegistryUnsearched  = errors.New("registries that are searched first could not be searched")
+)
+
+// SplitPluginRef splits a plugin reference of the form registry/plugin. The
+// registry is empty if the reference doesn't name one
+func SplitPluginRef(ref string) (string, string) {
+	registryName, pluginName, found := strings.Cut(ref, "/")
+	if !found {
+		return "", ref
+	}
+
+	return registryName, pluginName
+}
+
+// Priority returns the names of every registry in the order they're searched
+// for plugins: the configured priority first, then the others by name, then
+// the default registry. A public default registry is searched last so it can't
+// shadow a private registry's plugins
+func (p *PluginRegistries) Priority() []string {
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	ranked := map[string]bool{}
+	names := make([]string, 0, len(p.r))
+	for _, n := range p.priority {
+		if _, ok := p.r[n]; ok && !ranked[n] {
+			ranked[n] = true
+			names = append(names, n)
+		}
+	}
+
+	defaultName := p.defaultLocked()
+	unranked := []string{}
+	for n := range p.r {
+		if !ranked[n] && n != defaultName {
+			unranked = append(unranked, n)
+		}
+	}
+	sort.Strings(unranked)
+
+	names = append(names, unranked...)
+
+	// the default registry may have been removed
+	if _, ok := p.r[defaultName]; ok && !ranked[defaultName] {
+		names = append(names, defaultName)
+	}
+
+	return names
+}
+
+// IsRanked reports whether the registry has an explicit priority
+func (p *PluginRegistries) IsRanked(name string) bool {
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	for _, n := range p.priority {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetPriority sets the order registries are searched for plugins. Registries
+// that aren't listed are searched after the listed ones
+func (p *PluginRegistries) SetPriority(names []string) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	seen := map[string]bool{}
+	for _, n := range names {
+		if _, ok := p.r[n]; !ok {
+			return fmt.Errorf("registry %q not installed", n)
+		}
+
+		if seen[n] {
+			return fmt.Errorf("registry %q is listed more than once", n)
+		}
+		seen[n] = true
+	}
+
+	p.priority = names
+	return nil
+}
+
+// ResolveRegistry finds the registry to install a plugin from when none was
+// named. The plugin is installed from the first registry by priority that has
+// it. When it's in several registries and the first isn't explicitly ranked,
+// there's no telling which was meant and ErrPluginAmbiguous is returned. When
+// a registry searched before the first that has it could not be loaded, it
+// might have had the plugin too and ErrRegistryUnsearched is returned. The
+// other registries with the plugin are returned too
+func (p *PluginRegistries) ResolveRegistry(pluginName string) (string, []string, error) {
+	names := p.Priority()
+
+	found := make([]bool, len(names))
+	failures := make([]error, len(names))
+
+	wg := &sync.WaitGroup{}
+	for i, n := range names {
+		wg.Add(1)
+		go func(i int, n string) {
+			defer wg.Done()
+
+			reg := p.Get(n)
+			if err := reg.LazyLoad(); err != nil {
+				failures[i] = fmt.Errorf("%s: %w", n, err)
+				return
+			}
+
+			_, found[i] = reg.FindPlugin(pluginName)
+		}(i, n)
+	}
+	wg.Wait()
+
+	matches := []string{}
+	unavailable := []string{}
+	for i, n := range names {
+		if found[i] {
+			matches = append(matches, n)
+		}
+
+		// a registry searched after a match can't change which one is used
+		if failures[i] != nil && len(matches) == 0 {
+			unavailable = append(unavailable, failures[i].Error())
+		}
+	}
+
+	if len(matches) == 0 {
+		if len(unavailable) > 0 {
+			return "", nil, fmt.Errorf("%w: %s. These registries could not be searched: %s", ErrPluginNotInRegistry, pluginName, strings.Join(unavailable, "; "))
+		}
+
+		return "", nil, fmt.Errorf("%w: %s", ErrPluginNotInRegistry, pluginName)
+	}
+
+	if len(unavailable) > 0 {
+		return "", matches, fmt.Errorf("%w: %s is in %s, but might be in a registry searched before it: %s. Name the registry as %s/%s or with --registry", ErrRegistryUnsearched, pluginName, matches[0], strings.Join(unavailable, "; "), matches[0], pluginName)
+	}
+
+	if len(matches) > 1 && !p.IsRanked(matches[0]) {
+		choices := make([]string, 0, len(matches))
+		for _, m := range matches {
+			choices = append(choices, fmt.Sprintf("%s/%s", m, pluginName))
+		}
+
+		return "", matches, fmt.Errorf("%w: %s is in %s. Install one of %s, name the registry with --registry, or rank the registries with \"registry priority\"", ErrPluginAmbiguous, pluginName, strings.Join(matches, ", "), strings.Join(choices, ", "))
+	}
+
+	return matches[0], matches[1:], nil
+}
+
+type PriorityCommand struct {
+	Names []string `arg:"" optional:"" help:"The registries to search first for plugins, highest priority first. Registries that aren't listed are searched after them by name, and the default registry last. Without registries, the current order is shown"`
+	Reset bool     `help:"Remove the configured priority"`
+	r     *PluginRegistries
+}
+
+func (c *PriorityCommand) BeforeApply() error {
+	var err error
+	c.r, err = LoadFromDisk()
+	return err
+}
+
+func (c *PriorityCommand) Run(ctx *kong.Context) error {
+	if c.Reset && len(c.Names) > 0 {
+		return errors.New("--reset cannot be combined with registry names")
+	}
+
+	if c.Reset || len(c.Names) > 0 {
+		if err := c.r.SetPriority(c.Names); err != nil {
+			return err
+		}
+
+		if err := c.r.SaveToDisk(); err != nil {
+			return err
+		}
+	}
+
+	for i, n := range c.r.Priority() {
+		ranked := ""
+		switch {
+		case c.r.IsRanked(n):
+		case n == c.r.DefaultName():
+			ranked = " (default)"
+		default:
+			ranked = " (unranked)"
+		}
+
+		fmt.Fprintf(ctx.Stdout, "%d. %s%s\n", i+1, n, ranked)
+	}
+
+	return nil
+}
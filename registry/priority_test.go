@@ -0,0 +1,246 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+// testRegistries returns installed registries for the registry tests: the
+// default registry, one added by hand and two synced from a catalog
+func testRegistries(t *testing.T) *PluginRegistries {
+	t.Helper()
+
+	parse := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	return &PluginRegistries{
+		r: map[string]*PluginRegistry{
+			defaultRegistryName: {URL: parse(defaultRegistryURLStr)},
+			"personal":          {URL: parse("https://plugins.alice.dev")},
+			"platform": {
+				URL:         parse("https://plugins.example.com"),
+				TrustedKeys: []runner.TrustedKey{{Name: "release", Key: "RWQ"}},
+				Auth:        &RegistryAuth{Helper: "example-token"},
+				Mirrors:     []*url.URL{parse("https://mirror.example.com")},
+				Managed:     true,
+			},
+			"legacy": {URL: parse("https://legacy.example.com"), Managed: true},
+		},
+		m: new(sync.RWMutex),
+	}
+}
+
+func TestPriority(t *testing.T) {
+	tests := []struct {
+		name        string
+		priority    []string
+		defaultName string
+		removed     string
+		order       []string
+	}{
+		{
+			name:  "default last",
+			order: []string{"legacy", "personal", "platform", defaultRegistryName},
+		},
+		{
+			name:     "ranked before the default",
+			priority: []string{"platform", "personal"},
+			order:    []string{"platform", "personal", "legacy", defaultRegistryName},
+		},
+		{
+			name:     "ranked default",
+			priority: []string{"platform", defaultRegistryName},
+			order:    []string{"platform", defaultRegistryName, "legacy", "personal"},
+		},
+		{
+			name:        "another default",
+			defaultName: "personal",
+			order:       []string{defaultRegistryName, "legacy", "platform", "personal"},
+		},
+		{
+			name:     "ranked registry that was removed",
+			priority: []string{"gone", "legacy"},
+			order:    []string{"legacy", "personal", "platform", defaultRegistryName},
+		},
+		{
+			name:    "default registry removed",
+			removed: defaultRegistryName,
+			order:   []string{"legacy", "personal", "platform"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testRegistries(t)
+			p.priority = tt.priority
+			p.defaultName = tt.defaultName
+			if tt.removed != "" {
+				p.Delete(tt.removed)
+			}
+
+			if order := p.Priority(); !reflect.DeepEqual(order, tt.order) {
+				t.Errorf("Priority() = %q, want %q", order, tt.order)
+			}
+		})
+	}
+}
+
+func TestResolveRegistry(t *testing.T) {
+	work := t.TempDir()
+	index := func(plugins ...string) string {
+		contents := "plugins:\n"
+		for _, p := range plugins {
+			contents += fmt.Sprintf("  - name: %s\n    versions:\n      - version: 1.0.0\n        download:\n          multi-arch: {locator: %s-1.0.0, type: native}\n", p, p)
+		}
+		return contents
+	}
+
+	// every registry has its own plugin, and they all have "shared". "broken"
+	// can't be loaded
+	registries := map[string]string{
+		defaultRegistryName: index("public", "shared"),
+		"internal":          index("internal", "shared"),
+		"team":              index("team", "shared"),
+		"broken":            "",
+	}
+
+	tests := []struct {
+		name     string
+		plugin   string
+		priority []string
+		// without lists registries that aren't installed
+		without  []string
+		registry string
+		others   []string
+		err      error
+	}{
+		{
+			name:     "in one registry",
+			plugin:   "team",
+			without:  []string{"broken"},
+			registry: "team",
+		},
+		{
+			name:     "only in the default registry",
+			plugin:   "public",
+			without:  []string{"broken"},
+			registry: defaultRegistryName,
+		},
+		{
+			name:    "in several unranked registries",
+			plugin:  "shared",
+			without: []string{"broken"},
+			others:  []string{"internal", "team", defaultRegistryName},
+			err:     ErrPluginAmbiguous,
+		},
+		{
+			name:    "in the default registry and an unranked one",
+			plugin:  "shared",
+			without: []string{"broken", "team"},
+			others:  []string{"internal", defaultRegistryName},
+			err:     ErrPluginAmbiguous,
+		},
+		{
+			name:     "in several registries, the first ranked",
+			plugin:   "shared",
+			priority: []string{"team"},
+			without:  []string{"broken"},
+			registry: "team",
+			others:   []string{"internal", defaultRegistryName},
+		},
+		{
+			name:     "ranked registry searched first could not be loaded",
+			plugin:   "shared",
+			priority: []string{"broken", "internal"},
+			without:  []string{"team"},
+			others:   []string{"internal", defaultRegistryName},
+			err:      ErrRegistryUnsearched,
+		},
+		{
+			name:   "registry searched first could not be loaded",
+			plugin: "public",
+			others: []string{defaultRegistryName},
+			err:    ErrRegistryUnsearched,
+		},
+		{
+			name:     "registry searched later could not be loaded",
+			plugin:   "internal",
+			priority: []string{"internal"},
+			registry: "internal",
+		},
+		{
+			name:    "not in any registry",
+			plugin:  "missing",
+			without: []string{"broken"},
+			err:     ErrPluginNotInRegistry,
+		},
+		{
+			name:   "not in any registry that could be loaded",
+			plugin: "missing",
+			err:    ErrPluginNotInRegistry,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(runner.PLUGIN_HOME, t.TempDir())
+
+			p := &PluginRegistries{r: map[string]*PluginRegistry{}, m: new(sync.RWMutex)}
+			for name, contents := range registries {
+				dir := filepath.Join(work, name)
+				if contents != "" {
+					if err := os.MkdirAll(dir, 0o755); err != nil {
+						t.Fatal(err)
+					}
+
+					if err := os.WriteFile(filepath.Join(dir, indexFileName), []byte(contents), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				u, err := runner.FileURL(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+				p.r[name] = &PluginRegistry{URL: u}
+			}
+
+			for _, name := range tt.without {
+				p.Delete(name)
+			}
+			p.priority = tt.priority
+
+			registry, others, err := p.ResolveRegistry(tt.plugin)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if registry != tt.registry {
+				t.Errorf("registry = %q, want %q", registry, tt.registry)
+			}
+
+			if len(others) != 0 || len(tt.others) != 0 {
+				if !reflect.DeepEqual(others, tt.others) {
+					t.Errorf("others = %q, want %q", others, tt.others)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/alecthomas/kong"
+)
+
+type RefreshCommand struct {
+	Names []string `arg:"" optional:"" help:"The registries to refresh. Defaults to every registry in the catalog"`
+	r     *PluginRegistries
+}
+
+func (c *RefreshCommand) BeforeApply() error {
+	var err error
+	c.r, err = LoadFromDisk()
+	return err
+}
+
+func (c *RefreshCommand) Run(ctx *kong.Context) error {
+	if cacheOptions.Offline {
+		return fmt.Errorf("registries can't be refreshed with --offline")
+	}
+
+	names := c.Names
+	if len(names) == 0 {
+		for n := range c.r.GetAll() {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+	}
+
+	failed := 0
+	for _, n := range names {
+		reg := c.r.Get(n)
+		if reg == nil {
+			fmt.Fprintf(ctx.Stderr, "%s: registry not installed\n", n)
+			failed++
+			continue
+		}
+
+		result, err := reg.Refresh()
+		if err != nil {
+			fmt.Fprintf(ctx.Stderr, "%s: %v\n", n, err)
+			failed++
+			continue
+		}
+
+		fmt.Fprintf(ctx.Stdout, "%s: %s, %d plugins\n", n, result, len(reg.Plugins))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d registries could not be refreshed", failed, len(names))
+	}
+
+	return nil
+}
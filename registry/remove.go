@@ -18,12 +18,21 @@ func (r *RemoveRegistryCommand) BeforeApply() error {
 }
 
 func (r *RemoveRegistryCommand) Run(ctx *kong.Context) error {
-	if reg := r.r.Get(r.Name); reg == nil {
+	reg := r.r.Get(r.Name)
+	if reg == nil {
 		return fmt.Errorf("registry %q not installed", r.Name)
 	}
 
+	if err := r.r.checkMutable(r.Name); err != nil {
+		return err
+	}
+
 	if r.r.Delete(r.Name) {
-		return r.r.SaveToDisk()
+		if err := r.r.SaveToDisk(); err != nil {
+			return err
+		}
+
+		return DropCache(reg.URL)
 	}
 
 	return nil
@@ -0,0 +1,121 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alecthomas/kong"
+)
+
+type ServeCommand struct {
+	Dir     string `arg:"" type:"existingdir" help:"The registry directory, holding index.yaml and the plugin executables"`
+	Listen  string `short:"l" default:"127.0.0.1:8080" help:"The address to listen on"`
+	TLSCert string `type:"existingfile" help:"The PEM encoded certificate to serve HTTPS with. Registries can only be added with an HTTPS URL, unless they're served on localhost"`
+	TLSKey  string `type:"existingfile" help:"The PEM encoded private key of --tls-cert"`
+}
+
+func (s *ServeCommand) Run(ctx *kong.Context) error {
+	if (s.TLSCert == "") != (s.TLSKey == "") {
+		return errors.New("--tls-cert and --tls-key must be set together")
+	}
+
+	dir, err := filepath.Abs(s.Dir)
+	if err != nil {
+		return err
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, indexFileName)); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		fmt.Fprintf(ctx.Stderr, "warning: %s has no %s yet, create it with \"registry build-index\"\n", dir, indexFileName)
+	}
+
+	server := &http.Server{
+		Addr:              s.Listen,
+		Handler:           logRequests(ctx.Stderr, registryFiles(dir)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	scheme := "http"
+	if s.TLSCert != "" {
+		scheme = "https"
+	}
+	fmt.Fprintf(ctx.Stdout, "Serving %s at %s://%s, press Ctrl+C to stop\n", dir, scheme, s.Listen)
+
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		if s.TLSCert != "" {
+			errs <- server.ListenAndServeTLS(s.TLSCert, s.TLSKey)
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err = <-errs:
+		return err
+	case <-stop.Done():
+	}
+
+	shutdown, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+
+	return server.Shutdown(shutdown)
+}
+
+// registryFiles serves the files in dir. Hidden files and directories, like
+// .git or signing keys kept next to the registry, aren't served, and neither
+// are directory listings, which would reveal them and anything else that isn't
+// in the index
+func registryFiles(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		name := path.Clean("/" + req.URL.Path)
+		for _, segment := range strings.Split(name, "/") {
+			if strings.HasPrefix(segment, ".") {
+				http.NotFound(rw, req)
+				return
+			}
+		}
+
+		if info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err == nil && info.IsDir() {
+			http.NotFound(rw, req)
+			return
+		}
+
+		files.ServeHTTP(rw, req)
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func logRequests(w io.Writer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: rw, status: http.StatusOK}
+		next.ServeHTTP(recorder, req)
+		fmt.Fprintf(w, "%s %s %s %d\n", time.Now().Format(time.RFC3339), req.Method, req.URL.Path, recorder.status)
+	})
+}
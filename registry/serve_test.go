@@ -0,0 +1,69 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistryFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		indexFileName:                   "name: example\n",
+		indexSignatureFileName:          "signature\n",
+		"aws/aws-1.0.0":                 "#!/bin/sh\n",
+		".git/config":                   "[core]\n",
+		".minisign.key":                 "secret\n",
+		"aws/.aws-1.0.0.tmp":            "partial\n",
+		"aws/.keep/aws-1.0.0":           "#!/bin/sh\n",
+		"aws/releases/aws-2.0.0-linux":  "#!/bin/sh\n",
+		"aws/releases/aws-2.0.0-darwin": "#!/bin/sh\n",
+	}
+
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{path: "/" + indexFileName, status: http.StatusOK},
+		{path: "/" + indexSignatureFileName, status: http.StatusOK},
+		{path: "/aws/aws-1.0.0", status: http.StatusOK},
+		{path: "/aws/releases/aws-2.0.0-linux", status: http.StatusOK},
+		{path: "/missing", status: http.StatusNotFound},
+		{path: "/.git/config", status: http.StatusNotFound},
+		{path: "/.git/", status: http.StatusNotFound},
+		{path: "/.minisign.key", status: http.StatusNotFound},
+		{path: "/aws/.aws-1.0.0.tmp", status: http.StatusNotFound},
+		{path: "/aws/.keep/aws-1.0.0", status: http.StatusNotFound},
+		{path: "/aws/../.git/config", status: http.StatusNotFound},
+		{path: "/%2egit/config", status: http.StatusNotFound},
+		{path: "/", status: http.StatusNotFound},
+		{path: "/aws/", status: http.StatusNotFound},
+		{path: "/aws", status: http.StatusNotFound},
+		{path: "/aws/releases/", status: http.StatusNotFound},
+	}
+
+	handler := registryFiles(dir)
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("GET %s = %d, want %d", tt.path, rec.Code, tt.status)
+			}
+		})
+	}
+}
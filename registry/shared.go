@@ -10,14 +10,31 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/gideaworx/terraform-exporter-plugin-registry/registry"
 	"github.com/gideaworx/terraform-exporter/runner"
 	"gopkg.in/yaml.v3"
 )
 
 type PluginRegistry struct {
-	URL     *url.URL          `yaml:",inline"`
-	Plugins []registry.Plugin `yaml:"-"`
+	URL *url.URL `yaml:",inline"`
+	// TrustedKeys are the public keys trusted to sign this registry's plugins
+	TrustedKeys []runner.TrustedKey `yaml:"-"`
+	// IndexKey is the public key pinned to verify the registry's index.yaml
+	IndexKey string `yaml:"-"`
+	// Auth is how to authenticate to the registry, nil if it's public
+	Auth *RegistryAuth `yaml:"-"`
+	// HTTP overrides the global HTTP options for this registry
+	HTTP runner.HTTPOptions `yaml:"-"`
+	// Mirrors serve the same index and executables as URL, and are tried in
+	// order when it can't be reached
+	Mirrors []*url.URL `yaml:"-"`
+	// Managed registries are defined by the catalog they were synced from,
+	// and only change when it's synced again
+	Managed    bool       `yaml:"-"`
+	Plugins    []Plugin   `yaml:"-"`
+	Advisories []Advisory `yaml:"-"`
+
+	// servedFrom is the URL or mirror the index was last loaded from
+	servedFrom *url.URL
 }
 
 func (r *PluginRegistry) Clone() *PluginRegistry {
@@ -27,8 +44,21 @@ func (r *PluginRegistry) Clone() *PluginRegistry {
 
 	cloned := new(PluginRegistry)
 	cloned.URL = r.URL.JoinPath("")
-	cloned.Plugins = make([]registry.Plugin, len(r.Plugins))
+	cloned.IndexKey = r.IndexKey
+	cloned.Auth = r.Auth
+	cloned.HTTP = r.HTTP
+	cloned.servedFrom = r.servedFrom
+	cloned.Managed = r.Managed
+	cloned.Mirrors = make([]*url.URL, len(r.Mirrors))
+	for i, m := range r.Mirrors {
+		cloned.Mirrors[i] = m.JoinPath("")
+	}
+	cloned.TrustedKeys = make([]runner.TrustedKey, len(r.TrustedKeys))
+	copy(cloned.TrustedKeys, r.TrustedKeys)
+	cloned.Plugins = make([]Plugin, len(r.Plugins))
 	copy(cloned.Plugins, r.Plugins)
+	cloned.Advisories = make([]Advisory, len(r.Advisories))
+	copy(cloned.Advisories, r.Advisories)
 
 	return cloned
 }
@@ -37,38 +67,65 @@ func (r *PluginRegistry) LazyLoad() error {
 	if len(r.Plugins) > 0 {
 		return nil
 	}
-	hc := runner.GetHTTPClient()
 
-	resp, err := hc.Get(r.URL.JoinPath("index.yaml").String())
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+	_, err := r.load(false)
+	return err
+}
+
+// Refresh revalidates the registry's cached index, whatever its age
+func (r *PluginRegistry) Refresh() (RefreshResult, error) {
+	return r.load(true)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("expected status '200 OK', got '%s'", resp.Status)
+func (r *PluginRegistry) load(force bool) (RefreshResult, error) {
+	contents, result, err := r.loadIndex(force)
+	if err != nil {
+		return "", err
 	}
 
-	var fullRegistry registry.PluginRegistry
-	if err = yaml.NewDecoder(resp.Body).Decode(&fullRegistry); err != nil {
-		return err
+	var fullRegistry Index
+	if err = yaml.Unmarshal(contents, &fullRegistry); err != nil {
+		return "", fmt.Errorf("could not parse the index of %s, run \"registry lint\" for details: %w", r.URL, err)
 	}
 
 	r.Plugins = fullRegistry.Plugins
-	return nil
+	r.Advisories = fullRegistry.Advisories
+	return result, nil
 }
 
 type PluginRegistries struct {
-	r map[string]*PluginRegistry
-	m *sync.RWMutex
+	r    map[string]*PluginRegistry
+	m    *sync.RWMutex
+	http runner.HTTPOptions
+	// priority ranks registries when a plugin is installed without naming one
+	priority []string
+	// defaultName is the registry set as the default, empty for the built-in
+	// default registry
+	defaultName string
+	// catalog is where the managed registries were synced from
+	catalog *CatalogSource
 }
 
 type fileRegistryEntry struct {
-	Name string `yaml:"name"`
-	URL  string `yaml:"url"`
+	Name        string              `yaml:"name"`
+	URL         string              `yaml:"url"`
+	TrustedKeys []runner.TrustedKey `yaml:"trustedKeys,omitempty"`
+	IndexKey    string              `yaml:"indexKey,omitempty"`
+	Auth        *RegistryAuth       `yaml:"auth,omitempty"`
+	HTTP        *runner.HTTPOptions `yaml:"http,omitempty"`
+	Mirrors     []string            `yaml:"mirrors,omitempty"`
+	Managed     bool                `yaml:"managed,omitempty"`
 }
 
 type registryFile struct {
+	// HTTP holds the HTTP options for every registry
+	HTTP *runner.HTTPOptions `yaml:"http,omitempty"`
+	// Priority lists registries in the order they're searched for plugins
+	Priority []string `yaml:"priority,omitempty"`
+	// Default is the registry used when one is needed but none is named
+	Default string `yaml:"default,omitempty"`
+	// Catalog is where the managed registries were synced from
+	Catalog    *CatalogSource      `yaml:"catalog,omitempty"`
 	Registries []fileRegistryEntry `yaml:"registries"`
 }
 
@@ -118,13 +175,53 @@ func LoadFromDisk() (*PluginRegistries, error) {
 		return nil, err
 	}
 
+	registries.http = httpOptions(installedRegistries.HTTP)
+	registries.priority = installedRegistries.Priority
+	registries.defaultName = installedRegistries.Default
+	registries.catalog = installedRegistries.Catalog
+	runner.SetDefaultHTTPOptions(registries.http)
+
 	for _, reg := range installedRegistries.Registries {
+		if err = reg.Auth.validate(); err != nil {
+			return nil, fmt.Errorf("registry %s: %w", reg.Name, err)
+		}
+
+		// the default registry is only saved to keep the keys trusted for it,
+		// and the URL it was moved to
+		if reg.Name == defaultRegistryName {
+			if reg.URL != "" {
+				if m[defaultRegistryName].URL, err = url.Parse(reg.URL); err != nil {
+					return nil, err
+				}
+			}
+			m[defaultRegistryName].TrustedKeys = reg.TrustedKeys
+			m[defaultRegistryName].IndexKey = reg.IndexKey
+			m[defaultRegistryName].Auth = reg.Auth
+			m[defaultRegistryName].HTTP = httpOptions(reg.HTTP)
+			if m[defaultRegistryName].Mirrors, err = parseMirrors(reg.Mirrors); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
 		u, err := url.Parse(reg.URL)
 		if err != nil {
 			return nil, err
 		}
 
-		if err = registries.Add(reg.Name, u); err != nil {
+		// registries added before names were validated still load, they can be
+		// renamed to something the registry/plugin syntax can address
+		if _, ok := m[reg.Name]; ok {
+			return nil, fmt.Errorf("a registry with name %s already exists", reg.Name)
+		}
+
+		m[reg.Name] = &PluginRegistry{URL: u}
+		m[reg.Name].TrustedKeys = reg.TrustedKeys
+		m[reg.Name].IndexKey = reg.IndexKey
+		m[reg.Name].Auth = reg.Auth
+		m[reg.Name].HTTP = httpOptions(reg.HTTP)
+		m[reg.Name].Managed = reg.Managed
+		if m[reg.Name].Mirrors, err = parseMirrors(reg.Mirrors); err != nil {
 			return nil, err
 		}
 	}
@@ -142,14 +239,18 @@ func (p *PluginRegistries) SaveToDisk() error {
 	defer p.m.RUnlock()
 
 	regFile := registryFile{
+		HTTP:       optionalHTTPOptions(p.http),
+		Priority:   p.priority,
+		Default:    p.defaultName,
+		Catalog:    p.catalog,
 		Registries: make([]fileRegistryEntry, 0, len(p.r)),
 	}
 	for n, p := range p.r {
-		if n == defaultRegistryName {
+		if n == defaultRegistryName && p.URL.String() == defaultRegistryURLStr && len(p.TrustedKeys) == 0 && p.IndexKey == "" && p.Auth == nil && p.HTTP == (runner.HTTPOptions{}) && len(p.Mirrors) == 0 {
 			continue
 		}
 
-		regFile.Registries = append(regFile.Registries, fileRegistryEntry{Name: n, URL: p.URL.String()})
+		regFile.Registries = append(regFile.Registries, fileRegistryEntry{Name: n, URL: p.URL.String(), TrustedKeys: p.TrustedKeys, IndexKey: p.IndexKey, Auth: p.Auth, HTTP: optionalHTTPOptions(p.HTTP), Mirrors: mirrorStrings(p.Mirrors), Managed: p.Managed})
 	}
 
 	contents, err := yaml.Marshal(regFile)
@@ -157,7 +258,13 @@ func (p *PluginRegistries) SaveToDisk() error {
 		return err
 	}
 
-	return os.WriteFile(filepath.Join(pluginHome, registryFileName), contents, 0666)
+	// the file can hold credentials
+	path := filepath.Join(pluginHome, registryFileName)
+	if err = os.WriteFile(path, contents, 0o600); err != nil {
+		return err
+	}
+
+	return os.Chmod(path, 0o600)
 }
 
 func (p *PluginRegistries) GetAll() map[string]*PluginRegistry {
@@ -190,12 +297,8 @@ func (p *PluginRegistries) Add(name string, location *url.URL) error {
 	}
 
 	name = strings.TrimSpace(name)
-	if name == "" {
-		return errors.New("a registry cannot be named with the empty string")
-	}
-
-	if name == "default" {
-		return errors.New("you cannot override the default registry")
+	if err := validateName(name); err != nil {
+		return err
 	}
 
 	p.m.Lock()
@@ -214,9 +317,301 @@ func (p *PluginRegistries) Delete(name string) bool {
 	p.m.Lock()
 	defer p.m.Unlock()
 
+	return p.deleteLocked(name)
+}
+
+func (p *PluginRegistries) deleteLocked(name string) bool {
 	oldLen := len(p.r)
 	delete(p.r, name)
 	newLen := len(p.r)
 
+	for i, n := range p.priority {
+		if n == name {
+			p.priority = append(p.priority[:i:i], p.priority[i+1:]...)
+			break
+		}
+	}
+
+	if p.defaultName == name {
+		p.defaultName = ""
+	}
+
 	return newLen < oldLen
 }
+
+// checkMutable returns an error if the registry isn't installed, or is managed
+// by a catalog and can't be changed
+func (p *PluginRegistries) checkMutable(name string) error {
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	_, err := p.mutableLocked(name)
+	return err
+}
+
+func (p *PluginRegistries) mutableLocked(name string) (*PluginRegistry, error) {
+	reg, ok := p.r[name]
+	if !ok {
+		return nil, fmt.Errorf("registry %q not installed", name)
+	}
+
+	if reg.Managed {
+		location := "a catalog"
+		if p.catalog != nil {
+			location = p.catalog.Location
+		}
+
+		return nil, fmt.Errorf("%w: %s is defined by %s, change it there and run \"registry sync\"", ErrManaged, name, location)
+	}
+
+	return reg, nil
+}
+
+// validateName checks the name of a new registry
+func validateName(name string) error {
+	if name == "" {
+		return errors.New("a registry cannot be named with the empty string")
+	}
+
+	if name == defaultRegistryName {
+		return errors.New("you cannot override the default registry")
+	}
+
+	// plugins are named as registry/plugin
+	if strings.Contains(name, "/") {
+		return fmt.Errorf("registry name %q cannot contain a slash", name)
+	}
+
+	return nil
+}
+
+// SetURL moves a registry to a new location
+func (p *PluginRegistries) SetURL(name string, location *url.URL) error {
+	if location == nil {
+		return errors.New("location cannot be nil")
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	reg, err := p.mutableLocked(name)
+	if err != nil {
+		return err
+	}
+
+	reg.URL = location
+	reg.servedFrom = nil
+	reg.Plugins = nil
+	reg.Advisories = nil
+	return nil
+}
+
+// Rename renames a registry, keeping its place in the priority and whether
+// it's the default
+func (p *PluginRegistries) Rename(name, newName string) error {
+	if name == defaultRegistryName {
+		return errors.New("the default registry cannot be renamed")
+	}
+
+	newName = strings.TrimSpace(newName)
+	if err := validateName(newName); err != nil {
+		return err
+	}
+
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	reg, err := p.mutableLocked(name)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := p.r[newName]; ok {
+		return fmt.Errorf("a registry with name %s already exists", newName)
+	}
+
+	delete(p.r, name)
+	p.r[newName] = reg
+
+	for i, n := range p.priority {
+		if n == name {
+			p.priority[i] = newName
+		}
+	}
+
+	if p.defaultName == name {
+		p.defaultName = newName
+	}
+
+	return nil
+}
+
+// DefaultName returns the name of the registry used when one is needed but
+// none is named
+func (p *PluginRegistries) DefaultName() string {
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	return p.defaultLocked()
+}
+
+func (p *PluginRegistries) defaultLocked() string {
+	if _, ok := p.r[p.defaultName]; ok {
+		return p.defaultName
+	}
+
+	return defaultRegistryName
+}
+
+// SetDefault sets the registry used when one is needed but none is named
+func (p *PluginRegistries) SetDefault(name string) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if _, ok := p.r[name]; !ok {
+		return fmt.Errorf("registry %q not installed", name)
+	}
+
+	p.defaultName = name
+	if name == defaultRegistryName {
+		p.defaultName = ""
+	}
+
+	return nil
+}
+
+// Trust adds a key trusted to sign the plugins of a registry, replacing a key
+// with the same name
+func (p *PluginRegistries) Trust(name string, key runner.TrustedKey) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	reg, err := p.mutableLocked(name)
+	if err != nil {
+		return err
+	}
+
+	for i, tk := range reg.TrustedKeys {
+		if tk.Name == key.Name {
+			reg.TrustedKeys[i] = key
+			return nil
+		}
+	}
+
+	reg.TrustedKeys = append(reg.TrustedKeys, key)
+	return nil
+}
+
+// Untrust removes a trusted key from a registry, and reports whether the key
+// was trusted
+func (p *PluginRegistries) Untrust(name, keyName string) (bool, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	reg, err := p.mutableLocked(name)
+	if err != nil {
+		return false, err
+	}
+
+	for i, tk := range reg.TrustedKeys {
+		if tk.Name == keyName {
+			reg.TrustedKeys = append(reg.TrustedKeys[:i], reg.TrustedKeys[i+1:]...)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// PinIndexKey sets the key that must sign a registry's index. An empty key
+// unpins it
+func (p *PluginRegistries) PinIndexKey(name, key string) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	reg, err := p.mutableLocked(name)
+	if err != nil {
+		return err
+	}
+
+	reg.IndexKey = key
+	return nil
+}
+
+// SetAuth sets how to authenticate to a registry. A nil auth makes it public
+func (p *PluginRegistries) SetAuth(name string, auth *RegistryAuth) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	reg, err := p.mutableLocked(name)
+	if err != nil {
+		return err
+	}
+
+	reg.Auth = auth
+	return nil
+}
+
+// SetHTTPOptions sets the HTTP options of a registry, or the options shared by
+// every registry when name is empty
+func (p *PluginRegistries) SetHTTPOptions(name string, o runner.HTTPOptions) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if name == "" {
+		p.http = o
+		runner.SetDefaultHTTPOptions(o)
+		return nil
+	}
+
+	reg, err := p.mutableLocked(name)
+	if err != nil {
+		return err
+	}
+
+	reg.HTTP = o
+	return nil
+}
+
+// SetMirrors replaces the mirrors of a registry. No mirrors removes them all
+func (p *PluginRegistries) SetMirrors(name string, mirrors []*url.URL) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	reg, err := p.mutableLocked(name)
+	if err != nil {
+		return err
+	}
+
+	reg.Mirrors = mirrors
+	return nil
+}
+
+// HTTPOptions returns the HTTP options shared by every registry
+func (p *PluginRegistries) HTTPOptions() runner.HTTPOptions {
+	p.m.RLock()
+	defer p.m.RUnlock()
+
+	return p.http
+}
+
+func httpOptions(o *runner.HTTPOptions) runner.HTTPOptions {
+	if o == nil {
+		return runner.HTTPOptions{}
+	}
+
+	return *o
+}
+
+func optionalHTTPOptions(o runner.HTTPOptions) *runner.HTTPOptions {
+	if o == (runner.HTTPOptions{}) {
+		return nil
+	}
+
+	return &o
+}
+
+// client returns the HTTP client for the registry's requests
+func (r *PluginRegistry) client() (*http.Client, error) {
+	return runner.NewHTTPClient(r.HTTP)
+}
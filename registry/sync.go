@@ -0,0 +1,264 @@
+package registry
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter/runner"
+	"github.com/olekukonko/tablewriter"
+)
+
+var ErrUnverifiedHelper = errors.New("an unverified catalog can't set credential helpers")
+
+type SyncCommand struct {
+	Catalog        string `arg:"" optional:"" help:"The HTTPS URL, file:// URL or path of the catalog. Defaults to the catalog synced last"`
+	Key            string `short:"k" type:"existingfile" help:"The public key that signs the catalog, as the catalog's URL with .sig appended. Defaults to the key the catalog was synced with last"`
+	DryRun         bool   `short:"n" help:"Only show the changes that would be made"`
+	NonInteractive bool   `short:"y" help:"Apply the changes without asking first"`
+	ctx            *kong.Context
+	in             io.Reader
+	r              *PluginRegistries
+}
+
+func (s *SyncCommand) BeforeApply(ctx *kong.Context, stdin io.Reader) error {
+	s.ctx = ctx
+	s.in = stdin
+
+	var err error
+	s.r, err = LoadFromDisk()
+	return err
+}
+
+func (s *SyncCommand) Run() error {
+	source, err := s.source()
+	if err != nil {
+		return err
+	}
+
+	location, err := url.Parse(source.Location)
+	if err != nil {
+		return err
+	}
+
+	if cacheOptions.Offline && location.Scheme != "file" {
+		return errors.New("catalogs can't be synced with --offline")
+	}
+
+	catalog, err := FetchCatalog(location, source.Key)
+	if err != nil {
+		return fmt.Errorf("could not load the catalog at %s: %w", location.Redacted(), err)
+	}
+
+	if source.Key == "" {
+		fmt.Fprintf(s.ctx.Stderr, "warning: the catalog at %s is not verified, pass the key that signs it with --key\n", location.Redacted())
+	}
+
+	changes, err := s.r.PlanCatalog(catalog)
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, c := range changes {
+		if c.Action != CatalogNone {
+			pending++
+		}
+	}
+
+	if len(changes) > 0 {
+		s.printPlan(changes)
+	}
+
+	if err = checkHelpers(source, changes); err != nil {
+		return err
+	}
+
+	if pending == 0 {
+		fmt.Fprintf(s.ctx.Stdout, "\nRegistries already match the catalog at %s\n", location.Redacted())
+		if s.DryRun {
+			return nil
+		}
+
+		// the catalog is remembered even when there's nothing to change
+		s.r.ApplyCatalog(source, nil)
+		return s.r.SaveToDisk()
+	}
+
+	if s.DryRun {
+		return nil
+	}
+
+	if !s.NonInteractive {
+		scanner := bufio.NewScanner(s.in)
+
+		fmt.Fprintf(s.ctx.Stdout, "\nApply %d changes? type 'y' or 'yes' (case insensitive): ", pending)
+		scanner.Scan()
+		input := strings.TrimSpace(scanner.Text())
+
+		if !strings.EqualFold("y", input) && !strings.EqualFold("yes", input) {
+			fmt.Fprintf(s.ctx.Stdout, "\nYou answered %q, bailing out...\n\n", input)
+			return nil
+		}
+	}
+
+	return s.apply(source, changes)
+}
+
+// source is the catalog to sync and its key, from the arguments or from the
+// last sync. The key of the last sync is only reused for the same catalog
+func (s *SyncCommand) source() (CatalogSource, error) {
+	previous := s.r.Catalog()
+
+	source := CatalogSource{}
+	if s.Catalog == "" {
+		if previous == nil {
+			return source, errors.New("no catalog has been synced yet, pass the URL or path of one")
+		}
+		source = *previous
+	} else {
+		u, err := url.Parse(s.Catalog)
+		if err != nil {
+			return source, err
+		}
+
+		if u, err = ParseLocation(u); err != nil {
+			return source, err
+		}
+
+		source.Location = u.String()
+		if previous != nil && previous.Location == source.Location {
+			source.Key = previous.Key
+		}
+	}
+
+	if s.Key != "" {
+		contents, err := os.ReadFile(s.Key)
+		if err != nil {
+			return source, err
+		}
+
+		source.Key = strings.TrimSpace(string(contents))
+		if _, _, err = runner.ParsePublicKey(source.Key); err != nil {
+			return source, fmt.Errorf("%s: %w", s.Key, err)
+		}
+	}
+
+	return source, nil
+}
+
+// checkHelpers refuses credential helpers, which run commands on this machine,
+// from a catalog that isn't verified with a key. Whoever can change the
+// catalog or what's served in its place could run anything otherwise. Helpers
+// that are already configured are kept
+func checkHelpers(source CatalogSource, changes []CatalogChange) error {
+	if source.Key != "" {
+		return nil
+	}
+
+	registries := []string{}
+	for _, c := range changes {
+		if c.Helper != "" {
+			registries = append(registries, c.Name)
+		}
+	}
+
+	if len(registries) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: the catalog sets credential helpers for %s. Pass the key that signs it with --key", ErrUnverifiedHelper, strings.Join(registries, ", "))
+}
+
+// apply makes the changes, moving the plugins installed from a registry whose
+// URL changed along with it. Either everything changes or nothing does
+func (s *SyncCommand) apply(source CatalogSource, changes []CatalogChange) error {
+	migrations := []*runner.SourceMigration{}
+	undo := func(err error) error {
+		for i := len(migrations) - 1; i >= 0; i-- {
+			err = rollback(migrations[i], err)
+		}
+		return err
+	}
+
+	drop := []*url.URL{}
+	for _, c := range changes {
+		current := s.r.Get(c.Name)
+		if current == nil {
+			continue
+		}
+
+		switch {
+		case c.Action == CatalogRemove:
+			drop = append(drop, current.URL)
+		case c.registry != nil && current.URL.String() != c.registry.URL.String():
+			previous, moved, name := current.URL.String(), c.registry.URL.String(), c.Name
+			migration, err := runner.MigrateBOMSources(func(source *runner.PluginSource) bool {
+				if source.Type != "registry" || source.Name != name || source.URL != previous {
+					return false
+				}
+
+				source.URL = moved
+				return true
+			})
+			if err != nil {
+				return undo(err)
+			}
+
+			migrations = append(migrations, migration)
+			writeMigrated(s.ctx, migration)
+			drop = append(drop, current.URL)
+		}
+	}
+
+	s.r.ApplyCatalog(source, changes)
+	if err := s.r.SaveToDisk(); err != nil {
+		return undo(err)
+	}
+
+	for _, u := range drop {
+		if err := DropCache(u); err != nil {
+			fmt.Fprintf(s.ctx.Stderr, "warning: could not remove the cached index of %s: %v\n", u, err)
+		}
+	}
+
+	fmt.Fprintf(s.ctx.Stdout, "Synced the registries with %s\n", runner.RedactURL(source.Location))
+	return nil
+}
+
+func (s *SyncCommand) printPlan(changes []CatalogChange) {
+	table := tablewriter.NewWriter(s.ctx.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Registry", "Action", "URL", "Notes"})
+	table.SetHeaderLine(true)
+	table.SetBorder(true)
+
+	for _, c := range changes {
+		location := ""
+		if c.registry != nil {
+			location = c.registry.URL.Redacted()
+		}
+
+		notes := []string{}
+		if len(c.Changes) > 0 {
+			notes = append(notes, "changes "+strings.Join(c.Changes, ", "))
+		}
+
+		if c.Action == CatalogRemove {
+			notes = append(notes, "no longer in the catalog")
+		}
+
+		if c.Helper != "" {
+			notes = append(notes, fmt.Sprintf("runs credential helper %q", c.Helper))
+		}
+
+		table.Append([]string{c.Name, string(c.Action), location, strings.Join(notes, "\n")})
+	}
+
+	table.Render()
+}
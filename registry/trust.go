@@ -0,0 +1,76 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+type TrustKeyCommand struct {
+	Registry string `arg:"" help:"The name of the registry whose plugins the key signs"`
+	KeyFile  string `arg:"" type:"existingfile" help:"A minisign public key file, or a PEM encoded public key for cosign signatures"`
+	Name     string `short:"n" help:"The identity of the signer, recorded with every plugin the key signs. Defaults to the key's id"`
+	r        *PluginRegistries
+}
+
+func (t *TrustKeyCommand) BeforeApply() error {
+	var err error
+	t.r, err = LoadFromDisk()
+	return err
+}
+
+func (t *TrustKeyCommand) Run(ctx *kong.Context) error {
+	contents, err := os.ReadFile(t.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	format, id, err := runner.ParsePublicKey(string(contents))
+	if err != nil {
+		return fmt.Errorf("could not read %s: %w", t.KeyFile, err)
+	}
+
+	name := strings.TrimSpace(t.Name)
+	if name == "" {
+		name = id
+	}
+
+	if err = t.r.Trust(t.Registry, runner.TrustedKey{Name: name, Key: strings.TrimSpace(string(contents))}); err != nil {
+		return err
+	}
+
+	if err = t.r.SaveToDisk(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(ctx.Stdout, "Trusting %s key %s as %q for registry %s\n", format, id, name, t.Registry)
+	return nil
+}
+
+type UntrustKeyCommand struct {
+	Registry string `arg:"" help:"The name of the registry"`
+	Name     string `arg:"" help:"The name of the trusted key to remove"`
+	r        *PluginRegistries
+}
+
+func (u *UntrustKeyCommand) BeforeApply() error {
+	var err error
+	u.r, err = LoadFromDisk()
+	return err
+}
+
+func (u *UntrustKeyCommand) Run(ctx *kong.Context) error {
+	trusted, err := u.r.Untrust(u.Registry, u.Name)
+	if err != nil {
+		return err
+	}
+
+	if !trusted {
+		return fmt.Errorf("registry %s does not trust a key named %q", u.Registry, u.Name)
+	}
+
+	return u.r.SaveToDisk()
+}
@@ -0,0 +1,114 @@
+package registry
+
+import (
+	"fmt"
+	"net/url"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// FindPlugin returns the named plugin from a loaded registry
+func (r *PluginRegistry) FindPlugin(name string) (Plugin, bool) {
+	for _, p := range r.Plugins {
+		if p.Name == name {
+			return p, true
+		}
+	}
+
+	return Plugin{}, false
+}
+
+// SortVersions sorts plugin versions from newest to oldest
+func SortVersions(versions []PluginVersion) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, _ := semver.ParseTolerant(versions[i].Version)
+		vj, _ := semver.ParseTolerant(versions[j].Version)
+		return vi.GT(vj)
+	})
+}
+
+// CompatibleExecutable returns the executable of a plugin version that can run
+// on this machine, if there is one
+func CompatibleExecutable(v PluginVersion) (PluginExecutable, bool) {
+	targetArch := TargetArchitecture(fmt.Sprintf("%s/%s", runtime.GOOS, runtime.GOARCH))
+	if exe, ok := v.DownloadInfo[targetArch]; ok {
+		return exe, true
+	}
+
+	if maExe, ok := v.DownloadInfo[MultiArch]; ok {
+		return maExe, true
+	}
+
+	// if we're on darwin/arm64, we can try darwin/amd64 and let Rosetta take the wheel
+	if runtime.GOOS == "darwin" && runtime.GOARCH == "arm64" {
+		exe, ok := v.DownloadInfo[DarwinAmd64]
+		return exe, ok
+	}
+
+	return PluginExecutable{}, false
+}
+
+// LatestCompatible returns the newest version of a plugin that can run on this
+// machine and is accepted by the filter. A nil filter accepts every version.
+// Yanked versions are never the latest
+func LatestCompatible(p Plugin, filter func(semver.Version) bool) (PluginVersion, bool) {
+	return latestCompatible(p, filter, false)
+}
+
+func latestCompatible(p Plugin, filter func(semver.Version) bool, allowYanked bool) (PluginVersion, bool) {
+	versions := make([]PluginVersion, len(p.Versions))
+	copy(versions, p.Versions)
+	SortVersions(versions)
+
+	for _, v := range versions {
+		if v.Yanked && !allowYanked {
+			continue
+		}
+
+		sv, err := semver.ParseTolerant(v.Version)
+		if err != nil {
+			continue
+		}
+
+		if filter != nil && !filter(sv) {
+			continue
+		}
+
+		if _, ok := CompatibleExecutable(v); ok {
+			return v, true
+		}
+	}
+
+	return PluginVersion{}, false
+}
+
+// FindVersion returns a version of a plugin, whether or not it was yanked
+func FindVersion(p Plugin, version string) (PluginVersion, bool) {
+	for _, v := range p.Versions {
+		if strings.TrimPrefix(v.Version, "v") == strings.TrimPrefix(version, "v") {
+			return v, true
+		}
+	}
+
+	return PluginVersion{}, false
+}
+
+// ResolveLocator returns the URL of a native executable or signature. Locators
+// relative to the registry, like the plain file names of a file:// registry,
+// are resolved against the registry's URL
+func (r *PluginRegistry) ResolveLocator(locator string) (*url.URL, error) {
+	ref, err := url.Parse(locator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid locator %q: %w", locator, err)
+	}
+
+	base := *r.URL
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	return base.ResolveReference(ref), nil
+}
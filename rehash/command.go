@@ -0,0 +1,135 @@
+package rehash
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+type Command struct {
+	Algorithm string   `short:"a" default:"sha256" enum:"sha256,sha384,sha512,blake2b" help:"The hash algorithm to record. One of sha256, sha384, sha512 or blake2b"`
+	Plugins   []string `arg:"" optional:"" help:"The plugins to rehash, as plugin or plugin@version. A plugin without a version rehashes every installed version. Defaults to all installed plugins"`
+}
+
+func (c *Command) Run(ctx *kong.Context) error {
+	refs, err := c.resolveRefs()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, ref := range refs {
+		if err := c.rehash(ctx, ref); err != nil {
+			fmt.Fprintf(ctx.Stderr, "%s: %v\n", ref, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d plugins could not be rehashed", failed, len(refs))
+	}
+
+	return nil
+}
+
+// resolveRefs expands the requested plugins into one reference per installed
+// version
+func (c *Command) resolveRefs() ([]string, error) {
+	names := c.Plugins
+	if len(names) == 0 {
+		home, err := runner.PluginHome()
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err := os.ReadDir(home)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+				names = append(names, entry.Name())
+			}
+		}
+	}
+
+	refs := []string{}
+	for _, name := range names {
+		pluginName, version, err := runner.ParsePluginRef(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if version != "" {
+			refs = append(refs, name)
+			continue
+		}
+
+		versions, err := runner.InstalledVersions(pluginName)
+		if err != nil {
+			return nil, err
+		}
+
+		// plugins installed before versions were kept side by side have a
+		// single, unversioned install
+		if len(versions) == 0 {
+			refs = append(refs, pluginName)
+			continue
+		}
+
+		for _, v := range versions {
+			refs = append(refs, runner.PluginRef(pluginName, v))
+		}
+	}
+
+	return refs, nil
+}
+
+func (c *Command) rehash(ctx *kong.Context, ref string) error {
+	dir, err := runner.ResolvePluginDir(ref)
+	if err != nil {
+		return err
+	}
+
+	bom, err := runner.ReadBOM(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: no bill of materials in %s", runner.ErrPluginNotFound, filepath.Dir(dir))
+		}
+		return err
+	}
+
+	if bom.Integrity == nil {
+		return errors.New("no integrity was recorded when the plugin was installed, reinstall it instead")
+	}
+
+	// the plugin must still match what was recorded, otherwise rehashing would
+	// bless whatever changed since it was installed
+	if err = runner.VerifyIntegrity(dir, bom); err != nil {
+		return err
+	}
+
+	integrity, err := runner.ComputeIntegrity(dir, bom, c.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	previous := bom.Integrity.Algorithm
+	if previous == "" {
+		previous = runner.DefaultHashAlgorithm
+	}
+
+	bom.Integrity = integrity
+	if err = runner.WriteBOM(dir, bom); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(ctx.Stdout, "Rehashed %s from %s to %s\n", ref, previous, integrity.Algorithm)
+	return nil
+}
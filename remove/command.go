@@ -12,7 +12,7 @@ import (
 )
 
 type Command struct {
-	PluginName     string    `arg:"" required:"true" help:"The name of the plugin to remove"`
+	PluginName     string    `arg:"" required:"true" help:"The name of the plugin to remove, or plugin@version to remove a single version"`
 	NonInteractive bool      `short:"f" default:"false" help:"Remove the plugin without asking first"`
 	out            io.Writer `kong:"-"`
 	in             io.Reader `kong:"-"`
@@ -26,11 +26,31 @@ func (c *Command) BeforeApply(ctx *kong.Context, stdin io.Reader) error {
 }
 
 func (c *Command) Run() error {
-	pluginDir, err := runner.PluginDir(c.PluginName, false)
+	pluginName, version, err := runner.ParsePluginRef(c.PluginName)
+	if err != nil {
+		return err
+	}
+
+	pluginDir, err := runner.PluginDir(pluginName, false)
 	if err != nil {
 		return fmt.Errorf("could not get plugin directory: %w", err)
 	}
 
+	if version != "" {
+		active, err := runner.ActiveVersion(pluginName)
+		if err != nil {
+			return err
+		}
+
+		if active == version {
+			return fmt.Errorf("%s is the active version of %s. Use another version first, or remove the whole plugin", version, pluginName)
+		}
+
+		if pluginDir, err = runner.ResolvePluginDir(c.PluginName); err != nil {
+			return err
+		}
+	}
+
 	doDelete := c.NonInteractive
 	if !doDelete {
 		scanner := bufio.NewScanner(c.in)
@@ -0,0 +1,37 @@
+package rollback
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+type Command struct {
+	PluginName string `arg:"" help:"The name of the plugin to roll back to its previously active version"`
+}
+
+func (c *Command) Run(ctx *kong.Context) error {
+	if _, err := runner.LoadPluginBOM(c.PluginName); err != nil {
+		if errors.Is(err, runner.ErrPluginNotFound) {
+			return fmt.Errorf("plugin %q is not installed", c.PluginName)
+		}
+		return err
+	}
+
+	previous, err := runner.PreviousVersion(c.PluginName)
+	if err != nil {
+		return fmt.Errorf("could not roll back %s: %w", c.PluginName, err)
+	}
+
+	if err = runner.SetActiveVersion(c.PluginName, previous); err != nil {
+		if errors.Is(err, runner.ErrPluginVersionNotFound) {
+			return fmt.Errorf("could not roll back %s: version %s has been removed", c.PluginName, previous)
+		}
+		return err
+	}
+
+	fmt.Fprintf(ctx.Stdout, "Rolled %s back to version %s\n", c.PluginName, previous)
+	return nil
+}
@@ -1,7 +1,9 @@
 package runner
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,45 +27,71 @@ const (
 type PluginIntegrity struct {
 	Checksum  string `toml:"checksum"`
 	Algorithm string `toml:"algorithm"`
+	Scope     string `toml:"scope,omitempty"`
 }
 
 type PluginSource struct {
 	Type string `toml:"type"`
 	Name string `toml:"name"`
 	URL  string `toml:"url,omitempty"`
+	// Mirror is the mirror of the registry the plugin was downloaded from
+	Mirror string `toml:"mirror,omitempty"`
 }
 
 type BillOfMaterials struct {
-	Name      string               `toml:"name"`
-	Type      PluginType           `toml:"type"`
-	Source    PluginSource         `toml:"source"`
-	Version   plugin.Version       `toml:"version,omitempty"`
-	Integrity *PluginIntegrity     `toml:"integrity,omitempty"`
-	Provides  []plugin.CommandInfo `toml:"provides,omitempty"`
+	Name      string           `toml:"name"`
+	Type      PluginType       `toml:"type"`
+	Source    PluginSource     `toml:"source"`
+	Version   plugin.Version   `toml:"version,omitempty"`
+	Integrity *PluginIntegrity `toml:"integrity,omitempty"`
+	// Signer is set when the plugin's signature was verified on install
+	Signer *PluginSigner `toml:"signer,omitempty"`
+	// Interpreter and EntryPoint are relative to the plugin's directory
+	Interpreter string               `toml:"interpreter,omitempty"`
+	EntryPoint  string               `toml:"entry-point,omitempty"`
+	Provides    []plugin.CommandInfo `toml:"provides,omitempty"`
 }
 
+// FindPluginsForCommand returns the plugin reference and command name of every
+// installed plugin providing cmdName. cmdName may be qualified with a plugin
+// as plugin/command or plugin@version/command
 func FindPluginsForCommand(cmdName string) ([][2]string, error) {
-	boms, err := LoadInstalledBOMs()
-	if err != nil {
-		return nil, err
+	ref := ""
+	if idx := strings.Index(cmdName, "/"); idx >= 0 {
+		ref, cmdName = cmdName[:idx], cmdName[idx+1:]
 	}
 
-	if strings.Contains(cmdName, "/") {
-		parts := strings.Split(cmdName, "/")
-		for i := 0; i < len(boms); i++ {
-			if parts[0] == boms[i].Name {
-				boms = []BillOfMaterials{boms[i]}
-				break
-			}
+	var boms []BillOfMaterials
+	if ref != "" {
+		bom, err := LoadPluginBOM(ref)
+		if err != nil && !errors.Is(err, ErrPluginNotFound) {
+			return nil, err
+		}
+
+		if err == nil {
+			boms = []BillOfMaterials{bom}
 		}
 	}
 
+	if boms == nil {
+		var err error
+		if boms, err = LoadInstalledBOMs(); err != nil {
+			return nil, err
+		}
+		ref = ""
+	}
+
 	matching := [][2]string{}
-	cmdName = cmdName[strings.Index(cmdName, "/")+1:]
 	for _, bom := range boms {
 		for _, c := range bom.Provides {
 			if c.Name == cmdName {
-				matching = append(matching, [2]string{bom.Name, c.Name})
+				pluginRef := bom.Name
+				// LoadPluginBOM already refused a ref with an invalid version
+				if _, version, _ := ParsePluginRef(ref); version != "" {
+					pluginRef = PluginRef(bom.Name, version)
+				}
+
+				matching = append(matching, [2]string{pluginRef, c.Name})
 				break
 			}
 		}
@@ -72,60 +100,81 @@ func FindPluginsForCommand(cmdName string) ([][2]string, error) {
 	return matching, nil
 }
 
-func LoadPluginBOM(pluginName string) (BillOfMaterials, error) {
-	boms, err := loadBoms(pluginName)
+// LoadPluginBOM loads the bill of materials for a plugin reference of the form
+// name[@version]. Without a version, the active version is loaded
+func LoadPluginBOM(ref string) (BillOfMaterials, error) {
+	pluginName, _, err := ParsePluginRef(ref)
 	if err != nil {
 		return BillOfMaterials{}, err
 	}
 
-	if len(boms) == 0 {
+	root, err := PluginDir(pluginName, false)
+	if err != nil {
+		return BillOfMaterials{}, err
+	}
+
+	if _, err = os.Stat(root); os.IsNotExist(err) {
 		return BillOfMaterials{}, ErrPluginNotFound
 	}
 
-	return boms[0], nil
-}
+	dir, err := ResolvePluginDir(ref)
+	if err != nil {
+		return BillOfMaterials{}, err
+	}
 
-func LoadInstalledBOMs() ([]BillOfMaterials, error) {
-	return loadBoms("*")
+	bom, err := ReadBOM(dir)
+	if os.IsNotExist(err) {
+		return BillOfMaterials{}, ErrPluginNotFound
+	}
+
+	return bom, err
 }
 
-func loadBoms(dir string) ([]BillOfMaterials, error) {
+// LoadInstalledBOMs loads the bill of materials of the active version of
+// every installed plugin
+func LoadInstalledBOMs() ([]BillOfMaterials, error) {
 	home, err := PluginHome()
 	if err != nil {
 		return nil, err
 	}
 
-	pattern := filepath.Join(home, dir, "export-plugin.bom")
-	matches, err := filepath.Glob(pattern)
+	entries, err := os.ReadDir(home)
 	if err != nil {
 		return nil, err
 	}
 
-	boms := make([]BillOfMaterials, 0, len(matches))
+	boms := make([]BillOfMaterials, 0, len(entries))
 	lock := &sync.Mutex{}
 	errs := []error{}
 
 	wg := &sync.WaitGroup{}
-	wg.Add(len(matches))
-	for _, match := range matches {
-		go func(bomFile string) {
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		wg.Add(1)
+		go func(pluginName string) {
 			defer wg.Done()
-			bomBytes, err := os.ReadFile(bomFile)
-			if err != nil {
-				errs = append(errs, err)
-				return
+			dir, err := ResolvePluginDir(pluginName)
+			if err == nil {
+				var bom BillOfMaterials
+				if bom, err = ReadBOM(dir); err == nil {
+					lock.Lock()
+					boms = append(boms, bom)
+					lock.Unlock()
+					return
+				}
 			}
 
-			var bom BillOfMaterials
-			if err = toml.Unmarshal(bomBytes, &bom); err != nil {
-				errs = append(errs, err)
+			if os.IsNotExist(err) {
 				return
 			}
 
 			lock.Lock()
-			boms = append(boms, bom)
+			errs = append(errs, err)
 			lock.Unlock()
-		}(match)
+		}(entry.Name())
 	}
 	wg.Wait()
 
@@ -143,3 +192,29 @@ func loadBoms(dir string) ([]BillOfMaterials, error) {
 
 	return boms, nil
 }
+
+func ReadBOM(dir string) (BillOfMaterials, error) {
+	var bom BillOfMaterials
+
+	bomBytes, err := os.ReadFile(filepath.Join(dir, bomFileName))
+	if err != nil {
+		return bom, err
+	}
+
+	if err = toml.Unmarshal(bomBytes, &bom); err != nil {
+		return bom, fmt.Errorf("could not parse %s: %w", filepath.Join(dir, bomFileName), err)
+	}
+
+	return bom, nil
+}
+
+// WriteBOM writes the bill of materials for the plugin installed in dir. The
+// file is replaced atomically so a BOM being rewritten is never half written
+func WriteBOM(dir string, bom BillOfMaterials) error {
+	buf := &bytes.Buffer{}
+	if err := toml.NewEncoder(buf).Encode(bom); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(dir, bomFileName), buf.Bytes(), 0o644)
+}
@@ -0,0 +1,226 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	SHA256  = "sha256"
+	SHA384  = "sha384"
+	SHA512  = "sha512"
+	BLAKE2b = "blake2b"
+
+	DefaultHashAlgorithm = SHA256
+)
+
+var ErrUnknownHashAlgorithm = errors.New("unknown hash algorithm")
+
+var hashAlgorithms = map[string]func() hash.Hash{
+	SHA256: sha256.New,
+	SHA384: sha512.New384,
+	SHA512: sha512.New,
+	BLAKE2b: func() hash.Hash {
+		// New512 only fails for keys that are too long
+		h, _ := blake2b.New512(nil)
+		return h
+	},
+}
+
+// HashAlgorithms lists the supported hash algorithms, weakest first
+var HashAlgorithms = []string{SHA256, SHA384, SHA512, BLAKE2b}
+
+// NewHash returns a new hash for a supported algorithm. Bills of materials
+// written before algorithms were configurable have no algorithm, and mean sha256
+func NewHash(algorithm string) (hash.Hash, error) {
+	newHash, err := hashConstructor(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return newHash(), nil
+}
+
+func normalizeAlgorithm(algorithm string) string {
+	algorithm = strings.ToLower(strings.TrimSpace(algorithm))
+	if algorithm == "" {
+		return DefaultHashAlgorithm
+	}
+
+	return algorithm
+}
+
+func hashConstructor(algorithm string) (func() hash.Hash, error) {
+	algorithm = normalizeAlgorithm(algorithm)
+	newHash, ok := hashAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, supported algorithms are %s", ErrUnknownHashAlgorithm, algorithm, strings.Join(HashAlgorithms, ", "))
+	}
+
+	return newHash, nil
+}
+
+// ValidateHashAlgorithm returns an error if algorithm isn't supported
+func ValidateHashAlgorithm(algorithm string) error {
+	_, err := hashConstructor(algorithm)
+	return err
+}
+
+// ComputeIntegrity hashes an installed plugin with algorithm. The scope of the
+// existing integrity in bom is kept: the whole directory for tree integrity,
+// otherwise the file that is launched
+func ComputeIntegrity(dir string, bom BillOfMaterials, algorithm string) (*PluginIntegrity, error) {
+	newHash, err := hashConstructor(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := IntegrityFile
+	if bom.Integrity != nil && bom.Integrity.Scope == IntegrityTree {
+		scope = IntegrityTree
+	}
+
+	var sum []byte
+	if scope == IntegrityTree {
+		sum, err = treeHash(newHash, dir, true)
+	} else {
+		sum, err = fileHash(newHash, integrityTarget(dir, bom))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	integrity := &PluginIntegrity{
+		Checksum:  fmt.Sprintf("%x", sum),
+		Algorithm: normalizeAlgorithm(algorithm),
+	}
+
+	if scope == IntegrityTree {
+		integrity.Scope = IntegrityTree
+	}
+
+	return integrity, nil
+}
+
+// VerifyIntegrity checks an installed plugin against the integrity recorded in
+// its bill of materials
+func VerifyIntegrity(dir string, bom BillOfMaterials) error {
+	if bom.Integrity == nil {
+		return nil
+	}
+
+	computed, err := ComputeIntegrity(dir, bom, bom.Integrity.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(computed.Checksum, bom.Integrity.Checksum) {
+		what := integrityTarget(dir, bom)
+		if computed.Scope == IntegrityTree {
+			what = dir
+		}
+
+		return fmt.Errorf("the contents of %s have changed since the plugin was installed: %s checksum is %s but %s was recorded", what, computed.Algorithm, computed.Checksum, bom.Integrity.Checksum)
+	}
+
+	return nil
+}
+
+// FileChecksum returns the hex encoded checksum of a file, such as a local
+// plugin that may already be installed
+func FileChecksum(path, algorithm string) (string, error) {
+	newHash, err := hashConstructor(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	sum, err := fileHash(newHash, path)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", sum), nil
+}
+
+// integrityTarget is the file covered by a file-scoped checksum
+func integrityTarget(dir string, bom BillOfMaterials) string {
+	if bom.Type == Python && bom.EntryPoint != "" {
+		return filepath.Join(dir, bom.EntryPoint)
+	}
+
+	return filepath.Join(dir, executableName)
+}
+
+func fileHash(newHash func() hash.Hash, path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	h := newHash()
+	if _, err = io.Copy(h, file); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// MultiHash computes the hashes of several algorithms in a single pass
+type MultiHash struct {
+	hashes map[string]hash.Hash
+}
+
+func NewMultiHash(algorithms ...string) (*MultiHash, error) {
+	m := &MultiHash{hashes: make(map[string]hash.Hash, len(algorithms))}
+	for _, alg := range algorithms {
+		h, err := NewHash(alg)
+		if err != nil {
+			return nil, err
+		}
+
+		m.hashes[normalizeAlgorithm(alg)] = h
+	}
+
+	return m, nil
+}
+
+func (m *MultiHash) Write(p []byte) (int, error) {
+	for _, h := range m.hashes {
+		h.Write(p)
+	}
+
+	return len(p), nil
+}
+
+// Sum returns the hex encoded checksum for one of the algorithms
+func (m *MultiHash) Sum(algorithm string) string {
+	h, ok := m.hashes[normalizeAlgorithm(algorithm)]
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// Verify compares the computed checksums with expected checksums keyed by
+// algorithm
+func (m *MultiHash) Verify(expected map[string]string) error {
+	for alg, checksum := range expected {
+		if computed := m.Sum(alg); !strings.EqualFold(computed, checksum) {
+			return fmt.Errorf("calculated %s checksum %q does not match provided checksum %q", normalizeAlgorithm(alg), computed, checksum)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// HTTPOptions configure how registries and plugin executables are reached.
+// Unset options fall back to the environment: $TFE_CA_BUNDLE,
+// $TFE_CLIENT_CERT and $TFE_CLIENT_KEY, and the usual $HTTPS_PROXY,
+// $HTTP_PROXY and $NO_PROXY
+type HTTPOptions struct {
+	// CABundle is a PEM file of certificate authorities trusted in addition to
+	// the system's
+	CABundle string `yaml:"caBundle,omitempty"`
+	// ClientCert and ClientKey are PEM files of a client certificate for mutual
+	// TLS
+	ClientCert string `yaml:"clientCert,omitempty"`
+	ClientKey  string `yaml:"clientKey,omitempty"`
+	// Proxy is the URL of the proxy for http and https requests
+	Proxy string `yaml:"proxy,omitempty"`
+	// NoProxy lists hosts reached without the proxy, in the format of $NO_PROXY
+	NoProxy string `yaml:"noProxy,omitempty"`
+}
+
+// Merge returns o with every unset option taken from defaults
+func (o HTTPOptions) Merge(defaults HTTPOptions) HTTPOptions {
+	if o.CABundle == "" {
+		o.CABundle = defaults.CABundle
+	}
+	if o.ClientCert == "" && o.ClientKey == "" {
+		o.ClientCert = defaults.ClientCert
+		o.ClientKey = defaults.ClientKey
+	}
+	if o.Proxy == "" {
+		o.Proxy = defaults.Proxy
+	}
+	if o.NoProxy == "" {
+		o.NoProxy = defaults.NoProxy
+	}
+
+	return o
+}
+
+var (
+	defaultHTTPOptions HTTPOptions
+	httpClients        = map[HTTPOptions]*http.Client{}
+	httpClientsLock    = &sync.Mutex{}
+)
+
+// SetDefaultHTTPOptions sets the options of the default client, used for every
+// request that doesn't have options of its own
+func SetDefaultHTTPOptions(o HTTPOptions) {
+	httpClientsLock.Lock()
+	defer httpClientsLock.Unlock()
+
+	defaultHTTPOptions = o
+}
+
+// DefaultHTTPOptions returns the default client options, with the environment
+// filling in what isn't set
+func DefaultHTTPOptions() HTTPOptions {
+	httpClientsLock.Lock()
+	defer httpClientsLock.Unlock()
+
+	return defaultHTTPOptions.Merge(HTTPOptions{
+		CABundle:   os.Getenv("TFE_CA_BUNDLE"),
+		ClientCert: os.Getenv("TFE_CLIENT_CERT"),
+		ClientKey:  os.Getenv("TFE_CLIENT_KEY"),
+	})
+}
+
+// GetHTTPClient returns the client for requests without options of their own
+func GetHTTPClient() (*http.Client, error) {
+	return NewHTTPClient(HTTPOptions{})
+}
+
+// NewHTTPClient returns a client configured with o, merged with the default
+// options. Clients are shared between callers with the same options
+func NewHTTPClient(o HTTPOptions) (*http.Client, error) {
+	o = o.Merge(DefaultHTTPOptions())
+
+	httpClientsLock.Lock()
+	defer httpClientsLock.Unlock()
+
+	if hc, ok := httpClients[o]; ok {
+		return hc, nil
+	}
+
+	transport, err := newTransport(o)
+	if err != nil {
+		return nil, err
+	}
+
+	hc := &http.Client{Transport: transport}
+	httpClients[o] = hc
+
+	return hc, nil
+}
+
+func newTransport(o HTTPOptions) (*http.Transport, error) {
+	skipVerify, _ := strconv.ParseBool(os.Getenv("TF_EXPORTER_INSTALL_SKIP_TLS_VERIFY"))
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: skipVerify,
+	}
+
+	if o.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		pem, err := os.ReadFile(o.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle: %w", err)
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA bundle %s has no PEM encoded certificates", o.CABundle)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if o.ClientCert != "" || o.ClientKey != "" {
+		if o.ClientCert == "" || o.ClientKey == "" {
+			return nil, fmt.Errorf("a client certificate needs both a certificate and a key file")
+		}
+
+		cert, err := tls.LoadX509KeyPair(o.ClientCert, o.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	if o.Proxy != "" || o.NoProxy != "" {
+		env := httpproxy.FromEnvironment()
+		if o.Proxy != "" {
+			if _, err := url.Parse(o.Proxy); err != nil {
+				return nil, fmt.Errorf("invalid proxy URL %q: %w", o.Proxy, err)
+			}
+
+			env.HTTPProxy = o.Proxy
+			env.HTTPSProxy = o.Proxy
+		}
+
+		if o.NoProxy != "" {
+			env.NoProxy = o.NoProxy
+		}
+
+		proxy := env.ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxy(req.URL)
+		}
+	}
+
+	return transport, nil
+}
@@ -1,7 +1,6 @@
 package runner
 
 import (
-	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -9,9 +8,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 
-	"github.com/BurntSushi/toml"
 	plugin "github.com/gideaworx/terraform-exporter-plugin-go"
 	"github.com/hashicorp/go-hclog"
 	goplug "github.com/hashicorp/go-plugin"
@@ -132,55 +131,148 @@ func PluginDir(pluginName string, create bool) (string, error) {
 	return pluginDir, nil
 }
 
+// LoadPlugin launches a plugin and performs the handshake. pluginName is either
+// an installed plugin reference (name or name@version) or, if it contains a
+// path separator, the path to a plugin executable. If integrity is nil, the
+// integrity recorded in the plugin's bill of materials is used
 func LoadPlugin(pluginName string, integrity *PluginIntegrity) (PluginDefinition, error) {
-	var executable string
-	var err error
-
-	home, err := PluginHome()
-	if err != nil {
-		return PluginDefinition{}, err
-	}
-
-	executable = filepath.Join(home, pluginName, "export-plugin")
-	if strings.ContainsRune(pluginName, '/') {
-		executable, err = filepath.Abs(pluginName)
+	if isPluginPath(pluginName) {
+		executable, err := filepath.Abs(pluginName)
 		if err != nil {
 			return PluginDefinition{}, err
 		}
-	}
 
-	var checksum []byte
-	var sc *goplug.SecureConfig
-	if integrity == nil {
-		bomFile := filepath.Join(home, pluginName, "export-plugin.bom")
-		bomBytes, err := os.ReadFile(bomFile)
+		sc, err := secureConfig(integrity)
 		if err != nil {
 			return PluginDefinition{}, err
 		}
 
-		var bom BillOfMaterials
-		if err = toml.Unmarshal(bomBytes, &bom); err != nil {
-			return PluginDefinition{}, err
-		}
+		return launch(exec.Command(executable), sc)
+	}
 
-		integrity = bom.Integrity
+	dir, err := ResolvePluginDir(pluginName)
+	if err != nil {
+		return PluginDefinition{}, err
+	}
+
+	bom, err := ReadBOM(dir)
+	if err != nil {
+		return PluginDefinition{}, err
 	}
 
 	if integrity != nil {
-		checksum, err = hex.DecodeString(integrity.Checksum)
-		if err != nil {
-			return PluginDefinition{}, err
+		bom.Integrity = integrity
+	}
+
+	return LoadPluginFromDir(dir, bom)
+}
+
+// isPluginPath reports whether LoadPlugin was given the path of an executable
+// rather than the name of an installed plugin. Windows paths may use either
+// slash
+func isPluginPath(pluginName string) bool {
+	return strings.ContainsRune(pluginName, '/') || strings.ContainsRune(pluginName, filepath.Separator) || filepath.IsAbs(pluginName)
+}
+
+// LoadPluginFromDir launches the plugin installed in dir, as described by bom.
+// Installers use it to launch a plugin before it is moved into place
+func LoadPluginFromDir(dir string, bom BillOfMaterials) (PluginDefinition, error) {
+	treeVerified := false
+	if bom.Integrity != nil && bom.Integrity.Scope == IntegrityTree {
+		if err := VerifyIntegrity(dir, bom); err != nil {
+			return PluginDefinition{}, fmt.Errorf("refusing to launch plugin %s: %w", bom.Name, err)
 		}
-		sc = &goplug.SecureConfig{
-			Hash:     sha256.New(),
-			Checksum: checksum,
+		treeVerified = true
+	}
+
+	if bom.Type == Python && bom.Interpreter != "" {
+		return launchPython(dir, bom, treeVerified)
+	}
+
+	if treeVerified {
+		return launch(exec.Command(filepath.Join(dir, executableName)), nil)
+	}
+
+	sc, err := secureConfig(bom.Integrity)
+	if err != nil {
+		return PluginDefinition{}, err
+	}
+
+	return launch(exec.Command(filepath.Join(dir, executableName)), sc)
+}
+
+// launchPython runs a plugin's entry point with the interpreter of the virtual
+// environment it was installed into. go-plugin can only verify the checksum
+// of the command it runs, which is the interpreter, so unless the whole tree
+// has been verified the entry point is verified here before launching instead.
+// Bytecode is part of the tree hash, so python must not write any while it runs
+func launchPython(dir string, bom BillOfMaterials, treeVerified bool) (PluginDefinition, error) {
+	interpreter := filepath.Join(dir, bom.Interpreter)
+	entryPoint := filepath.Join(dir, bom.EntryPoint)
+
+	if err := checkVirtualEnv(interpreter, entryPoint); err != nil {
+		return PluginDefinition{}, fmt.Errorf("the python virtual environment of plugin %s is missing or broken, reinstall the plugin to repair it: %w", bom.Name, err)
+	}
+
+	if !treeVerified {
+		if err := VerifyIntegrity(dir, bom); err != nil {
+			return PluginDefinition{}, fmt.Errorf("refusing to launch plugin %s: %w", bom.Name, err)
 		}
 	}
 
+	cmd := exec.Command(interpreter, entryPoint)
+	cmd.Env = append(os.Environ(), "PYTHONDONTWRITEBYTECODE=1")
+	return launch(cmd, nil)
+}
+
+func checkVirtualEnv(interpreter, entryPoint string) error {
+	venvDir := filepath.Dir(filepath.Dir(interpreter))
+	if _, err := os.Stat(filepath.Join(venvDir, "pyvenv.cfg")); err != nil {
+		return err
+	}
+
+	// the interpreter is usually a symlink to the python it was created with,
+	// which breaks if that python is uninstalled or upgraded
+	info, err := os.Stat(interpreter)
+	if err != nil {
+		return err
+	}
+
+	// Windows has no executable bit, python.exe is executable by its name
+	if info.IsDir() || (runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0) {
+		return fmt.Errorf("%s is not executable", interpreter)
+	}
+
+	_, err = os.Stat(entryPoint)
+	return err
+}
+
+func secureConfig(integrity *PluginIntegrity) (*goplug.SecureConfig, error) {
+	if integrity == nil {
+		return nil, nil
+	}
+
+	checksum, err := hex.DecodeString(integrity.Checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := NewHash(integrity.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &goplug.SecureConfig{
+		Hash:     h,
+		Checksum: checksum,
+	}, nil
+}
+
+func launch(cmd *exec.Cmd, sc *goplug.SecureConfig) (PluginDefinition, error) {
 	client := goplug.NewClient(&goplug.ClientConfig{
 		HandshakeConfig:  plugin.HandshakeConfig,
 		VersionedPlugins: pluginMap,
-		Cmd:              exec.Command(executable),
+		Cmd:              cmd,
 		SecureConfig:     sc,
 		Logger: hclog.New(&hclog.LoggerOptions{
 			Name:   "plugin",
@@ -212,7 +304,7 @@ func LoadPlugin(pluginName string, integrity *PluginIntegrity) (PluginDefinition
 	return PluginDefinition{
 		info:       info,
 		p:          ep,
-		executable: executable,
+		executable: cmd.Path,
 		client:     client,
 	}, nil
 }
@@ -226,3 +318,13 @@ func LoadPluginInfo(pluginName string, integrity *PluginIntegrity) (plugin.Plugi
 
 	return pd.PluginInfo(), nil
 }
+
+func LoadPluginInfoFromDir(dir string, bom BillOfMaterials) (plugin.PluginInformation, error) {
+	pd, err := LoadPluginFromDir(dir, bom)
+	if err != nil {
+		return plugin.PluginInformation{}, err
+	}
+	defer pd.client.Kill()
+
+	return pd.PluginInfo(), nil
+}
@@ -0,0 +1,113 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestIsPluginPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path bool
+	}{
+		{name: "aws"},
+		{name: "aws@1.2.3"},
+		{name: "./export-plugin", path: true},
+		{name: "build/export-plugin", path: true},
+		{name: filepath.Join("build", "export-plugin"), path: true},
+		{name: filepath.Join(os.TempDir(), "export-plugin"), path: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if path := isPluginPath(tt.name); path != tt.path {
+				t.Errorf("isPluginPath(%q) = %v, want %v", tt.name, path, tt.path)
+			}
+		})
+	}
+}
+
+func TestCheckVirtualEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup breaks the virtual environment created for each test
+		setup  func(venv, interpreter, entryPoint string) error
+		broken bool
+	}{
+		{
+			name:  "healthy",
+			setup: func(string, string, string) error { return nil },
+		},
+		{
+			name: "not a virtual environment",
+			setup: func(venv, _, _ string) error {
+				return os.Remove(filepath.Join(venv, "pyvenv.cfg"))
+			},
+			broken: true,
+		},
+		{
+			name: "interpreter removed",
+			setup: func(_, interpreter, _ string) error {
+				return os.Remove(interpreter)
+			},
+			broken: true,
+		},
+		{
+			name: "interpreter is a directory",
+			setup: func(_, interpreter, _ string) error {
+				if err := os.Remove(interpreter); err != nil {
+					return err
+				}
+				return os.Mkdir(interpreter, 0o755)
+			},
+			broken: true,
+		},
+		{
+			// Windows has no executable bit to lose
+			name: "interpreter not executable",
+			setup: func(_, interpreter, _ string) error {
+				return os.Chmod(interpreter, 0o644)
+			},
+			broken: runtime.GOOS != "windows",
+		},
+		{
+			name: "entry point removed",
+			setup: func(_, _, entryPoint string) error {
+				return os.Remove(entryPoint)
+			},
+			broken: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			venv := t.TempDir()
+			bin := filepath.Join(venv, "bin")
+			interpreter, entryPoint := filepath.Join(bin, "python"), filepath.Join(bin, "export-plugin")
+			if runtime.GOOS == "windows" {
+				bin = filepath.Join(venv, "Scripts")
+				interpreter, entryPoint = filepath.Join(bin, "python.exe"), filepath.Join(bin, "export-plugin.exe")
+			}
+
+			if err := os.MkdirAll(bin, 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, f := range []string{filepath.Join(venv, "pyvenv.cfg"), interpreter, entryPoint} {
+				if err := os.WriteFile(f, nil, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := tt.setup(venv, interpreter, entryPoint); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := checkVirtualEnv(interpreter, entryPoint); (err != nil) != tt.broken {
+				t.Errorf("checkVirtualEnv() = %v, want broken = %v", err, tt.broken)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const DEFAULT_LOCK_FILE = ".tf-exporter.lock"
+
+var ErrLockMismatch = errors.New("installed plugin does not match the lock file")
+
+type LockedPlugin struct {
+	Name      string           `toml:"name"`
+	Version   string           `toml:"version"`
+	Source    PluginSource     `toml:"source"`
+	Integrity *PluginIntegrity `toml:"integrity,omitempty"`
+}
+
+type LockFile struct {
+	Plugins []LockedPlugin `toml:"plugin"`
+	path    string
+}
+
+// LoadLockFile reads the project lock file at path. A missing file is not an
+// error, it just yields an empty lock file that will be created on Save.
+func LoadLockFile(path string) (*LockFile, error) {
+	lock := &LockFile{path: path}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return lock, nil
+		}
+		return nil, err
+	}
+
+	if err = toml.Unmarshal(contents, lock); err != nil {
+		return nil, fmt.Errorf("could not parse lock file %s: %w", path, err)
+	}
+
+	return lock, nil
+}
+
+func LockFromBOM(bom BillOfMaterials) LockedPlugin {
+	// which mirror served a plugin doesn't change what's locked
+	source := bom.Source
+	source.Mirror = ""
+
+	return LockedPlugin{
+		Name:      bom.Name,
+		Version:   bom.Version.String(),
+		Source:    source,
+		Integrity: bom.Integrity,
+	}
+}
+
+func (l *LockFile) Get(pluginName string) (LockedPlugin, bool) {
+	for _, p := range l.Plugins {
+		if p.Name == pluginName {
+			return p, true
+		}
+	}
+
+	return LockedPlugin{}, false
+}
+
+func (l *LockFile) Set(locked LockedPlugin) {
+	for i := range l.Plugins {
+		if l.Plugins[i].Name == locked.Name {
+			l.Plugins[i] = locked
+			return
+		}
+	}
+
+	l.Plugins = append(l.Plugins, locked)
+}
+
+// Verify compares an installed plugin against its lock entry, if there is one.
+// It returns true if the plugin was found in the lock file.
+func (l *LockFile) Verify(bom BillOfMaterials) (bool, error) {
+	locked, ok := l.Get(bom.Name)
+	if !ok {
+		return false, nil
+	}
+
+	installed := LockFromBOM(bom)
+	problems := []string{}
+	if locked.Version != installed.Version {
+		problems = append(problems, fmt.Sprintf("locked version is %s but %s is installed", locked.Version, installed.Version))
+	}
+
+	// local files are recorded by their path and registries can move, neither
+	// is the same on every checkout. The checksum proves it's the same plugin
+	if locked.Source.Type != installed.Source.Type || (locked.Source.Type == "registry" && locked.Source.Name != installed.Source.Name) {
+		problems = append(problems, fmt.Sprintf("locked source is %s but it was installed from %s", locked.Source, installed.Source))
+	}
+
+	if locked.Integrity != nil {
+		if installed.Integrity == nil {
+			problems = append(problems, "the installed plugin has no integrity information")
+		} else if !strings.EqualFold(locked.Integrity.Checksum, installed.Integrity.Checksum) || locked.Integrity.Algorithm != installed.Integrity.Algorithm {
+			problems = append(problems, fmt.Sprintf("locked checksum is %s but the installed checksum is %s", locked.Integrity, installed.Integrity))
+		}
+	}
+
+	if len(problems) > 0 {
+		return true, fmt.Errorf("%w: plugin %q in %s: %s", ErrLockMismatch, bom.Name, l.path, strings.Join(problems, ", "))
+	}
+
+	return true, nil
+}
+
+func (l *LockFile) Save() error {
+	sort.Slice(l.Plugins, func(i, j int) bool {
+		return l.Plugins[i].Name < l.Plugins[j].Name
+	})
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "# This file is maintained by terraform-exporter. Commit it to share plugin versions.")
+	if err := toml.NewEncoder(buf).Encode(l); err != nil {
+		return err
+	}
+
+	return os.WriteFile(l.path, buf.Bytes(), 0o644)
+}
+
+func (s PluginSource) String() string {
+	if s.URL != "" {
+		return fmt.Sprintf("%s %s (%s)", s.Type, s.Name, RedactURL(s.URL))
+	}
+
+	return fmt.Sprintf("%s %s", s.Type, s.Name)
+}
+
+func (i *PluginIntegrity) String() string {
+	if i == nil {
+		return "<none>"
+	}
+
+	return fmt.Sprintf("%s:%s", i.Algorithm, i.Checksum)
+}
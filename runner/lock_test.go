@@ -0,0 +1,176 @@
+package runner
+
+import (
+	"errors"
+	"testing"
+
+	plugin "github.com/gideaworx/terraform-exporter-plugin-go"
+)
+
+func TestLockFileVerify(t *testing.T) {
+	registryBOM := func() BillOfMaterials {
+		return BillOfMaterials{
+			Name:      "aws",
+			Type:      Native,
+			Version:   plugin.FromString("1.2.0"),
+			Source:    PluginSource{Type: "registry", Name: "default", URL: "https://plugins.example.com"},
+			Integrity: &PluginIntegrity{Checksum: "abc123", Algorithm: SHA256},
+		}
+	}
+
+	localBOM := func(path string) BillOfMaterials {
+		return BillOfMaterials{
+			Name:      "local",
+			Type:      Native,
+			Version:   plugin.FromString("0.1.0"),
+			Source:    PluginSource{Type: "local-file", Name: path},
+			Integrity: &PluginIntegrity{Checksum: "def456", Algorithm: SHA256},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		locked    BillOfMaterials
+		installed BillOfMaterials
+		found     bool
+		mismatch  bool
+	}{
+		{
+			name:      "identical",
+			locked:    registryBOM(),
+			installed: registryBOM(),
+			found:     true,
+		},
+		{
+			name:   "not locked",
+			locked: localBOM("/home/alice/plugins/local"),
+			installed: func() BillOfMaterials {
+				b := registryBOM()
+				b.Name = "gcp"
+				return b
+			}(),
+		},
+		{
+			name:   "different version",
+			locked: registryBOM(),
+			installed: func() BillOfMaterials {
+				b := registryBOM()
+				b.Version = plugin.FromString("1.3.0")
+				return b
+			}(),
+			found:    true,
+			mismatch: true,
+		},
+		{
+			name:   "different registry",
+			locked: registryBOM(),
+			installed: func() BillOfMaterials {
+				b := registryBOM()
+				b.Source.Name = "internal"
+				return b
+			}(),
+			found:    true,
+			mismatch: true,
+		},
+		{
+			name:   "registry moved",
+			locked: registryBOM(),
+			installed: func() BillOfMaterials {
+				b := registryBOM()
+				b.Source.URL = "https://mirror.example.com/plugins"
+				return b
+			}(),
+			found: true,
+		},
+		{
+			name:   "downloaded from a mirror",
+			locked: registryBOM(),
+			installed: func() BillOfMaterials {
+				b := registryBOM()
+				b.Source.Mirror = "https://mirror.example.com"
+				return b
+			}(),
+			found: true,
+		},
+		{
+			name:   "different source type",
+			locked: registryBOM(),
+			installed: func() BillOfMaterials {
+				b := registryBOM()
+				b.Source = PluginSource{Type: "local-file", Name: "/tmp/aws"}
+				return b
+			}(),
+			found:    true,
+			mismatch: true,
+		},
+		{
+			name:      "local file on another checkout",
+			locked:    localBOM("/home/alice/project/bin/local"),
+			installed: localBOM("/builds/ci/project/bin/local"),
+			found:     true,
+		},
+		{
+			name:   "different checksum",
+			locked: registryBOM(),
+			installed: func() BillOfMaterials {
+				b := registryBOM()
+				b.Integrity = &PluginIntegrity{Checksum: "ffffff", Algorithm: SHA256}
+				return b
+			}(),
+			found:    true,
+			mismatch: true,
+		},
+		{
+			name:   "checksum case",
+			locked: registryBOM(),
+			installed: func() BillOfMaterials {
+				b := registryBOM()
+				b.Integrity = &PluginIntegrity{Checksum: "ABC123", Algorithm: SHA256}
+				return b
+			}(),
+			found: true,
+		},
+		{
+			name:   "different algorithm",
+			locked: registryBOM(),
+			installed: func() BillOfMaterials {
+				b := registryBOM()
+				b.Integrity = &PluginIntegrity{Checksum: "abc123", Algorithm: SHA512}
+				return b
+			}(),
+			found:    true,
+			mismatch: true,
+		},
+		{
+			name:   "installed without integrity",
+			locked: registryBOM(),
+			installed: func() BillOfMaterials {
+				b := registryBOM()
+				b.Integrity = nil
+				return b
+			}(),
+			found:    true,
+			mismatch: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lock := &LockFile{path: DEFAULT_LOCK_FILE}
+			lock.Set(LockFromBOM(tt.locked))
+
+			found, err := lock.Verify(tt.installed)
+			if found != tt.found {
+				t.Errorf("found = %v, want %v", found, tt.found)
+			}
+
+			if tt.mismatch && !errors.Is(err, ErrLockMismatch) {
+				t.Errorf("err = %v, want %v", err, ErrLockMismatch)
+			}
+
+			if !tt.mismatch && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// SourceMigration is a change to the source recorded in the bills of material
+// of installed plugins. It covers every installed version, not just the active
+// ones, and can be rolled back as a whole
+type SourceMigration struct {
+	// Migrated are the references of the plugin versions whose source changed
+	Migrated  []string
+	originals map[string][]byte
+}
+
+type bomRewrite struct {
+	path     string
+	ref      string
+	original []byte
+	bom      BillOfMaterials
+}
+
+// MigrateBOMSources rewrites the source of every installed plugin version that
+// migrate changes. migrate reports whether it changed the source. Every bill of
+// materials is read before any is written, and if one can't be written the ones
+// already written are restored, so either all of them change or none do
+func MigrateBOMSources(migrate func(*PluginSource) bool) (*SourceMigration, error) {
+	home, err := PluginHome()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(home)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &SourceMigration{originals: map[string][]byte{}}, nil
+		}
+		return nil, err
+	}
+
+	rewrites := []bomRewrite{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		root := filepath.Join(home, entry.Name())
+		paths, err := filepath.Glob(filepath.Join(root, versionsDirName, "*", bomFileName))
+		if err != nil {
+			return nil, err
+		}
+
+		// plugins installed before versions were kept side by side have their
+		// bill of materials in the plugin's directory
+		if _, err = os.Stat(filepath.Join(root, bomFileName)); err == nil {
+			paths = append(paths, filepath.Join(root, bomFileName))
+		}
+
+		for _, path := range paths {
+			original, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+
+			var bom BillOfMaterials
+			if err = toml.Unmarshal(original, &bom); err != nil {
+				return nil, fmt.Errorf("could not parse %s: %w", path, err)
+			}
+
+			if !migrate(&bom.Source) {
+				continue
+			}
+
+			ref := bom.Name
+			if filepath.Dir(path) != root {
+				ref = PluginRef(bom.Name, filepath.Base(filepath.Dir(path)))
+			}
+
+			rewrites = append(rewrites, bomRewrite{path: path, ref: ref, original: original, bom: bom})
+		}
+	}
+
+	m := &SourceMigration{Migrated: make([]string, 0, len(rewrites)), originals: make(map[string][]byte, len(rewrites))}
+	for _, rw := range rewrites {
+		buf := &bytes.Buffer{}
+		if err = toml.NewEncoder(buf).Encode(rw.bom); err == nil {
+			err = writeFileAtomic(rw.path, buf.Bytes(), 0o644)
+		}
+
+		if err != nil {
+			if rbErr := m.Rollback(); rbErr != nil {
+				return nil, fmt.Errorf("could not update %s: %w. Restoring the bills of material already updated failed too: %v", rw.ref, err, rbErr)
+			}
+			return nil, fmt.Errorf("could not update %s, no bills of material were changed: %w", rw.ref, err)
+		}
+
+		m.originals[rw.path] = rw.original
+		m.Migrated = append(m.Migrated, rw.ref)
+	}
+
+	sort.Strings(m.Migrated)
+	return m, nil
+}
+
+// Rollback restores every bill of materials the migration changed
+func (m *SourceMigration) Rollback() error {
+	failed := []string{}
+	for path, original := range m.originals {
+		if err := writeFileAtomic(path, original, 0o644); err != nil {
+			failed = append(failed, err.Error())
+			continue
+		}
+		delete(m.originals, path)
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not restore %d bills of material: %s", len(failed), strings.Join(failed, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	plugin "github.com/gideaworx/terraform-exporter-plugin-go"
+)
+
+const (
+	oldRegistryURL = "https://plugins.example.com"
+	newRegistryURL = "https://plugins.example.org"
+)
+
+// installBOMs writes the bills of material of an installed aws plugin with two
+// versions and a gcp plugin from the "corp" registry, a plugin from another
+// registry, a local file, and a plugin installed before versions were kept
+// side by side. It returns their paths
+func installBOMs(t *testing.T, home string) map[string]string {
+	t.Helper()
+
+	registryBOM := func(name, version, registryName string) BillOfMaterials {
+		return BillOfMaterials{
+			Name:    name,
+			Type:    Native,
+			Version: plugin.FromString(version),
+			Source:  PluginSource{Type: "registry", Name: registryName, URL: oldRegistryURL},
+		}
+	}
+
+	installed := map[string]BillOfMaterials{
+		filepath.Join("aws", versionsDirName, "1.0.0"):   registryBOM("aws", "1.0.0", "corp"),
+		filepath.Join("aws", versionsDirName, "2.0.0"):   registryBOM("aws", "2.0.0", "corp"),
+		filepath.Join("gcp", versionsDirName, "0.1.0"):   registryBOM("gcp", "0.1.0", "corp"),
+		filepath.Join("azure", versionsDirName, "1.0.0"): registryBOM("azure", "1.0.0", "other"),
+		filepath.Join("custom", versionsDirName, "0.1.0"): {
+			Name:    "custom",
+			Type:    Native,
+			Version: plugin.FromString("0.1.0"),
+			Source:  PluginSource{Type: "local-file", Name: "/home/alice/bin/custom"},
+		},
+		"legacy": registryBOM("legacy", "0.9.0", "corp"),
+	}
+
+	paths := map[string]string{}
+	for dir, bom := range installed {
+		dir = filepath.Join(home, dir)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := WriteBOM(dir, bom); err != nil {
+			t.Fatal(err)
+		}
+		paths[dir] = filepath.Join(dir, bomFileName)
+	}
+
+	// hidden directories, like the registry cache, aren't plugins
+	hidden := filepath.Join(home, ".cache", versionsDirName, "1.0.0")
+	if err := os.MkdirAll(hidden, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(hidden, bomFileName), []byte("not toml ["), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return paths
+}
+
+func readAll(t *testing.T, paths map[string]string) map[string]string {
+	t.Helper()
+
+	contents := map[string]string{}
+	for dir, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[dir] = string(b)
+	}
+
+	return contents
+}
+
+func TestMigrateBOMSources(t *testing.T) {
+	moveRegistry := func(name string) func(*PluginSource) bool {
+		return func(source *PluginSource) bool {
+			if source.Type != "registry" || source.Name != name || source.URL != oldRegistryURL {
+				return false
+			}
+
+			source.URL = newRegistryURL
+			return true
+		}
+	}
+
+	tests := []struct {
+		name    string
+		migrate func(*PluginSource) bool
+		// corrupt is a plugin version whose bill of materials can't be parsed
+		corrupt  string
+		migrated []string
+		err      bool
+	}{
+		{
+			name:     "every version of the registry's plugins",
+			migrate:  moveRegistry("corp"),
+			migrated: []string{"aws@1.0.0", "aws@2.0.0", "gcp@0.1.0", "legacy"},
+		},
+		{
+			name:     "another registry",
+			migrate:  moveRegistry("other"),
+			migrated: []string{"azure@1.0.0"},
+		},
+		{
+			name:     "nothing to migrate",
+			migrate:  moveRegistry("missing"),
+			migrated: []string{},
+		},
+		{
+			name:    "unparsable bill of materials",
+			migrate: moveRegistry("corp"),
+			corrupt: filepath.Join("gcp", versionsDirName, "0.1.0"),
+			err:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv(PLUGIN_HOME, home)
+
+			paths := installBOMs(t, home)
+			if tt.corrupt != "" {
+				if err := os.WriteFile(filepath.Join(home, tt.corrupt, bomFileName), []byte("not toml ["), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before := readAll(t, paths)
+
+			m, err := MigrateBOMSources(tt.migrate)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+
+				// every bill of materials is read before any is written
+				if after := readAll(t, paths); !reflect.DeepEqual(after, before) {
+					t.Error("bills of material changed despite the error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(m.Migrated, tt.migrated) {
+				t.Errorf("Migrated = %q, want %q", m.Migrated, tt.migrated)
+			}
+
+			migrated := 0
+			for dir := range paths {
+				bom, err := ReadBOM(dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if bom.Source.URL == newRegistryURL {
+					migrated++
+				}
+			}
+
+			if migrated != len(tt.migrated) {
+				t.Errorf("%d bills of material were rewritten, want %d", migrated, len(tt.migrated))
+			}
+
+			if err = m.Rollback(); err != nil {
+				t.Fatalf("Rollback() = %v", err)
+			}
+
+			if after := readAll(t, paths); !reflect.DeepEqual(after, before) {
+				t.Error("Rollback() didn't restore every bill of materials")
+			}
+		})
+	}
+}
+
+func TestMigrateBOMSourcesNothingInstalled(t *testing.T) {
+	t.Setenv(PLUGIN_HOME, filepath.Join(t.TempDir(), "missing"))
+
+	m, err := MigrateBOMSources(func(*PluginSource) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Migrated) != 0 {
+		t.Errorf("Migrated = %q, want none", m.Migrated)
+	}
+
+	if err = m.Rollback(); err != nil {
+		t.Errorf("Rollback() = %v", err)
+	}
+}
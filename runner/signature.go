@@ -0,0 +1,245 @@
+package runner
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// SignatureMinisign is a detached minisign signature made with an ed25519 key
+	SignatureMinisign = "minisign"
+	// SignatureCosign is a base64 encoded signature as made by "cosign sign-blob"
+	// with a PEM encoded ECDSA, ed25519 or RSA key
+	SignatureCosign = "cosign"
+)
+
+var (
+	ErrSignatureInvalid   = errors.New("signature verification failed")
+	ErrNoTrustedKeys      = errors.New("no trusted keys")
+	ErrSignatureRequired  = errors.New("a verified signature is required")
+	ErrUnknownSigFormat   = errors.New("unknown signature format")
+	ErrUnsupportedKeyType = errors.New("unsupported public key type")
+)
+
+// TrustedKey is a public key trusted to sign the plugins of a registry. Name
+// identifies the signer, Key is a minisign public key or a PEM encoded public
+// key
+type TrustedKey struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+// PluginSigner records who signed an installed plugin
+type PluginSigner struct {
+	Identity string `toml:"identity"`
+	KeyID    string `toml:"key-id"`
+	Format   string `toml:"format"`
+}
+
+func (s *PluginSigner) String() string {
+	if s == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%s (%s)", s.Identity, s.KeyID)
+}
+
+type publicKey struct {
+	format string
+	id     string
+	// minisign keys carry an id that signatures refer to
+	minisignID []byte
+	key        crypto.PublicKey
+}
+
+// ParsePublicKey parses a minisign public key, either the key line on its own
+// or a whole minisign .pub file, or a PEM encoded PKIX public key
+func ParsePublicKey(contents string) (format string, id string, err error) {
+	pk, err := parsePublicKey(contents)
+	if err != nil {
+		return "", "", err
+	}
+
+	return pk.format, pk.id, nil
+}
+
+func parsePublicKey(contents string) (publicKey, error) {
+	if block, _ := pem.Decode([]byte(contents)); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return publicKey{}, err
+		}
+
+		switch key.(type) {
+		case *ecdsa.PublicKey, ed25519.PublicKey, *rsa.PublicKey:
+		default:
+			return publicKey{}, fmt.Errorf("%w %T", ErrUnsupportedKeyType, key)
+		}
+
+		fingerprint := sha256.Sum256(block.Bytes)
+		return publicKey{
+			format: SignatureCosign,
+			id:     fmt.Sprintf("sha256:%x", fingerprint[:8]),
+			key:    key,
+		}, nil
+	}
+
+	line := lastLine(contents)
+	raw, err := base64.StdEncoding.DecodeString(line)
+	if err != nil {
+		return publicKey{}, fmt.Errorf("public key is neither PEM nor a minisign key: %w", err)
+	}
+
+	if len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return publicKey{}, errors.New("public key is neither PEM nor a minisign key")
+	}
+
+	return publicKey{
+		format:     SignatureMinisign,
+		id:         fmt.Sprintf("%X", reverse(raw[2:10])),
+		minisignID: raw[2:10],
+		key:        ed25519.PublicKey(raw[10:]),
+	}, nil
+}
+
+// VerifySignature checks a detached signature of artifact against the trusted
+// keys, and returns the signer whose key made it
+func VerifySignature(format string, artifact, signature []byte, keys []TrustedKey) (*PluginSigner, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoTrustedKeys
+	}
+
+	var verify func(publicKey) (bool, error)
+	switch strings.ToLower(format) {
+	case SignatureMinisign, "":
+		format = SignatureMinisign
+		verify = func(pk publicKey) (bool, error) {
+			return verifyMinisign(pk, artifact, signature)
+		}
+	case SignatureCosign:
+		sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+		if err != nil {
+			// cosign can also write the raw signature bytes
+			sig = signature
+		}
+
+		verify = func(pk publicKey) (bool, error) {
+			return verifyPKIX(pk, artifact, sig), nil
+		}
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownSigFormat, format)
+	}
+
+	for _, tk := range keys {
+		pk, err := parsePublicKey(tk.Key)
+		if err != nil {
+			return nil, fmt.Errorf("trusted key %q: %w", tk.Name, err)
+		}
+
+		if pk.format != format {
+			continue
+		}
+
+		ok, err := verify(pk)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return &PluginSigner{Identity: tk.Name, KeyID: pk.id, Format: format}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: the %s signature was not made by any trusted key", ErrSignatureInvalid, format)
+}
+
+// verifyMinisign verifies both the signature of the artifact and the global
+// signature covering the trusted comment
+func verifyMinisign(pk publicKey, artifact, signature []byte) (bool, error) {
+	lines := strings.Split(strings.ReplaceAll(string(signature), "\r\n", "\n"), "\n")
+	if len(lines) < 4 {
+		return false, fmt.Errorf("%w: malformed minisign signature", ErrSignatureInvalid)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return false, fmt.Errorf("%w: malformed minisign signature", ErrSignatureInvalid)
+	}
+
+	if !bytes.Equal(sig[2:10], pk.minisignID) {
+		return false, nil
+	}
+
+	message := artifact
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		// signatures made by minisign 0.10 and newer are over a blake2b hash
+		sum := blake2b.Sum512(artifact)
+		message = sum[:]
+	default:
+		return false, fmt.Errorf("%w: unknown minisign algorithm %q", ErrSignatureInvalid, sig[:2])
+	}
+
+	key := pk.key.(ed25519.PublicKey)
+	if !ed25519.Verify(key, message, sig[10:]) {
+		return false, nil
+	}
+
+	if !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return false, fmt.Errorf("%w: minisign signature has no trusted comment", ErrSignatureInvalid)
+	}
+	trustedComment := strings.TrimPrefix(lines[2], "trusted comment: ")
+
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil {
+		return false, fmt.Errorf("%w: malformed minisign signature", ErrSignatureInvalid)
+	}
+
+	signed := make([]byte, 0, ed25519.SignatureSize+len(trustedComment))
+	signed = append(append(signed, sig[10:]...), trustedComment...)
+	return ed25519.Verify(key, signed, globalSig), nil
+}
+
+func verifyPKIX(pk publicKey, artifact, sig []byte) bool {
+	digest := sha256.Sum256(artifact)
+
+	switch key := pk.key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, artifact, sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	}
+
+	return false
+}
+
+func lastLine(contents string) string {
+	lines := strings.Split(strings.TrimSpace(contents), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// reverse returns b in reverse order. minisign shows its little endian key ids
+// as a hex number
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+
+	return r
+}
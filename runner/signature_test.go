@@ -0,0 +1,166 @@
+package runner
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisignKey is a throwaway minisign key pair
+type minisignKey struct {
+	id      []byte
+	private ed25519.PrivateKey
+	public  publicKey
+}
+
+func newMinisignKey(t *testing.T, id string) minisignKey {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := append(append([]byte("Ed"), id...), pub...)
+	pk, err := parsePublicKey("untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(raw) + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return minisignKey{id: []byte(id), private: priv, public: pk}
+}
+
+// sign makes a minisign signature of artifact the way minisign does, with
+// algorithm "Ed" for the legacy format and "ED" for a prehashed one
+func (k minisignKey) sign(algorithm string, artifact []byte, comment string) []byte {
+	message := artifact
+	if algorithm == "ED" {
+		sum := blake2b.Sum512(artifact)
+		message = sum[:]
+	}
+
+	sig := ed25519.Sign(k.private, message)
+	global := ed25519.Sign(k.private, append(append([]byte{}, sig...), comment...))
+	line := append(append([]byte(algorithm), k.id...), sig...)
+
+	return []byte(fmt.Sprintf("untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(line), comment, base64.StdEncoding.EncodeToString(global)))
+}
+
+func TestVerifyMinisign(t *testing.T) {
+	key := newMinisignKey(t, "12345678")
+	other := newMinisignKey(t, "87654321")
+	artifact := []byte("#!/bin/sh\necho plugin\n")
+
+	tests := []struct {
+		name      string
+		signature []byte
+		artifact  []byte
+		valid     bool
+		invalid   bool
+	}{
+		{
+			name:      "legacy signature",
+			signature: key.sign("Ed", artifact, "timestamp:1700000000\tfile:plugin"),
+			artifact:  artifact,
+			valid:     true,
+		},
+		{
+			name:      "prehashed signature",
+			signature: key.sign("ED", artifact, "timestamp:1700000000\tfile:plugin"),
+			artifact:  artifact,
+			valid:     true,
+		},
+		{
+			name:      "windows line endings",
+			signature: []byte(strings.ReplaceAll(string(key.sign("ED", artifact, "comment")), "\n", "\r\n")),
+			artifact:  artifact,
+			valid:     true,
+		},
+		{
+			name:      "tampered artifact",
+			signature: key.sign("ED", artifact, "comment"),
+			artifact:  []byte("#!/bin/sh\necho something else\n"),
+		},
+		{
+			name: "tampered trusted comment",
+			signature: []byte(strings.Replace(string(key.sign("ED", artifact, "file:plugin")),
+				"trusted comment: file:plugin", "trusted comment: file:other", 1)),
+			artifact: artifact,
+		},
+		{
+			name:      "signed by another key",
+			signature: other.sign("ED", artifact, "comment"),
+			artifact:  artifact,
+		},
+		{
+			name:      "too short",
+			signature: []byte("untrusted comment: nothing\n"),
+			artifact:  artifact,
+			invalid:   true,
+		},
+		{
+			name:      "not base64",
+			signature: []byte("untrusted comment: x\n!!!\ntrusted comment: x\n!!!\n"),
+			artifact:  artifact,
+			invalid:   true,
+		},
+		{
+			name:      "unknown algorithm",
+			signature: key.sign("Xx", artifact, "comment"),
+			artifact:  artifact,
+			invalid:   true,
+		},
+		{
+			name: "no trusted comment",
+			signature: []byte(strings.Replace(string(key.sign("ED", artifact, "comment")),
+				"\ntrusted comment: ", "\ncomment: ", 1)),
+			artifact: artifact,
+			invalid:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, err := verifyMinisign(key.public, tt.artifact, tt.signature)
+			if tt.invalid {
+				if !errors.Is(err, ErrSignatureInvalid) {
+					t.Errorf("err = %v, want %v", err, ErrSignatureInvalid)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if valid != tt.valid {
+				t.Errorf("valid = %v, want %v", valid, tt.valid)
+			}
+		})
+	}
+}
+
+func TestParseMinisignPublicKey(t *testing.T) {
+	key := newMinisignKey(t, "\x01\x02\x03\x04\x05\x06\x07\x08")
+
+	if key.public.format != SignatureMinisign {
+		t.Errorf("format = %q, want %q", key.public.format, SignatureMinisign)
+	}
+
+	// minisign shows key ids little endian
+	if key.public.id != "0807060504030201" {
+		t.Errorf("id = %q, want %q", key.public.id, "0807060504030201")
+	}
+
+	for _, contents := range []string{"", "not a key", base64.StdEncoding.EncodeToString([]byte("Ed12345678short"))} {
+		if _, err := parsePublicKey(contents); err == nil {
+			t.Errorf("expected an error parsing %q", contents)
+		}
+	}
+}
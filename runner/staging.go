@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	stagingPrefix       = ".install-"
+	replacedPrefix      = ".replaced-"
+	previousVersionFile = "previous-version"
+	staleStagingAge     = 24 * time.Hour
+)
+
+var ErrNoPreviousVersion = errors.New("no previous version to roll back to")
+
+// StageInstall creates an empty directory next to a plugin's installed versions
+// that an installer can write into. Because it lives on the same filesystem as
+// the final location, CommitStagedVersion can move it into place with a rename.
+// Callers should always RemoveAll the staging directory when they're done, it
+// is a no-op once the directory has been committed
+func StageInstall(pluginName string) (string, error) {
+	root, err := PluginDir(pluginName, true)
+	if err != nil {
+		return "", err
+	}
+
+	removeStaleStagingDirs(root)
+
+	return os.MkdirTemp(root, stagingPrefix)
+}
+
+// DiscardStagedInstall removes a staging directory, and the plugin's directory
+// too if nothing else was ever installed in it
+func DiscardStagedInstall(stagingDir string) {
+	os.RemoveAll(stagingDir)
+	os.Remove(filepath.Join(filepath.Dir(stagingDir), versionsDirName))
+	os.Remove(filepath.Dir(stagingDir))
+}
+
+// CommitStagedVersion atomically moves a staged install into place as version
+// of pluginName and makes it the active version. If that version is already
+// installed, it is replaced, and restored if the replacement fails
+func CommitStagedVersion(pluginName, version, stagingDir string) error {
+	if _, err := os.Stat(filepath.Join(stagingDir, bomFileName)); err != nil {
+		return fmt.Errorf("refusing to commit incomplete install of %s: %w", PluginRef(pluginName, version), err)
+	}
+
+	versionDir, err := PluginVersionDir(pluginName, version, false)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(versionDir), 0o777); err != nil {
+		return err
+	}
+
+	backup := ""
+	if _, err = os.Stat(versionDir); err == nil {
+		backup = filepath.Join(filepath.Dir(stagingDir), fmt.Sprintf("%s%s-%d", replacedPrefix, filepath.Base(versionDir), time.Now().UnixNano()))
+		if err = os.Rename(versionDir, backup); err != nil {
+			return fmt.Errorf("could not move existing install of %s aside: %w", PluginRef(pluginName, version), err)
+		}
+	}
+
+	if err = os.Rename(stagingDir, versionDir); err != nil {
+		if backup != "" {
+			os.Rename(backup, versionDir)
+		}
+		return err
+	}
+
+	if err = SetActiveVersion(pluginName, version); err != nil {
+		os.RemoveAll(versionDir)
+		if backup != "" {
+			os.Rename(backup, versionDir)
+		}
+		return err
+	}
+
+	if backup != "" {
+		os.RemoveAll(backup)
+	}
+
+	return nil
+}
+
+// PreviousVersion returns the version that was active before the current one
+func PreviousVersion(pluginName string) (string, error) {
+	root, err := PluginDir(pluginName, false)
+	if err != nil {
+		return "", err
+	}
+
+	contents, err := os.ReadFile(filepath.Join(root, previousVersionFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrNoPreviousVersion
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(contents)), nil
+}
+
+func recordPreviousVersion(root, version string) error {
+	return writeFileAtomic(filepath.Join(root, previousVersionFile), []byte(version+"\n"), 0o666)
+}
+
+func removeStaleStagingDirs(root string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), stagingPrefix) && !strings.HasPrefix(e.Name(), replacedPrefix) {
+			continue
+		}
+
+		if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > staleStagingAge {
+			os.RemoveAll(filepath.Join(root, e.Name()))
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package runner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stage creates a staged install of pluginName holding a marker file with
+// contents, and a bill of materials unless incomplete is set
+func stage(t *testing.T, pluginName, contents string, incomplete bool) string {
+	t.Helper()
+
+	dir, err := StageInstall(pluginName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "marker"), []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !incomplete {
+		if err = os.WriteFile(filepath.Join(dir, bomFileName), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestCommitStagedVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		// installed are committed before the one being tested, in order
+		installed  []string
+		version    string
+		incomplete bool
+		err        error
+		active     string
+		previous   string
+	}{
+		{
+			name:    "first version",
+			version: "1.0.0",
+			active:  "1.0.0",
+		},
+		{
+			name:      "second version",
+			installed: []string{"1.0.0"},
+			version:   "2.0.0",
+			active:    "2.0.0",
+			previous:  "1.0.0",
+		},
+		{
+			name:      "same version again",
+			installed: []string{"1.0.0", "2.0.0"},
+			version:   "2.0.0",
+			active:    "2.0.0",
+			previous:  "1.0.0",
+		},
+		{
+			name:       "incomplete install",
+			installed:  []string{"1.0.0"},
+			version:    "2.0.0",
+			incomplete: true,
+			err:        os.ErrNotExist,
+			active:     "1.0.0",
+		},
+		{
+			name:      "invalid version",
+			installed: []string{"1.0.0"},
+			version:   "../1.0.0",
+			err:       ErrInvalidVersion,
+			active:    "1.0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(PLUGIN_HOME, t.TempDir())
+
+			for _, v := range tt.installed {
+				if err := CommitStagedVersion("aws", v, stage(t, "aws", "old "+v, false)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			stagingDir := stage(t, "aws", "new "+tt.version, tt.incomplete)
+			err := CommitStagedVersion("aws", tt.version, stagingDir)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+
+				// the staged install is left for the caller to discard
+				if _, err = os.Stat(stagingDir); err != nil {
+					t.Errorf("staging directory is gone: %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				dir, err := PluginVersionDir("aws", tt.version, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if contents, err := os.ReadFile(filepath.Join(dir, "marker")); err != nil || string(contents) != "new "+tt.version {
+					t.Errorf("installed marker = %q, %v, want %q", contents, err, "new "+tt.version)
+				}
+
+				if _, err = os.Stat(stagingDir); !os.IsNotExist(err) {
+					t.Errorf("staging directory still exists: %v", err)
+				}
+			}
+
+			if active, err := ActiveVersion("aws"); err != nil || active != tt.active {
+				t.Errorf("ActiveVersion() = %q, %v, want %q", active, err, tt.active)
+			}
+
+			previous, err := PreviousVersion("aws")
+			if tt.previous == "" && !errors.Is(err, ErrNoPreviousVersion) {
+				t.Errorf("PreviousVersion() = %q, %v, want %v", previous, err, ErrNoPreviousVersion)
+			}
+
+			if tt.previous != "" && (err != nil || previous != tt.previous) {
+				t.Errorf("PreviousVersion() = %q, %v, want %q", previous, err, tt.previous)
+			}
+
+			// replaced versions are cleaned up
+			root, err := PluginDir("aws", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries, err := os.ReadDir(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, e := range entries {
+				if strings.HasPrefix(e.Name(), replacedPrefix) {
+					t.Errorf("%s was left behind", e.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestDiscardStagedInstall(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed bool
+	}{
+		{name: "nothing installed"},
+		{name: "other versions installed", installed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(PLUGIN_HOME, t.TempDir())
+
+			if tt.installed {
+				if err := CommitStagedVersion("aws", "1.0.0", stage(t, "aws", "", false)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			stagingDir := stage(t, "aws", "", false)
+			DiscardStagedInstall(stagingDir)
+
+			if _, err := os.Stat(stagingDir); !os.IsNotExist(err) {
+				t.Errorf("staging directory still exists: %v", err)
+			}
+
+			_, err := os.Stat(filepath.Dir(stagingDir))
+			if tt.installed && err != nil {
+				t.Errorf("plugin directory was removed: %v", err)
+			}
+
+			if !tt.installed && !os.IsNotExist(err) {
+				t.Errorf("empty plugin directory was left behind: %v", err)
+			}
+		})
+	}
+}
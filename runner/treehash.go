@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// IntegrityFile means the checksum covers the plugin executable only
+	IntegrityFile = "file"
+	// IntegrityTree means the checksum is a TreeHash of the plugin directory
+	IntegrityTree = "tree"
+)
+
+// TreeHash computes a deterministic Merkle hash of a directory. Every file is
+// hashed with its executable bit, every symlink by its target, and every
+// directory by the sorted names, kinds and hashes of its children, so any
+// added, removed, renamed or modified file changes the result
+func TreeHash(dir string, algorithm string) (string, error) {
+	newHash, err := hashConstructor(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	sum, err := treeHash(newHash, dir, true)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(sum), nil
+}
+
+// treeHash hashes dir. The bill of materials is written after installation, so
+// it's left out at the top of the tree, but a file of the same name anywhere
+// else is part of the plugin
+func treeHash(newHash func() hash.Hash, dir string, top bool) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	h := newHash()
+	io.WriteString(h, "tree\x00")
+	for _, entry := range entries {
+		if top && entry.Name() == bomFileName {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		kind, sum, err := entryHash(newHash, path, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(h, "%s\x00%s\x00%x\n", kind, entry.Name(), sum)
+	}
+
+	return h.Sum(nil), nil
+}
+
+func entryHash(newHash func() hash.Hash, path string, entry os.DirEntry) (string, []byte, error) {
+	switch {
+	case entry.Type()&os.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", nil, err
+		}
+
+		h := newHash()
+		io.WriteString(h, "link\x00"+filepath.ToSlash(target))
+		return "link", h.Sum(nil), nil
+	case entry.IsDir():
+		sum, err := treeHash(newHash, path, false)
+		return "tree", sum, err
+	case entry.Type().IsRegular():
+		info, err := entry.Info()
+		if err != nil {
+			return "", nil, err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return "", nil, err
+		}
+		defer file.Close()
+
+		h := newHash()
+		mode := "blob"
+		if info.Mode().Perm()&0o111 != 0 {
+			mode = "exec"
+		}
+		io.WriteString(h, mode+"\x00")
+		if _, err = io.Copy(h, file); err != nil {
+			return "", nil, err
+		}
+
+		return mode, h.Sum(nil), nil
+	default:
+		return "", nil, fmt.Errorf("%s is not a regular file, directory or symlink", path)
+	}
+}
@@ -0,0 +1,194 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeTree creates a small plugin directory for the tree hash tests
+func writeTree(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"export-plugin":                          "#!/bin/sh\n",
+		"node_modules/a/package.json":            `{"name":"a","version":"1.0.0"}`,
+		"node_modules/a/index.js":                "module.exports = {}\n",
+		"node_modules/@scope/b/package.json":     `{"name":"@scope/b","version":"2.0.0"}`,
+		"lib/__pycache__/plugin.cpython-311.pyc": "\x00\x01\x02",
+	}
+
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Chmod(filepath.Join(dir, "export-plugin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestTreeHash(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(t *testing.T, dir string) error
+		changed bool
+		symlink bool
+	}{
+		{
+			name:   "unchanged",
+			modify: func(*testing.T, string) error { return nil },
+		},
+		{
+			name: "modified file",
+			modify: func(_ *testing.T, dir string) error {
+				return os.WriteFile(filepath.Join(dir, "node_modules", "a", "index.js"), []byte("module.exports = 1\n"), 0o644)
+			},
+			changed: true,
+		},
+		{
+			name: "added file",
+			modify: func(_ *testing.T, dir string) error {
+				return os.WriteFile(filepath.Join(dir, "node_modules", "a", "extra.js"), nil, 0o644)
+			},
+			changed: true,
+		},
+		{
+			name: "removed file",
+			modify: func(_ *testing.T, dir string) error {
+				return os.Remove(filepath.Join(dir, "node_modules", "a", "index.js"))
+			},
+			changed: true,
+		},
+		{
+			name: "renamed file",
+			modify: func(_ *testing.T, dir string) error {
+				return os.Rename(filepath.Join(dir, "node_modules", "a", "index.js"), filepath.Join(dir, "node_modules", "a", "main.js"))
+			},
+			changed: true,
+		},
+		{
+			name: "added empty directory",
+			modify: func(_ *testing.T, dir string) error {
+				return os.Mkdir(filepath.Join(dir, "node_modules", "c"), 0o755)
+			},
+			changed: true,
+		},
+		{
+			name: "executable bit",
+			modify: func(_ *testing.T, dir string) error {
+				return os.Chmod(filepath.Join(dir, "node_modules", "a", "index.js"), 0o755)
+			},
+			changed: runtime.GOOS != "windows",
+		},
+		{
+			name: "symlink",
+			modify: func(_ *testing.T, dir string) error {
+				return os.Symlink(filepath.Join("node_modules", "a", "index.js"), filepath.Join(dir, "link"))
+			},
+			changed: true,
+			symlink: true,
+		},
+		{
+			name: "bill of materials is ignored",
+			modify: func(_ *testing.T, dir string) error {
+				return os.WriteFile(filepath.Join(dir, bomFileName), []byte("name = \"a\"\n"), 0o644)
+			},
+		},
+		{
+			name: "nested bill of materials",
+			modify: func(_ *testing.T, dir string) error {
+				return os.WriteFile(filepath.Join(dir, "node_modules", "a", bomFileName), []byte("name = \"a\"\n"), 0o644)
+			},
+			changed: true,
+		},
+		{
+			name: "added python bytecode",
+			modify: func(_ *testing.T, dir string) error {
+				cache := filepath.Join(dir, "node_modules", "a", "__pycache__")
+				if err := os.Mkdir(cache, 0o755); err != nil {
+					return err
+				}
+				return os.WriteFile(filepath.Join(cache, "index.cpython-311.pyc"), []byte{0, 1, 2}, 0o644)
+			},
+			changed: true,
+		},
+		{
+			name: "modified python bytecode",
+			modify: func(_ *testing.T, dir string) error {
+				return os.WriteFile(filepath.Join(dir, "lib", "__pycache__", "plugin.cpython-311.pyc"), []byte{3, 4, 5}, 0o644)
+			},
+			changed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.symlink && runtime.GOOS == "windows" {
+				t.Skip("symlinks need extra privileges on windows")
+			}
+
+			dir := writeTree(t)
+			before, err := TreeHash(dir, SHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = tt.modify(t, dir); err != nil {
+				t.Fatal(err)
+			}
+
+			after, err := TreeHash(dir, SHA256)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if changed := before != after; changed != tt.changed {
+				t.Errorf("hash changed = %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+}
+
+func TestTreeHashAlgorithms(t *testing.T) {
+	dir := writeTree(t)
+
+	sums := map[string]string{}
+	for _, alg := range HashAlgorithms {
+		sum, err := TreeHash(dir, alg)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+
+		// the same tree hashes the same way, wherever it is
+		again, err := TreeHash(dir, alg)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+
+		if sum != again {
+			t.Errorf("%s: hash is not deterministic: %s != %s", alg, sum, again)
+		}
+
+		for other, otherSum := range sums {
+			if otherSum == sum {
+				t.Errorf("%s and %s hashes are the same", alg, other)
+			}
+		}
+		sums[alg] = sum
+	}
+
+	if _, err := TreeHash(dir, "md5"); err == nil {
+		t.Error("expected an error for an unknown algorithm")
+	}
+}
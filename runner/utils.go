@@ -1,21 +1,124 @@
 package runner
 
 import (
-	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
-	"strconv"
+	"path/filepath"
+	"runtime"
+	"strings"
 )
 
-func GetHTTPClient() *http.Client {
-	skipVerify, _ := strconv.ParseBool(os.Getenv("TF_EXPORTER_INSTALL_SKIP_TLS_VERIFY"))
+var ErrUnauthorized = errors.New("not authorized")
 
-	hc := http.DefaultClient
-	hc.Transport = &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: skipVerify,
-		},
+// RedactURL replaces the password in rawURL, for showing URLs that were
+// recorded with credentials in them. Anything that isn't a URL is returned as
+// is
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
 	}
 
-	return hc
+	return u.Redacted()
+}
+
+// Open opens a registry file or plugin artifact. http and https URLs are
+// downloaded, file URLs are read from disk. A missing file is reported as
+// os.ErrNotExist for either
+func Open(u *url.URL) (io.ReadCloser, error) {
+	return OpenWith(u, nil, nil)
+}
+
+// OpenWith is Open with the client to send http requests with, nil for the
+// default client, and a function that authorizes them, for example by adding
+// credentials
+func OpenWith(u *url.URL, client *http.Client, authorize func(*http.Request) error) (io.ReadCloser, error) {
+	switch u.Scheme {
+	case "file":
+		path, err := FilePath(u)
+		if err != nil {
+			return nil, err
+		}
+
+		return os.Open(path)
+	case "http", "https":
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if authorize != nil {
+			if err = authorize(req); err != nil {
+				return nil, err
+			}
+		}
+
+		if client == nil {
+			if client, err = GetHTTPClient(); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%w: %s returned '%s'", ErrUnauthorized, u, resp.Status)
+		}
+
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s: %w", u, os.ErrNotExist)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("expected status '200 OK' from %s, got '%s'", u, resp.Status)
+		}
+
+		return resp.Body, nil
+	default:
+		return nil, fmt.Errorf("unsupported URL scheme %q in %s", u.Scheme, u)
+	}
+}
+
+// FilePath returns the local path of a file URL
+func FilePath(u *url.URL) (string, error) {
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("%s is not a file URL", u)
+	}
+
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("file URL %s names host %q, mount it and use a local path instead", u, u.Host)
+	}
+
+	path := filepath.FromSlash(u.Path)
+	// file:///C:/registry has the path /C:/registry
+	if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '\\' && path[2] == ':' {
+		path = path[1:]
+	}
+
+	return path, nil
+}
+
+// FileURL returns the file URL of a local path
+func FileURL(path string) (*url.URL, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		abs = "/" + abs
+	}
+
+	return &url.URL{Scheme: "file", Path: abs}, nil
 }
@@ -0,0 +1,227 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	plugin "github.com/gideaworx/terraform-exporter-plugin-go"
+)
+
+const (
+	versionsDirName   = "versions"
+	activeVersionFile = "active-version"
+	bomFileName       = "export-plugin.bom"
+	executableName    = "export-plugin"
+)
+
+var (
+	ErrPluginVersionNotFound = errors.New("plugin version not found")
+	ErrInvalidVersion        = errors.New("not a semantic version")
+)
+
+// ParsePluginRef splits a plugin reference of the form name[@version]. The
+// version, if present, must be a semantic version and is normalized so that
+// "v1.2" and "1.2.0" are the same
+func ParsePluginRef(ref string) (string, string, error) {
+	name, version, found := strings.Cut(ref, "@")
+	if !found {
+		return ref, "", nil
+	}
+
+	version, err := checkVersion(version)
+	if err != nil {
+		return "", "", err
+	}
+
+	return name, version, nil
+}
+
+func PluginRef(name, version string) string {
+	if version == "" {
+		return name
+	}
+
+	return fmt.Sprintf("%s@%s", name, NormalizeVersion(version))
+}
+
+// NormalizeVersion returns the canonical semver string for version, or version
+// itself if it can't be parsed
+func NormalizeVersion(version string) string {
+	version = strings.TrimSpace(version)
+	if _, err := semver.ParseTolerant(version); err != nil {
+		return version
+	}
+
+	return plugin.FromString(version).String()
+}
+
+// checkVersion normalizes version, and returns an error if it isn't a semantic
+// version. Versions name directories, so nothing else may be used as one
+func checkVersion(version string) (string, error) {
+	if _, err := semver.ParseTolerant(strings.TrimSpace(version)); err != nil {
+		return "", fmt.Errorf("%q is %w", version, ErrInvalidVersion)
+	}
+
+	return NormalizeVersion(version), nil
+}
+
+// PluginVersionDir returns the directory a specific version of a plugin is
+// (or will be) installed in
+func PluginVersionDir(pluginName, version string, create bool) (string, error) {
+	version, err := checkVersion(version)
+	if err != nil {
+		return "", err
+	}
+
+	root, err := PluginDir(pluginName, create)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(root, versionsDirName, version)
+	if create {
+		if err = os.MkdirAll(dir, 0o777); err != nil {
+			return "", err
+		}
+	}
+
+	return dir, nil
+}
+
+// ActiveVersion returns the version of a plugin that is used when no version
+// is requested explicitly. Plugins installed before versioned storage existed
+// have no active version and return the empty string
+func ActiveVersion(pluginName string) (string, error) {
+	root, err := PluginDir(pluginName, false)
+	if err != nil {
+		return "", err
+	}
+
+	contents, err := os.ReadFile(filepath.Join(root, activeVersionFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(contents)), nil
+}
+
+// SetActiveVersion points a plugin at one of its installed versions. The
+// version it replaces is remembered so it can be rolled back to
+func SetActiveVersion(pluginName, version string) error {
+	dir, err := PluginVersionDir(pluginName, version, false)
+	if err != nil {
+		return err
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, bomFileName)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrPluginVersionNotFound, PluginRef(pluginName, version))
+		}
+		return err
+	}
+
+	root := filepath.Dir(filepath.Dir(dir))
+	current, err := ActiveVersion(pluginName)
+	if err != nil {
+		return err
+	}
+
+	if current != "" && current != NormalizeVersion(version) {
+		if err = recordPreviousVersion(root, current); err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(filepath.Join(root, activeVersionFile), []byte(NormalizeVersion(version)+"\n"), 0o666)
+}
+
+// InstalledVersions returns every installed version of a plugin, newest first
+func InstalledVersions(pluginName string) ([]string, error) {
+	root, err := PluginDir(pluginName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(filepath.Join(root, versionsDirName, "*", bomFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]string, 0, len(matches))
+	for _, m := range matches {
+		versions = append(versions, filepath.Base(filepath.Dir(m)))
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		vi, _ := semver.ParseTolerant(versions[i])
+		vj, _ := semver.ParseTolerant(versions[j])
+		return vi.GT(vj)
+	})
+
+	return versions, nil
+}
+
+// ResolvePluginDir returns the directory holding the executable and bill of
+// materials for a plugin reference. Without a version, the active version is
+// used, falling back to the unversioned layout of older installs
+func ResolvePluginDir(ref string) (string, error) {
+	pluginName, version, err := ParsePluginRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	if version == "" {
+		if version, err = ActiveVersion(pluginName); err != nil {
+			return "", err
+		}
+	}
+
+	if version == "" {
+		return PluginDir(pluginName, false)
+	}
+
+	dir, err := PluginVersionDir(pluginName, version, false)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, bomFileName)); err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("%w: %s", ErrPluginVersionNotFound, PluginRef(pluginName, version))
+		}
+		return "", err
+	}
+
+	return dir, nil
+}
+
+func writeFileAtomic(path string, contents []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,142 @@
+package runner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParsePluginRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		name    string
+		version string
+		invalid bool
+	}{
+		{ref: "aws", name: "aws"},
+		{ref: "aws@1.2.3", name: "aws", version: "1.2.3"},
+		{ref: "aws@v1.2.3", name: "aws", version: "1.2.3"},
+		{ref: "aws@1.2", name: "aws", version: "1.2.0"},
+		{ref: "aws@1.0.0-rc.1", name: "aws", version: "1.0.0-rc.1"},
+		{ref: "aws@1.0.0+build.5", name: "aws", version: "1.0.0+build.5"},
+		{ref: "aws@", invalid: true},
+		{ref: "aws@latest", invalid: true},
+		{ref: "aws@../../bar", invalid: true},
+		{ref: `aws@..\..\bar`, invalid: true},
+		{ref: "aws@1.0.0/../../bar", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			name, version, err := ParsePluginRef(tt.ref)
+			if tt.invalid {
+				if !errors.Is(err, ErrInvalidVersion) {
+					t.Errorf("err = %v, want %v", err, ErrInvalidVersion)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if name != tt.name || version != tt.version {
+				t.Errorf("ParsePluginRef(%q) = %q, %q, want %q, %q", tt.ref, name, version, tt.name, tt.version)
+			}
+		})
+	}
+}
+
+func TestPluginVersionDir(t *testing.T) {
+	tests := []struct {
+		version string
+		dir     string
+		invalid bool
+	}{
+		{version: "1.2.3", dir: filepath.Join("aws", "versions", "1.2.3")},
+		{version: "v1.2", dir: filepath.Join("aws", "versions", "1.2.0")},
+		{version: "../../bar", invalid: true},
+		{version: "..", invalid: true},
+		{version: "", invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv(PLUGIN_HOME, home)
+
+			dir, err := PluginVersionDir("aws", tt.version, true)
+			if tt.invalid {
+				if !errors.Is(err, ErrInvalidVersion) {
+					t.Errorf("err = %v, want %v", err, ErrInvalidVersion)
+				}
+
+				// nothing is created for an invalid version
+				if entries, _ := os.ReadDir(home); len(entries) != 0 {
+					t.Errorf("created %s", entries[0].Name())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if want := filepath.Join(home, tt.dir); dir != want {
+				t.Errorf("dir = %q, want %q", dir, want)
+			}
+
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				t.Errorf("%s was not created: %v", dir, err)
+			}
+		})
+	}
+}
+
+func TestActiveVersion(t *testing.T) {
+	t.Setenv(PLUGIN_HOME, t.TempDir())
+
+	for _, v := range []string{"1.0.0", "1.10.0", "1.2.0", "2.0.0-rc.1"} {
+		dir, err := PluginVersionDir("aws", v, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err = os.WriteFile(filepath.Join(dir, bomFileName), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := InstalledVersions("aws")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"2.0.0-rc.1", "1.10.0", "1.2.0", "1.0.0"}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("InstalledVersions() = %q, want %q", versions, want)
+	}
+
+	steps := []struct {
+		activate string
+		active   string
+		notFound bool
+	}{
+		{activate: "1.0.0", active: "1.0.0"},
+		{activate: "v1.2", active: "1.2.0"},
+		{activate: "3.0.0", active: "1.2.0", notFound: true},
+		{activate: "1.10.0", active: "1.10.0"},
+	}
+
+	for _, step := range steps {
+		err := SetActiveVersion("aws", step.activate)
+		if step.notFound != errors.Is(err, ErrPluginVersionNotFound) || (!step.notFound && err != nil) {
+			t.Fatalf("SetActiveVersion(%q) = %v", step.activate, err)
+		}
+
+		if active, err := ActiveVersion("aws"); err != nil || active != step.active {
+			t.Errorf("after activating %s, ActiveVersion() = %q, %v, want %q", step.activate, active, err, step.active)
+		}
+	}
+}
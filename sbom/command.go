@@ -0,0 +1,211 @@
+package sbom
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+const (
+	formatCycloneDX = "cyclonedx"
+	formatSPDX      = "spdx"
+
+	toolName = "terraform-exporter"
+)
+
+type Command struct {
+	Plugins []string `arg:"" optional:"" help:"The plugins to include, as plugin or plugin@version. Defaults to the active version of every installed plugin"`
+	Format  string   `default:"cyclonedx" enum:"cyclonedx,spdx" help:"The SBOM format, CycloneDX 1.5 or SPDX 2.3 JSON. One of cyclonedx or spdx"`
+	Output  string   `short:"o" type:"path" help:"Where to write the SBOM. Defaults to stdout"`
+}
+
+// Component is an installed plugin, or a package installed with one
+type Component struct {
+	Name    string
+	Version string
+	// Type is the plugin type for plugins, and the package ecosystem, npm or
+	// pypi, for packages
+	Type       string
+	PURL       string
+	Source     string
+	SourceType string
+	Hashes     []Hash
+	// TreeHash is the checksum of the whole plugin directory recorded for
+	// plugins that aren't a single file. No other tool computes it the same
+	// way, so it isn't one of the component's hashes
+	TreeHash *Hash
+	Packages []Component
+}
+
+type Hash struct {
+	Algorithm string
+	Value     string
+}
+
+// document is what every format is written from
+type document struct {
+	serial     string
+	created    time.Time
+	version    string
+	components []Component
+}
+
+func (c *Command) Run(ctx *kong.Context) error {
+	components, err := c.collect()
+	if err != nil {
+		return err
+	}
+
+	serial, err := newUUID()
+	if err != nil {
+		return err
+	}
+
+	doc := document{
+		serial:     serial,
+		created:    time.Now().UTC(),
+		version:    ctx.Model.Vars()["version"],
+		components: components,
+	}
+
+	var out io.Writer = ctx.Stdout
+	if c.Output != "" {
+		file, err := os.Create(c.Output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	var v interface{}
+	switch c.Format {
+	case formatSPDX:
+		v = spdxDocument(doc)
+	default:
+		v = cycloneDXDocument(doc)
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(v); err != nil {
+		return err
+	}
+
+	if c.Output != "" {
+		fmt.Fprintf(ctx.Stderr, "Wrote a %s SBOM of %d plugins to %s\n", c.Format, len(components), c.Output)
+	}
+
+	return nil
+}
+
+// collect turns the active version of every plugin to include into a
+// component, along with the packages installed in its directory
+func (c *Command) collect() ([]Component, error) {
+	names := c.Plugins
+	if len(names) == 0 {
+		boms, err := runner.LoadInstalledBOMs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, bom := range boms {
+			names = append(names, bom.Name)
+		}
+	}
+	sort.Strings(names)
+
+	components := make([]Component, 0, len(names))
+	for _, name := range names {
+		bom, err := runner.LoadPluginBOM(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		dir, err := runner.ResolvePluginDir(name)
+		if err != nil {
+			return nil, err
+		}
+
+		component := pluginComponent(bom)
+
+		switch bom.Type {
+		case runner.NodeJS:
+			component.Packages, err = npmPackages(dir)
+		case runner.Python:
+			component.Packages, err = pythonPackages(dir)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("could not list the packages installed with %s: %w", name, err)
+		}
+
+		components = append(components, component)
+	}
+
+	return components, nil
+}
+
+func pluginComponent(bom runner.BillOfMaterials) Component {
+	version := bom.Version.String()
+	component := Component{
+		Name:       bom.Name,
+		Version:    version,
+		Type:       string(bom.Type),
+		SourceType: bom.Source.Type,
+	}
+
+	// only registries have a URL someone else can download from, a plugin
+	// installed from a local file has no known source. A plugin downloaded
+	// from a mirror came from the mirror's URL
+	if bom.Source.Type == "registry" {
+		component.Source = runner.RedactURL(bom.Source.URL)
+		if bom.Source.Mirror != "" {
+			component.Source = runner.RedactURL(bom.Source.Mirror)
+		}
+	}
+
+	purl := fmt.Sprintf("pkg:generic/%s@%s", purlEscape(bom.Name), purlEscape(version))
+	if component.Source != "" {
+		purl += "?" + url.Values{"repository_url": {component.Source}}.Encode()
+	}
+	component.PURL = purl
+
+	if bom.Integrity != nil {
+		algorithm := bom.Integrity.Algorithm
+		if algorithm == "" {
+			algorithm = runner.DefaultHashAlgorithm
+		}
+
+		hash := Hash{Algorithm: strings.ToLower(algorithm), Value: strings.ToLower(bom.Integrity.Checksum)}
+		if bom.Integrity.Scope == runner.IntegrityTree {
+			component.TreeHash = &hash
+		} else {
+			component.Hashes = []Hash{hash}
+		}
+	}
+
+	return component
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
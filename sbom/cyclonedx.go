@@ -0,0 +1,113 @@
+package sbom
+
+import "fmt"
+
+// cycloneDXHashAlgorithms maps hash algorithms to their CycloneDX names
+var cycloneDXHashAlgorithms = map[string]string{
+	"sha1":    "SHA-1",
+	"sha256":  "SHA-256",
+	"sha384":  "SHA-384",
+	"sha512":  "SHA-512",
+	"blake2b": "BLAKE2b-512",
+}
+
+type cdxBOM struct {
+	BOMFormat    string         `json:"bomFormat"`
+	SpecVersion  string         `json:"specVersion"`
+	SerialNumber string         `json:"serialNumber"`
+	Version      int            `json:"version"`
+	Metadata     cdxMetadata    `json:"metadata"`
+	Components   []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string `json:"timestamp"`
+	Tools     struct {
+		Components []cdxComponent `json:"components"`
+	} `json:"tools"`
+}
+
+type cdxComponent struct {
+	BOMRef             string           `json:"bom-ref,omitempty"`
+	Type               string           `json:"type"`
+	Name               string           `json:"name"`
+	Version            string           `json:"version,omitempty"`
+	PURL               string           `json:"purl,omitempty"`
+	Hashes             []cdxHash        `json:"hashes,omitempty"`
+	ExternalReferences []cdxExternalRef `json:"externalReferences,omitempty"`
+	Properties         []cdxProperty    `json:"properties,omitempty"`
+	Components         []cdxComponent   `json:"components,omitempty"`
+}
+
+type cdxHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+type cdxExternalRef struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// cycloneDXDocument is a CycloneDX 1.5 BOM with a component for each plugin.
+// The packages installed with a plugin are nested in its component
+func cycloneDXDocument(doc document) cdxBOM {
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + doc.serial,
+		Version:      1,
+		Components:   make([]cdxComponent, 0, len(doc.components)),
+	}
+
+	bom.Metadata.Timestamp = doc.created.Format("2006-01-02T15:04:05Z")
+	bom.Metadata.Tools.Components = []cdxComponent{{Type: "application", Name: toolName, Version: doc.version}}
+
+	for _, p := range doc.components {
+		plugin := cdxFromComponent(p, "application", p.PURL)
+		plugin.Properties = append(plugin.Properties, cdxProperty{toolName + ":plugin-type", p.Type})
+		if p.SourceType != "" {
+			plugin.Properties = append(plugin.Properties, cdxProperty{toolName + ":source-type", p.SourceType})
+		}
+
+		if p.TreeHash != nil {
+			plugin.Properties = append(plugin.Properties, cdxProperty{toolName + ":tree-hash", fmt.Sprintf("%s:%s", p.TreeHash.Algorithm, p.TreeHash.Value)})
+		}
+
+		for _, pkg := range p.Packages {
+			// a package installed with more than one plugin is a component of each
+			plugin.Components = append(plugin.Components, cdxFromComponent(pkg, "library", fmt.Sprintf("%s|%s", p.PURL, pkg.PURL)))
+		}
+
+		bom.Components = append(bom.Components, plugin)
+	}
+
+	return bom
+}
+
+func cdxFromComponent(c Component, componentType, ref string) cdxComponent {
+	component := cdxComponent{
+		BOMRef:  ref,
+		Type:    componentType,
+		Name:    c.Name,
+		Version: c.Version,
+		PURL:    c.PURL,
+	}
+
+	for _, h := range c.Hashes {
+		if alg, ok := cycloneDXHashAlgorithms[h.Algorithm]; ok {
+			component.Hashes = append(component.Hashes, cdxHash{alg, h.Value})
+		}
+	}
+
+	if c.Source != "" {
+		component.ExternalReferences = []cdxExternalRef{{Type: "distribution", URL: c.Source}}
+	}
+
+	return component
+}
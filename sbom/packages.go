@@ -0,0 +1,274 @@
+package sbom
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	ecosystemNPM  = "npm"
+	ecosystemPyPI = "pypi"
+)
+
+// npmLockedPackage is an entry of the hidden lock file npm keeps in
+// node_modules, which records where each package came from
+type npmLockedPackage struct {
+	Resolved  string `json:"resolved"`
+	Integrity string `json:"integrity"`
+}
+
+// npmPackages lists every package in a plugin's node_modules, including the
+// ones nested in the node_modules of other packages
+func npmPackages(dir string) ([]Component, error) {
+	lock := struct {
+		Packages map[string]npmLockedPackage `json:"packages"`
+	}{}
+
+	// npm 6 and older don't write the lock file, the packages are still listed
+	// but without their hashes
+	contents, err := os.ReadFile(filepath.Join(dir, "node_modules", ".package-lock.json"))
+	if err == nil {
+		if err = json.Unmarshal(contents, &lock); err != nil {
+			return nil, fmt.Errorf("could not parse node_modules/.package-lock.json: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	w := &npmWalker{dir: dir, locked: lock.Packages, seen: map[string]bool{}, packages: []Component{}}
+	if err = w.walk("node_modules"); err != nil {
+		return nil, err
+	}
+
+	sortPackages(w.packages)
+	return w.packages, nil
+}
+
+type npmWalker struct {
+	dir      string
+	locked   map[string]npmLockedPackage
+	seen     map[string]bool
+	packages []Component
+}
+
+// walk visits the packages of a node_modules directory. Paths are relative to
+// the plugin's directory and slash separated, like the keys of the lock file
+func (w *npmWalker) walk(nmPath string) error {
+	entries, err := os.ReadDir(filepath.Join(w.dir, filepath.FromSlash(nmPath)))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		// scoped packages are a directory further down
+		if strings.HasPrefix(name, "@") && entry.IsDir() {
+			if err = w.walk(path.Join(nmPath, name)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err = w.visit(path.Join(nmPath, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// visit adds the package at pkgPath and then the packages nested in it. Linked
+// packages aren't followed, they live outside the plugin
+func (w *npmWalker) visit(pkgPath string) error {
+	full := filepath.Join(w.dir, filepath.FromSlash(pkgPath))
+	info, err := os.Lstat(full)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return nil
+	}
+
+	contents, err := os.ReadFile(filepath.Join(full, "package.json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	manifest := struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}{}
+	if err = json.Unmarshal(contents, &manifest); err != nil {
+		return fmt.Errorf("could not parse %s/package.json: %w", pkgPath, err)
+	}
+
+	if purl := npmPURL(manifest.Name, manifest.Version); manifest.Name != "" && manifest.Version != "" && !w.seen[purl] {
+		w.seen[purl] = true
+
+		locked := w.locked[pkgPath]
+		w.packages = append(w.packages, Component{
+			Name:    manifest.Name,
+			Version: manifest.Version,
+			Type:    ecosystemNPM,
+			PURL:    purl,
+			Source:  locked.Resolved,
+			Hashes:  subresourceHashes(locked.Integrity),
+		})
+	}
+
+	return w.walk(path.Join(pkgPath, "node_modules"))
+}
+
+func npmPURL(name, version string) string {
+	namespace := ""
+	if idx := strings.Index(name, "/"); strings.HasPrefix(name, "@") && idx > 0 {
+		namespace, name = purlEscape(name[:idx])+"/", name[idx+1:]
+	}
+
+	return fmt.Sprintf("pkg:npm/%s%s@%s", namespace, purlEscape(name), purlEscape(version))
+}
+
+// purlEscape percent-encodes a package URL segment. @ and + are allowed in URL
+// paths but not in package URL segments
+func purlEscape(segment string) string {
+	return strings.NewReplacer("@", "%40", "+", "%2B").Replace(url.PathEscape(segment))
+}
+
+// subresourceHashes decodes the space separated algorithm-base64 hashes npm
+// records as a package's integrity
+func subresourceHashes(integrity string) []Hash {
+	hashes := []Hash{}
+	for _, field := range strings.Fields(integrity) {
+		idx := strings.Index(field, "-")
+		if idx < 0 {
+			continue
+		}
+
+		sum, err := base64.StdEncoding.DecodeString(field[idx+1:])
+		if err != nil {
+			continue
+		}
+
+		hashes = append(hashes, Hash{Algorithm: strings.ToLower(field[:idx]), Value: hex.EncodeToString(sum)})
+	}
+
+	return hashes
+}
+
+// pythonPackages lists every distribution installed in a plugin's virtual
+// environment
+func pythonPackages(dir string) ([]Component, error) {
+	patterns := []string{
+		filepath.Join(dir, "venv", "lib", "python*", "site-packages", "*.dist-info", "METADATA"),
+		filepath.Join(dir, "venv", "lib", "python*", "site-packages", "*.egg-info", "PKG-INFO"),
+		// virtual environments on Windows
+		filepath.Join(dir, "venv", "Lib", "site-packages", "*.dist-info", "METADATA"),
+		filepath.Join(dir, "venv", "Lib", "site-packages", "*.egg-info", "PKG-INFO"),
+	}
+
+	packages := []Component{}
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, metadata := range matches {
+			name, version, err := readPythonMetadata(metadata)
+			if err != nil {
+				return nil, err
+			}
+
+			if name == "" || version == "" {
+				continue
+			}
+
+			purl := fmt.Sprintf("pkg:pypi/%s@%s", purlEscape(normalizePythonName(name)), purlEscape(version))
+			if seen[purl] {
+				continue
+			}
+			seen[purl] = true
+
+			packages = append(packages, Component{
+				Name:    name,
+				Version: version,
+				Type:    ecosystemPyPI,
+				PURL:    purl,
+			})
+		}
+	}
+
+	sortPackages(packages)
+	return packages, nil
+}
+
+// readPythonMetadata reads the name and version from the headers of a
+// distribution's METADATA or PKG-INFO file
+func readPythonMetadata(path string) (string, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	name, version := "", ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// the headers end at the first blank line, the description follows
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			switch strings.ToLower(key) {
+			case "name":
+				name = strings.TrimSpace(value)
+			case "version":
+				version = strings.TrimSpace(value)
+			}
+		}
+	}
+
+	return name, version, scanner.Err()
+}
+
+var pythonNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// normalizePythonName normalizes a distribution name as PyPI and package URLs do
+func normalizePythonName(name string) string {
+	return pythonNameSeparators.ReplaceAllString(strings.ToLower(name), "-")
+}
+
+func sortPackages(packages []Component) {
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Name != packages[j].Name {
+			return packages[i].Name < packages[j].Name
+		}
+		return packages[i].Version < packages[j].Version
+	})
+}
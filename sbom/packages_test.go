@@ -0,0 +1,236 @@
+package sbom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// writeFiles creates files, by slash separated path relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// describe summarizes packages as "purl source hashes"
+func describe(packages []Component) []string {
+	described := make([]string, 0, len(packages))
+	for _, p := range packages {
+		described = append(described, fmt.Sprintf("%s %s %v", p.PURL, p.Source, p.Hashes))
+	}
+
+	return described
+}
+
+func TestNpmPackages(t *testing.T) {
+	const lock = `{
+  "packages": {
+    "node_modules/a": {
+      "resolved": "https://registry.npmjs.org/a/-/a-1.0.0.tgz",
+      "integrity": "sha512-YWJj sha1-ZGVm"
+    },
+    "node_modules/@scope/b": {
+      "resolved": "https://registry.npmjs.org/@scope/b/-/b-2.0.0.tgz",
+      "integrity": "sha512-not-base64!"
+    }
+  }
+}`
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		packages []string
+		err      bool
+	}{
+		{
+			name:     "no node_modules",
+			files:    map[string]string{"export-plugin": ""},
+			packages: []string{},
+		},
+		{
+			name: "packages with the lock file",
+			files: map[string]string{
+				"node_modules/.package-lock.json":    lock,
+				"node_modules/a/package.json":        `{"name": "a", "version": "1.0.0"}`,
+				"node_modules/@scope/b/package.json": `{"name": "@scope/b", "version": "2.0.0+build.1"}`,
+			},
+			packages: []string{
+				"pkg:npm/%40scope/b@2.0.0%2Bbuild.1 https://registry.npmjs.org/@scope/b/-/b-2.0.0.tgz []",
+				"pkg:npm/a@1.0.0 https://registry.npmjs.org/a/-/a-1.0.0.tgz [{sha512 616263} {sha1 646566}]",
+			},
+		},
+		{
+			name: "packages without the lock file",
+			files: map[string]string{
+				"node_modules/a/package.json": `{"name": "a", "version": "1.0.0"}`,
+			},
+			packages: []string{"pkg:npm/a@1.0.0  []"},
+		},
+		{
+			name: "nested and duplicate packages",
+			files: map[string]string{
+				"node_modules/a/package.json":                          `{"name": "a", "version": "1.0.0"}`,
+				"node_modules/a/node_modules/c/package.json":           `{"name": "c", "version": "1.0.0"}`,
+				"node_modules/a/node_modules/@scope/d/package.json":    `{"name": "@scope/d", "version": "0.1.0"}`,
+				"node_modules/e/package.json":                          `{"name": "e", "version": "3.0.0"}`,
+				"node_modules/e/node_modules/c/package.json":           `{"name": "c", "version": "2.0.0"}`,
+				"node_modules/e/node_modules/a/package.json":           `{"name": "a", "version": "1.0.0"}`,
+				"node_modules/e/node_modules/a/node_modules/.keep":     "",
+				"node_modules/e/node_modules/c/node_modules/f/LICENSE": "",
+			},
+			packages: []string{
+				"pkg:npm/%40scope/d@0.1.0  []",
+				"pkg:npm/a@1.0.0  []",
+				"pkg:npm/c@1.0.0  []",
+				"pkg:npm/c@2.0.0  []",
+				"pkg:npm/e@3.0.0  []",
+			},
+		},
+		{
+			name: "hidden directories, files and incomplete manifests are skipped",
+			files: map[string]string{
+				"node_modules/.bin/a":              "",
+				"node_modules/.cache/package.json": `{"name": "cache", "version": "1.0.0"}`,
+				"node_modules/README.md":           "",
+				"node_modules/g/package.json":      `{"name": "g"}`,
+				"node_modules/h/package.json":      `{"version": "1.0.0"}`,
+			},
+			packages: []string{},
+		},
+		{
+			name: "unparsable manifest",
+			files: map[string]string{
+				"node_modules/a/package.json": `{"name": `,
+			},
+			err: true,
+		},
+		{
+			name: "unparsable lock file",
+			files: map[string]string{
+				"node_modules/.package-lock.json": `{"packages": [`,
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			packages, err := npmPackages(dir)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := describe(packages); !reflect.DeepEqual(got, tt.packages) {
+				t.Errorf("npmPackages() = %q, want %q", got, tt.packages)
+			}
+		})
+	}
+}
+
+func TestNpmPackagesSkipsLinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on windows")
+	}
+
+	dir := t.TempDir()
+	outside := t.TempDir()
+	writeFiles(t, outside, map[string]string{"package.json": `{"name": "linked", "version": "1.0.0"}`})
+	writeFiles(t, dir, map[string]string{"node_modules/a/package.json": `{"name": "a", "version": "1.0.0"}`})
+
+	if err := os.Symlink(outside, filepath.Join(dir, "node_modules", "linked")); err != nil {
+		t.Fatal(err)
+	}
+
+	packages, err := npmPackages(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := describe(packages), []string{"pkg:npm/a@1.0.0  []"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("npmPackages() = %q, want %q", got, want)
+	}
+}
+
+func TestPythonPackages(t *testing.T) {
+	sitePackages := "venv/lib/python3.11/site-packages"
+	if runtime.GOOS == "windows" {
+		sitePackages = "venv/Lib/site-packages"
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		packages []string
+	}{
+		{
+			name:     "no virtual environment",
+			packages: []string{},
+		},
+		{
+			name: "wheels and eggs",
+			files: map[string]string{
+				sitePackages + "/requests-2.31.0.dist-info/METADATA": "Metadata-Version: 2.1\nName: requests\nVersion: 2.31.0\nSummary: HTTP\n",
+				sitePackages + "/Legacy_Pkg-0.1.egg-info/PKG-INFO":   "Metadata-Version: 1.0\nname: Legacy_Pkg\nversion: 0.1\n",
+			},
+			packages: []string{
+				"pkg:pypi/legacy-pkg@0.1  []",
+				"pkg:pypi/requests@2.31.0  []",
+			},
+		},
+		{
+			name: "headers end at the first blank line",
+			files: map[string]string{
+				sitePackages + "/zope.interface-6.0.dist-info/METADATA": "Name: zope.interface\nVersion: 6.0\n\nName: not-a-header\nVersion: 9.9\n",
+			},
+			packages: []string{"pkg:pypi/zope-interface@6.0  []"},
+		},
+		{
+			name: "duplicate and incomplete distributions",
+			files: map[string]string{
+				sitePackages + "/six-1.16.0.dist-info/METADATA": "Name: six\nVersion: 1.16.0\n",
+				sitePackages + "/six-1.16.0.egg-info/PKG-INFO":  "Name: six\nVersion: 1.16.0\n",
+				sitePackages + "/broken.dist-info/METADATA":     "Name: broken\n",
+			},
+			packages: []string{"pkg:pypi/six@1.16.0  []"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			packages, err := pythonPackages(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := describe(packages); !reflect.DeepEqual(got, tt.packages) {
+				t.Errorf("pythonPackages() = %q, want %q", got, tt.packages)
+			}
+		})
+	}
+}
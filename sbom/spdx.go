@@ -0,0 +1,141 @@
+package sbom
+
+import "fmt"
+
+const spdxNoAssertion = "NOASSERTION"
+
+// spdxHashAlgorithms maps hash algorithms to their SPDX names
+var spdxHashAlgorithms = map[string]string{
+	"sha1":    "SHA1",
+	"sha256":  "SHA256",
+	"sha384":  "SHA384",
+	"sha512":  "SHA512",
+	"blake2b": "BLAKE2b-512",
+}
+
+type spdxDoc struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name                  string            `json:"name"`
+	SPDXID                string            `json:"SPDXID"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	DownloadLocation      string            `json:"downloadLocation"`
+	FilesAnalyzed         bool              `json:"filesAnalyzed"`
+	Checksums             []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs          []spdxExternalRef `json:"externalRefs,omitempty"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose"`
+	Comment               string            `json:"comment,omitempty"`
+	Annotations           []spdxAnnotation  `json:"annotations,omitempty"`
+}
+
+type spdxAnnotation struct {
+	Annotator string `json:"annotator"`
+	Date      string `json:"annotationDate"`
+	Type      string `json:"annotationType"`
+	Comment   string `json:"comment"`
+}
+
+type spdxChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+// spdxDocument is an SPDX 2.3 document describing a package for each plugin,
+// which contains the packages installed with it
+func spdxDocument(doc document) spdxDoc {
+	created := doc.created.Format("2006-01-02T15:04:05Z")
+	creator := fmt.Sprintf("Tool: %s-%s", toolName, doc.version)
+
+	d := spdxDoc{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              toolName + "-plugins",
+		DocumentNamespace: fmt.Sprintf("https://spdx.org/spdxdocs/%s-plugins-%s", toolName, doc.serial),
+		CreationInfo: spdxCreationInfo{
+			Created:  created,
+			Creators: []string{creator},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+
+	// SPDX identifiers only allow letters, numbers, dots and dashes, so they're
+	// numbered rather than named
+	for i, p := range doc.components {
+		pluginID := fmt.Sprintf("SPDXRef-Plugin-%d", i+1)
+		plugin := spdxFromComponent(p, pluginID, "APPLICATION")
+		plugin.Comment = fmt.Sprintf("%s plugin", p.Type)
+		if p.TreeHash != nil {
+			plugin.Annotations = []spdxAnnotation{{
+				Annotator: creator,
+				Date:      created,
+				Type:      "OTHER",
+				Comment:   fmt.Sprintf("%s tree hash of the installed plugin directory: %s", p.TreeHash.Algorithm, p.TreeHash.Value),
+			}}
+		}
+
+		d.Packages = append(d.Packages, plugin)
+		d.Relationships = append(d.Relationships, spdxRelationship{d.SPDXID, "DESCRIBES", pluginID})
+
+		for j, pkg := range p.Packages {
+			pkgID := fmt.Sprintf("SPDXRef-Package-%d-%d", i+1, j+1)
+			d.Packages = append(d.Packages, spdxFromComponent(pkg, pkgID, "LIBRARY"))
+			d.Relationships = append(d.Relationships, spdxRelationship{pluginID, "CONTAINS", pkgID})
+		}
+	}
+
+	return d
+}
+
+func spdxFromComponent(c Component, id, purpose string) spdxPackage {
+	pkg := spdxPackage{
+		Name:                  c.Name,
+		SPDXID:                id,
+		VersionInfo:           c.Version,
+		DownloadLocation:      spdxNoAssertion,
+		PrimaryPackagePurpose: purpose,
+	}
+
+	if c.Source != "" {
+		pkg.DownloadLocation = c.Source
+	}
+
+	for _, h := range c.Hashes {
+		if alg, ok := spdxHashAlgorithms[h.Algorithm]; ok {
+			pkg.Checksums = append(pkg.Checksums, spdxChecksum{alg, h.Value})
+		}
+	}
+
+	if c.PURL != "" {
+		pkg.ExternalRefs = []spdxExternalRef{{Category: "PACKAGE-MANAGER", Type: "purl", Locator: c.PURL}}
+	}
+
+	return pkg
+}
@@ -0,0 +1,274 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/kong"
+	"github.com/blang/semver/v4"
+	"github.com/gideaworx/terraform-exporter/registry"
+	"github.com/gideaworx/terraform-exporter/runner"
+	"github.com/olekukonko/tablewriter"
+)
+
+// how much each kind of match adds to a result's score. Matches on the name
+// rank above matches on the commands, which rank above the description
+const (
+	scoreExactName     = 100
+	scoreNamePrefix    = 60
+	scoreName          = 40
+	scoreExactCommand  = 30
+	scoreCommand       = 20
+	scoreDescription   = 10
+	scoreCommandDetail = 5
+)
+
+type Command struct {
+	Terms            []string `arg:"" help:"The terms to search for. A plugin must match every term"`
+	Registries       []string `short:"r" name:"registry" help:"Only search these registries. Defaults to every registry in the catalog"`
+	AllArchitectures bool     `short:"a" help:"If set, also show plugins with no version that runs on this machine"`
+	AllowPrerelease  bool     `help:"If set, pre-release versions may be shown as the latest version"`
+	r                *registry.PluginRegistries
+}
+
+type result struct {
+	registry  string
+	plugin    registry.Plugin
+	latest    string
+	installed string
+	matched   []string
+	score     int
+}
+
+func (c *Command) BeforeApply() error {
+	var err error
+	c.r, err = registry.LoadFromDisk()
+	return err
+}
+
+func (c *Command) Run(ctx *kong.Context) error {
+	names := c.Registries
+	if len(names) == 0 {
+		for n := range c.r.GetAll() {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	registries, failed := c.loadAll(ctx, names)
+	if failed == len(names) {
+		return errors.New("no registry could be searched")
+	}
+
+	boms, err := runner.LoadInstalledBOMs()
+	if err != nil {
+		return err
+	}
+
+	terms := make([]string, 0, len(c.Terms))
+	for _, t := range c.Terms {
+		terms = append(terms, strings.ToLower(t))
+	}
+
+	results := []result{}
+	for _, name := range names {
+		reg := registries[name]
+		if reg == nil {
+			continue
+		}
+
+		for _, p := range reg.Plugins {
+			score, matched := match(p, terms)
+			if score == 0 {
+				continue
+			}
+
+			latest, ok := registry.LatestCompatible(p, func(v semver.Version) bool {
+				return c.AllowPrerelease || len(v.Pre) == 0
+			})
+			if !ok && !c.AllArchitectures {
+				continue
+			}
+
+			results = append(results, result{
+				registry:  name,
+				plugin:    p,
+				latest:    latest.Version,
+				installed: installedVersion(boms, p.Name, name),
+				matched:   matched,
+				score:     score,
+			})
+		}
+	}
+
+	if len(results) == 0 {
+		fmt.Fprintf(ctx.Stdout, "No plugins match %q\n", strings.Join(c.Terms, " "))
+		return nil
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+
+		if results[i].plugin.Name != results[j].plugin.Name {
+			return results[i].plugin.Name < results[j].plugin.Name
+		}
+
+		return results[i].registry < results[j].registry
+	})
+
+	headers := []string{"Plugin", "Registry", "Latest Version", "Installed", "Matched", "Description"}
+	headerColors := make([]tablewriter.Colors, len(headers))
+	for i := range headers {
+		headerColors[i] = tablewriter.Colors{tablewriter.Bold, tablewriter.FgHiWhiteColor}
+	}
+
+	table := tablewriter.NewWriter(ctx.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader(headers)
+	table.SetHeaderAlignment(tablewriter.ALIGN_CENTER)
+	table.SetHeaderColor(headerColors...)
+	table.SetColumnColor(
+		tablewriter.Colors{tablewriter.Bold, tablewriter.FgCyanColor},
+		tablewriter.Colors{tablewriter.FgYellowColor},
+		tablewriter.Colors{tablewriter.FgGreenColor},
+		tablewriter.Colors{},
+		tablewriter.Colors{},
+		tablewriter.Colors{tablewriter.FgWhiteColor},
+	)
+	table.SetHeaderLine(true)
+
+	for _, r := range results {
+		latest := r.latest
+		if latest == "" {
+			latest = "not for this machine"
+		}
+
+		table.Append([]string{r.plugin.Name, r.registry, latest, r.installed, strings.Join(r.matched, ", "), r.plugin.Description})
+	}
+	table.Render()
+
+	return nil
+}
+
+// loadAll loads the indexes of the registries in parallel. A registry that
+// can't be loaded is reported and left out of the search
+func (c *Command) loadAll(ctx *kong.Context, names []string) (map[string]*registry.PluginRegistry, int) {
+	loaded := map[string]*registry.PluginRegistry{}
+	lock := &sync.Mutex{}
+	failed := 0
+
+	wg := &sync.WaitGroup{}
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+
+			reg := c.r.Get(name)
+			err := fmt.Errorf("registry %q not installed", name)
+			if reg != nil {
+				err = reg.LazyLoad()
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if err != nil {
+				fmt.Fprintf(ctx.Stderr, "warning: could not search %s: %v\n", name, err)
+				failed++
+				return
+			}
+
+			loaded[name] = reg
+		}(name)
+	}
+	wg.Wait()
+
+	return loaded, failed
+}
+
+// match scores how well a plugin matches the search terms, and says what
+// matched. A plugin that doesn't match every term scores 0
+func match(p registry.Plugin, terms []string) (int, []string) {
+	name := strings.ToLower(p.Name)
+	description := strings.ToLower(p.Description)
+
+	total := 0
+	matched := []string{}
+	seen := map[string]bool{}
+	add := func(what string) {
+		if !seen[what] {
+			seen[what] = true
+			matched = append(matched, what)
+		}
+	}
+
+	for _, term := range terms {
+		score := 0
+		switch {
+		case name == term:
+			score += scoreExactName
+			add("name")
+		case strings.HasPrefix(name, term):
+			score += scoreNamePrefix
+			add("name")
+		case strings.Contains(name, term):
+			score += scoreName
+			add("name")
+		}
+
+		for _, cmd := range p.Commands {
+			cmdName := strings.ToLower(cmd.Name)
+			switch {
+			case cmdName == term:
+				score += scoreExactCommand
+				add("command " + cmd.Name)
+			case strings.Contains(cmdName, term):
+				score += scoreCommand
+				add("command " + cmd.Name)
+			case strings.Contains(strings.ToLower(cmd.Summary), term):
+				score += scoreCommandDetail
+				add("command " + cmd.Name)
+			}
+		}
+
+		if strings.Contains(description, term) {
+			score += scoreDescription
+			add("description")
+		}
+
+		if score == 0 {
+			return 0, nil
+		}
+		total += score
+	}
+
+	return total, matched
+}
+
+// installedVersion describes the active installed version of a plugin, noting
+// when it was installed from another registry
+func installedVersion(boms []runner.BillOfMaterials, pluginName, registryName string) string {
+	for _, bom := range boms {
+		if bom.Name != pluginName {
+			continue
+		}
+
+		if bom.Source.Type == "registry" && bom.Source.Name == registryName {
+			return bom.Version.String()
+		}
+
+		source := bom.Source.Name
+		if source == "" {
+			source = bom.Source.Type
+		}
+
+		return fmt.Sprintf("%s (from %s)", bom.Version.String(), source)
+	}
+
+	return ""
+}
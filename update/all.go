@@ -0,0 +1,73 @@
+package update
+
+import (
+	"fmt"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+func (c *Command) updateAll() error {
+	all, err := findCandidates(c.r, c.AllowPrerelease)
+	if err != nil {
+		return err
+	}
+
+	if len(all) == 0 {
+		fmt.Fprintln(c.ctx.Stdout, "No plugins installed from a registry")
+		return nil
+	}
+
+	failed := 0
+	summary := [][]string{}
+	for _, candidate := range all {
+		bom := candidate.bom
+		installed := bom.Version.String()
+
+		if candidate.err != nil {
+			failed++
+			summary = append(summary, []string{bom.Name, installed, "", fmt.Sprintf("failed: %v", candidate.err)})
+			continue
+		}
+
+		target := candidate.target(c.Only)
+		if target == "" {
+			summary = append(summary, []string{bom.Name, installed, "", "up to date"})
+			continue
+		}
+
+		u := &Command{
+			Registry:         bom.Source.Name,
+			PluginVersion:    target,
+			AllowPrerelease:  true,
+			HashAlgorithm:    c.HashAlgorithm,
+			RequireSignature: c.RequireSignature,
+			PluginName:       bom.Name,
+			ctx:              c.ctx,
+			in:               c.in,
+			r:                c.r,
+		}
+
+		if err := u.Run(); err != nil {
+			failed++
+			summary = append(summary, []string{bom.Name, installed, target, fmt.Sprintf("failed: %v", err)})
+			continue
+		}
+
+		summary = append(summary, []string{bom.Name, installed, target, "updated"})
+	}
+
+	fmt.Fprintln(c.ctx.Stdout)
+	table := tablewriter.NewWriter(c.ctx.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Plugin", "From", "To", "Result"})
+	table.SetHeaderLine(true)
+	table.SetBorder(true)
+	table.AppendBulk(summary)
+	table.Render()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d plugins could not be updated", failed, len(all))
+	}
+
+	return nil
+}
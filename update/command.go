@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/alecthomas/kong"
+	"github.com/blang/semver/v4"
 	"github.com/gideaworx/terraform-exporter/registry"
 	"github.com/gideaworx/terraform-exporter/runner"
 )
@@ -14,16 +15,22 @@ import (
 var ErrPluginNewer = errors.New("installed plugin is newer than the candidate")
 
 type Command struct {
-	LocalFile       bool                       `short:"f" help:"If set, treat the plugin-name arg as the path to a local file"`
-	Registry        string                     `short:"r" default:"default" help:"The name of the registry to install the plugin from"`
-	PluginVersion   string                     `help:"The version to install from the registry. Ignored if --local-file is set"`
-	AllowDowngrades bool                       `default:"false" help:"If set, allow an upgrade even if the new version is lower than the installed version"`
-	Install         bool                       `default:"false" help:"If set, install the plugin if the plugin isn't already installed"`
-	PluginName      string                     `arg:"" help:"The name of the plugin to install if --registry is true, or the path to the executable plugin if --local-file is set"`
-	pluginHomeDir   string                     `kong:"-"`
-	ctx             *kong.Context              `kong:"-"`
-	in              io.Reader                  `kong:"-"`
-	r               *registry.PluginRegistries `kong:"-"`
+	LocalFile        bool                       `short:"f" help:"If set, treat the plugin-name arg as the path to a local file"`
+	Registry         string                     `short:"r" help:"The name of the registry to update the plugin from. Defaults to the registry it was installed from, or with --install, the first registry by priority that has the plugin"`
+	PluginVersion    string                     `help:"The version or version constraint (e.g. \"~> 1.2\", \">=1.0,<2\" or \"^0.3\") to update to. Ignored if --local-file is set"`
+	AllowPrerelease  bool                       `help:"If set, pre-release versions are candidates for the update"`
+	AllowYanked      bool                       `help:"If set, a version that was yanked from the registry may be the update"`
+	AllowDowngrades  bool                       `default:"false" help:"If set, allow an upgrade even if the new version is lower than the installed version"`
+	Install          bool                       `default:"false" help:"If set, install the plugin if the plugin isn't already installed"`
+	All              bool                       `short:"a" help:"If set, update every plugin installed from a registry to its newest version"`
+	RequireSignature bool                       `help:"If set, refuse to update to a version whose signature isn't verified with a key the registry trusts"`
+	HashAlgorithm    string                     `default:"sha256" enum:"sha256,sha384,sha512,blake2b" help:"The hash algorithm used to record the updated plugin's integrity. One of sha256, sha384, sha512 or blake2b"`
+	Only             string                     `enum:"major,minor,patch" default:"major" help:"With --all, the largest kind of update to apply. One of major, minor or patch"`
+	PluginName       string                     `arg:"" optional:"" help:"The name of the plugin to update, optionally as registry/plugin, or the path to the executable plugin if --local-file is set"`
+	pluginHomeDir    string                     `kong:"-"`
+	ctx              *kong.Context              `kong:"-"`
+	in               io.Reader                  `kong:"-"`
+	r                *registry.PluginRegistries `kong:"-"`
 }
 
 func (i *Command) BeforeApply(ctx *kong.Context) error {
@@ -44,9 +51,36 @@ func (i *Command) Run() error {
 	}
 	i.pluginHomeDir = pluginHome
 
+	if i.All {
+		if i.PluginName != "" || i.LocalFile {
+			return errors.New("--all cannot be combined with a plugin name or --local-file")
+		}
+
+		return i.updateAll()
+	}
+
+	if i.PluginName == "" {
+		return errors.New("a plugin name is required unless --all is set")
+	}
+
+	if !i.LocalFile {
+		registryName, pluginName := registry.SplitPluginRef(i.PluginName)
+		if registryName != "" {
+			if i.Registry != "" && i.Registry != registryName {
+				return fmt.Errorf("%s names registry %q, but --registry is %q", i.PluginName, registryName, i.Registry)
+			}
+
+			i.Registry = registryName
+			i.PluginName = pluginName
+		}
+	}
+
 	version := "the latest version"
 	if i.PluginVersion != "" {
 		version = fmt.Sprintf("version %s", i.PluginVersion)
+		if _, err := semver.ParseTolerant(i.PluginVersion); err != nil {
+			version = fmt.Sprintf("the latest version matching %q", i.PluginVersion)
+		}
 	}
 
 	fmt.Fprintf(i.ctx.Stdout, "Updating %s to %s\n\n", i.PluginName, version)
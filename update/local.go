@@ -3,14 +3,11 @@ package update
 import (
 	"errors"
 	"fmt"
-	"io"
 	"path/filepath"
 	"strings"
 
-	"github.com/alecthomas/kong"
 	"github.com/blang/semver/v4"
 	"github.com/gideaworx/terraform-exporter/install"
-	"github.com/gideaworx/terraform-exporter/remove"
 	"github.com/gideaworx/terraform-exporter/runner"
 )
 
@@ -21,24 +18,14 @@ func (c *Command) localUpdate() error {
 	}
 
 	i := &install.Command{
-		LocalFile:  true,
-		PluginName: c.PluginName,
+		LocalFile:     true,
+		PluginName:    c.PluginName,
+		HashAlgorithm: c.HashAlgorithm,
 	}
 	if err := i.BeforeApply(c.ctx); err != nil {
 		return err
 	}
 
-	r := &remove.Command{
-		PluginName:     c.PluginName,
-		NonInteractive: true,
-	}
-	r.BeforeApply(&kong.Context{
-		Kong: &kong.Kong{
-			Stdout: io.Discard,
-			Stderr: io.Discard,
-		},
-	}, strings.NewReader(""))
-
 	bom, err := runner.LoadPluginBOM(filepath.Base(c.PluginName))
 	if errors.Is(err, runner.ErrPluginNotFound) {
 		if c.Install {
@@ -48,14 +35,8 @@ func (c *Command) localUpdate() error {
 		return err
 	}
 
-	if c.AllowDowngrades {
-		// we don't have to version check here
-		err = r.Run()
-		if err != nil {
-			return err
-		}
-
-		return i.Run()
+	if err != nil {
+		return err
 	}
 
 	newPluginInfo, err := runner.LoadPluginInfo(pluginFileName, nil)
@@ -63,19 +44,45 @@ func (c *Command) localUpdate() error {
 		return err
 	}
 
-	comp, err := compareVersions(bom.Version, newPluginInfo.Version)
-	if err != nil {
-		return fmt.Errorf("could not parse versions: %w", err)
-	}
-	if comp >= 0 {
-		return ErrPluginNewer
+	if !c.AllowDowngrades {
+		comp, err := compareVersions(bom.Version, newPluginInfo.Version)
+		if err != nil {
+			return fmt.Errorf("could not parse versions: %w", err)
+		}
+		if comp >= 0 {
+			return ErrPluginNewer
+		}
 	}
 
-	err = r.Run()
+	return c.installOrActivate(i, newPluginInfo.Version.String())
+}
+
+// installOrActivate installs version of a plugin next to the versions that
+// are already installed. If that version is already installed from the same
+// file it's just activated, and if the file was rebuilt it's reinstalled
+func (c *Command) installOrActivate(i *install.Command, version string) error {
+	pluginName := filepath.Base(c.PluginName)
+	ref := runner.PluginRef(pluginName, version)
+
+	installed, err := runner.LoadPluginBOM(ref)
 	if err != nil {
-		return err
+		return i.Run()
+	}
+
+	if installed.Integrity != nil {
+		checksum, err := runner.FileChecksum(c.PluginName, installed.Integrity.Algorithm)
+		if err != nil {
+			return err
+		}
+
+		if strings.EqualFold(checksum, installed.Integrity.Checksum) {
+			fmt.Fprintf(c.ctx.Stdout, "%s is already installed, activating it\n", ref)
+			return runner.SetActiveVersion(pluginName, version)
+		}
 	}
 
+	fmt.Fprintf(c.ctx.Stdout, "%s is already installed from a different file, reinstalling it\n", ref)
+	i.Replace = true
 	return i.Run()
 }
 
@@ -0,0 +1,166 @@
+package update
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/alecthomas/kong"
+	"github.com/blang/semver/v4"
+	"github.com/gideaworx/terraform-exporter/registry"
+	"github.com/gideaworx/terraform-exporter/runner"
+	"github.com/olekukonko/tablewriter"
+)
+
+const (
+	levelMajor = "major"
+	levelMinor = "minor"
+	levelPatch = "patch"
+)
+
+type OutdatedCommand struct {
+	All             bool `short:"a" help:"If set, also show plugins that are up to date"`
+	AllowPrerelease bool `help:"If set, consider pre-release versions"`
+	r               *registry.PluginRegistries
+}
+
+// candidates are the newest compatible registry versions of an installed plugin
+// that are reachable by a patch, minor or major update
+type candidates struct {
+	bom   runner.BillOfMaterials
+	patch string
+	minor string
+	major string
+	err   error
+}
+
+func (c candidates) target(level string) string {
+	switch level {
+	case levelPatch:
+		return c.patch
+	case levelMinor:
+		return c.minor
+	default:
+		return c.major
+	}
+}
+
+func (o *OutdatedCommand) BeforeApply() error {
+	var err error
+	o.r, err = registry.LoadFromDisk()
+	return err
+}
+
+func (o *OutdatedCommand) Run(ctx *kong.Context) error {
+	all, err := findCandidates(o.r, o.AllowPrerelease)
+	if err != nil {
+		return err
+	}
+
+	table := tablewriter.NewWriter(ctx.Stdout)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"Plugin", "Registry", "Installed", "Latest Patch", "Latest Minor", "Latest"})
+	table.SetHeaderLine(true)
+	table.SetBorder(true)
+
+	for _, c := range all {
+		if c.err != nil {
+			table.Append([]string{c.bom.Name, c.bom.Source.Name, c.bom.Version.String(), "", "", fmt.Sprintf("error: %v", c.err)})
+			continue
+		}
+
+		if c.major == "" && !o.All {
+			continue
+		}
+
+		table.Append([]string{c.bom.Name, c.bom.Source.Name, c.bom.Version.String(), c.patch, c.minor, c.major})
+	}
+
+	table.Render()
+	return nil
+}
+
+// findCandidates looks up update candidates for every plugin installed from a
+// registry. Lookup errors are reported per plugin rather than failing them all
+func findCandidates(r *registry.PluginRegistries, allowPrerelease bool) ([]candidates, error) {
+	boms, err := runner.LoadInstalledBOMs()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(boms, func(i, j int) bool {
+		return boms[i].Name < boms[j].Name
+	})
+
+	loaded := map[string]*registry.PluginRegistry{}
+	all := []candidates{}
+	for _, bom := range boms {
+		if bom.Source.Type != "registry" {
+			continue
+		}
+
+		c := candidates{bom: bom}
+		reg, ok := loaded[bom.Source.Name]
+		if !ok {
+			if reg = r.Get(bom.Source.Name); reg == nil {
+				c.err = fmt.Errorf("registry %q is no longer configured", bom.Source.Name)
+			} else if c.err = reg.LazyLoad(); c.err != nil {
+				reg = nil
+			}
+			loaded[bom.Source.Name] = reg
+		}
+
+		if c.err == nil && reg == nil {
+			c.err = fmt.Errorf("registry %q could not be loaded", bom.Source.Name)
+		}
+
+		if c.err == nil {
+			c.patch, c.minor, c.major, c.err = newerVersions(reg, bom, allowPrerelease)
+		}
+
+		all = append(all, c)
+	}
+
+	return all, nil
+}
+
+// newerVersions finds the newest versions reachable by a patch, minor and major
+// update. Pre-releases are skipped unless allowed, or the installed version is
+// a pre-release itself
+func newerVersions(reg *registry.PluginRegistry, bom runner.BillOfMaterials, allowPrerelease bool) (string, string, string, error) {
+	p, ok := reg.FindPlugin(bom.Name)
+	if !ok {
+		return "", "", "", fmt.Errorf("plugin not found in registry %q", bom.Source.Name)
+	}
+
+	installed, err := semver.ParseTolerant(bom.Version.String())
+	if err != nil {
+		return "", "", "", err
+	}
+
+	latest := func(accept func(semver.Version) bool) string {
+		v, ok := registry.LatestCompatible(p, func(sv semver.Version) bool {
+			if len(sv.Pre) > 0 && !allowPrerelease && len(installed.Pre) == 0 {
+				return false
+			}
+
+			return sv.GT(installed) && accept(sv)
+		})
+		if !ok {
+			return ""
+		}
+
+		return v.Version
+	}
+
+	patch := latest(func(sv semver.Version) bool {
+		return sv.Major == installed.Major && sv.Minor == installed.Minor
+	})
+	minor := latest(func(sv semver.Version) bool {
+		return sv.Major == installed.Major
+	})
+	major := latest(func(sv semver.Version) bool {
+		return true
+	})
+
+	return patch, minor, major, nil
+}
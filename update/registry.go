@@ -3,57 +3,49 @@ package update
 import (
 	"errors"
 	"fmt"
-	"io"
 	"path/filepath"
-	"sort"
-	"strings"
 
-	"github.com/alecthomas/kong"
-	"github.com/gideaworx/terraform-exporter-plugin-registry/registry"
 	"github.com/gideaworx/terraform-exporter/install"
-	"github.com/gideaworx/terraform-exporter/remove"
+	localreg "github.com/gideaworx/terraform-exporter/registry"
 	"github.com/gideaworx/terraform-exporter/runner"
 )
 
 func (c *Command) registryUpdate() error {
 	i := &install.Command{
-		LocalFile:     false,
-		PluginName:    c.PluginName,
-		Registry:      c.Registry,
-		PluginVersion: c.PluginVersion,
+		LocalFile:        false,
+		PluginName:       c.PluginName,
+		Registry:         c.Registry,
+		PluginVersion:    c.PluginVersion,
+		AllowPrerelease:  c.AllowPrerelease,
+		AllowYanked:      c.AllowYanked,
+		HashAlgorithm:    c.HashAlgorithm,
+		RequireSignature: c.RequireSignature,
 	}
 	if err := i.BeforeApply(c.ctx); err != nil {
 		return err
 	}
 
-	r := &remove.Command{
-		PluginName:     c.PluginName,
-		NonInteractive: true,
-	}
-	r.BeforeApply(&kong.Context{
-		Kong: &kong.Kong{
-			Stdout: io.Discard,
-			Stderr: io.Discard,
-		},
-	}, strings.NewReader(""))
-
 	bom, err := runner.LoadPluginBOM(filepath.Base(c.PluginName))
 	if errors.Is(err, runner.ErrPluginNotFound) {
 		if c.Install {
+			// with no registry named, install resolves one by priority
 			return i.Run()
 		}
 
 		return err
 	}
 
-	if c.AllowDowngrades {
-		// we don't have to version check here
-		err = r.Run()
-		if err != nil {
-			return err
+	if err != nil {
+		return err
+	}
+
+	if c.Registry == "" {
+		if bom.Source.Type != "registry" || bom.Source.Name == "" {
+			return fmt.Errorf("%s was not installed from a registry, name one with --registry", c.PluginName)
 		}
 
-		return i.Run()
+		c.Registry = bom.Source.Name
+		i.Registry = c.Registry
 	}
 
 	reg := c.r.Get(c.Registry)
@@ -65,60 +57,38 @@ func (c *Command) registryUpdate() error {
 		return fmt.Errorf("could not load plugin information from registry: %w", err)
 	}
 
-	var versions []registry.PluginVersion
-	for _, p := range reg.Plugins {
-		if p.Name == c.PluginName {
-			versions = p.Versions
-			break
-		}
-	}
-
-	if len(versions) == 0 {
+	p, ok := reg.FindPlugin(c.PluginName)
+	if !ok || len(p.Versions) == 0 {
 		return fmt.Errorf("plugin %s has no available versions to install", c.PluginName)
 	}
 
-	var targetVersion registry.PluginVersion
-	if c.PluginVersion == "" {
-		sort.Slice(versions, func(i, j int) bool {
-			vi, vj := versions[i], versions[j]
-			comp, _ := compareVersions(stringStringer(vi.Version), stringStringer(vj.Version))
-			return comp > 0 // sort in reverse order
-		})
-
-		targetVersion = versions[0]
-	} else {
-		for _, v := range versions {
-			comp, err := compareVersions(stringStringer(c.PluginVersion), stringStringer(v.Version))
-			if err != nil {
-				continue
-			}
-
-			if comp == 0 {
-				targetVersion = v
-				break
-			}
-		}
+	constraint, err := localreg.ParseConstraint(c.PluginVersion)
+	if err != nil {
+		return err
 	}
 
-	if targetVersion.Version == "" {
-		return fmt.Errorf("could not find version %q in plugin registry %q", c.PluginVersion, c.Registry)
+	targetVersion, err := localreg.ResolveVersion(p, constraint, c.AllowPrerelease, c.AllowYanked)
+	if errors.Is(err, localreg.ErrVersionYanked) {
+		return fmt.Errorf("%w. Update to it anyway with --allow-yanked", err)
 	}
 
-	comp, err := compareVersions(bom.Version, stringStringer(targetVersion.Version))
 	if err != nil {
-		return fmt.Errorf("could not determine if %q was newer than %q", bom.Version, targetVersion.Version)
+		return fmt.Errorf("%w in plugin registry %q", err, c.Registry)
 	}
 
-	if comp >= 0 {
-		return ErrPluginNewer
-	}
+	if !c.AllowDowngrades {
+		comp, err := compareVersions(bom.Version, stringStringer(targetVersion.Version))
+		if err != nil {
+			return fmt.Errorf("could not determine if %q was newer than %q", bom.Version, targetVersion.Version)
+		}
 
-	err = r.Run()
-	if err != nil {
-		return err
+		if comp >= 0 {
+			return ErrPluginNewer
+		}
 	}
 
-	return i.Run()
+	i.PluginVersion = targetVersion.Version
+	return c.installOrActivate(i, targetVersion.Version)
 }
 
 type stringStringer string
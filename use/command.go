@@ -0,0 +1,41 @@
+package use
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/kong"
+	"github.com/gideaworx/terraform-exporter/runner"
+)
+
+type Command struct {
+	Plugin string `arg:"" help:"The plugin version to activate, as plugin@version"`
+}
+
+func (c *Command) Run(ctx *kong.Context) error {
+	pluginName, version, err := runner.ParsePluginRef(c.Plugin)
+	if err != nil {
+		return err
+	}
+
+	if version == "" {
+		return fmt.Errorf("%q does not specify a version, use plugin@version", c.Plugin)
+	}
+
+	if err := runner.SetActiveVersion(pluginName, version); err != nil {
+		if errors.Is(err, runner.ErrPluginVersionNotFound) {
+			installed, _ := runner.InstalledVersions(pluginName)
+			if len(installed) == 0 {
+				return fmt.Errorf("plugin %q is not installed", pluginName)
+			}
+
+			return fmt.Errorf("%w. Installed versions of %s are %q", err, pluginName, strings.Join(installed, ", "))
+		}
+
+		return err
+	}
+
+	fmt.Fprintf(ctx.Stdout, "Now using %s\n", runner.PluginRef(pluginName, version))
+	return nil
+}